@@ -22,12 +22,13 @@ import (
 	"os/signal"
 	"path"
 	"syscall"
+	"time"
 
 	"github.com/MangosArentLiterature/Athena/internal/athena"
 	"github.com/MangosArentLiterature/Athena/internal/db"
 	"github.com/MangosArentLiterature/Athena/internal/logger"
 	"github.com/MangosArentLiterature/Athena/internal/settings"
-	"github.com/MangosArentLiterature/Athena/internal/sliceutil"
+	"github.com/xhit/go-str2duration/v2"
 )
 
 var (
@@ -65,9 +66,29 @@ func main() {
 	case "fatal":
 		logger.CurrentLevel = logger.Fatal
 	}
-	logger.LogStdOut = sliceutil.ContainsString(config.LogMethods, "stdout")
-	logger.LogFile = sliceutil.ContainsString(config.LogMethods, "log_file")
+	err = logger.ConfigureSinks(config.LogMethods, logger.SinkConfig{
+		HTTPURL:       config.LogHTTPURL,
+		HTTPBatchSize: config.LogHTTPBatchSize,
+		HTTPInterval:  time.Duration(config.LogHTTPInterval) * time.Second,
+		SyslogNetwork: config.LogSyslogNetwork,
+		SyslogAddress: config.LogSyslogAddress,
+	})
+	if err != nil {
+		logger.LogFatalf("failed to set up log_methods: %v", err)
+		os.Exit(1)
+	}
 	logger.DebugNetwork = *netDebugFlag
+	logger.MaxLogSize = int64(config.LogMaxSize) * 1024 * 1024
+	if config.LogRotateInterval != "" {
+		logger.RotateInterval, err = str2duration.ParseDuration(config.LogRotateInterval)
+		if err != nil {
+			logger.LogFatalf("failed to parse log_rotate_interval: %v", err)
+			os.Exit(1)
+		}
+	}
+	logger.CompressLogs = config.LogCompress
+	logger.LogRetention = time.Duration(config.LogRetentionDays) * 24 * time.Hour
+	logger.StartJanitor(time.Hour)
 	db.DBPath = settings.ConfigPath + "/athena.db"
 
 	err = athena.InitServer(config)
@@ -107,4 +128,5 @@ func main() {
 	}
 	athena.CleanupServer()
 	logger.LogInfo("Stopping server.")
+	logger.Shutdown()
 }
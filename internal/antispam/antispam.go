@@ -0,0 +1,254 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+// Package antispam implements content and flood heuristics for chat messages,
+// with escalating responses for repeat offenders.
+package antispam
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Names of the rules a message can trip.
+const (
+	RuleFlood     = "flood"
+	RuleDuplicate = "duplicate"
+	RuleCaps      = "caps"
+	RuleZalgo     = "zalgo"
+	RuleNewlines  = "newlines"
+)
+
+// Response is the action to take against a spammer.
+type Response int
+
+const (
+	Warn Response = iota
+	Mute
+	Kick
+)
+
+// String returns the string representation of a response.
+func (r Response) String() string {
+	switch r {
+	case Warn:
+		return "warn"
+	case Mute:
+		return "mute"
+	case Kick:
+		return "kick"
+	}
+	return ""
+}
+
+// Config holds the thresholds used by a Checker. A zero value disables the corresponding rule.
+type Config struct {
+	FloodLimit         int           // Messages allowed per IPID within FloodWindow.
+	FloodWindow        time.Duration // Window for FloodLimit.
+	DuplicateLimit     int           // Identical messages allowed from anyone within DuplicateWindow.
+	DuplicateWindow    time.Duration // Window for DuplicateLimit.
+	DuplicateMinLength int           // Messages shorter than this are never considered duplicates.
+	CapsRatio          float64       // Maximum ratio of uppercase letters.
+	CapsMinLength      int           // Minimum number of letters before the caps rule applies.
+	MaxCombining       int           // Maximum number of combining marks (zalgo text).
+	MaxNewlines        int           // Maximum number of line breaks.
+	MuteStrikes        int           // Strikes at which offenders are muted instead of warned.
+	KickStrikes        int           // Strikes at which offenders are kicked.
+	StrikeDecay        time.Duration // Time without violations after which strikes reset.
+}
+
+// Verdict describes a message that tripped a rule.
+type Verdict struct {
+	Rule     string
+	Detail   string
+	Strikes  int
+	Response Response
+}
+
+type sentMessage struct {
+	text string
+	at   time.Time
+}
+
+type strikeRecord struct {
+	count int
+	last  time.Time
+}
+
+// Checker tracks recent messages and strikes. It is safe for concurrent use.
+type Checker struct {
+	mu        sync.Mutex
+	conf      Config
+	recent    []sentMessage
+	flood     map[string][]time.Time
+	strikes   map[string]*strikeRecord
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New returns a checker using the given thresholds.
+func New(conf Config) *Checker {
+	return &Checker{
+		conf:    conf,
+		flood:   make(map[string][]time.Time),
+		strikes: make(map[string]*strikeRecord),
+		now:     time.Now,
+	}
+}
+
+// Check records a message sent by ipid and runs it through every rule.
+// If the message trips a rule, the offender is given a strike and the resulting verdict is returned with ok set to false.
+func (c *Checker) Check(ipid string, text string) (v Verdict, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if now.Sub(c.lastSweep) > time.Minute {
+		c.sweep(now)
+	}
+
+	rule, detail := c.checkFlood(ipid, now)
+	if rule == "" {
+		rule, detail = c.checkContent(text)
+	}
+	if rule == "" {
+		rule, detail = c.checkDuplicate(text, now)
+	}
+	if rule == "" {
+		return Verdict{}, true
+	}
+
+	s := c.strikes[ipid]
+	if s == nil || (c.conf.StrikeDecay > 0 && now.Sub(s.last) > c.conf.StrikeDecay) {
+		s = &strikeRecord{}
+		c.strikes[ipid] = s
+	}
+	s.count++
+	s.last = now
+
+	v = Verdict{Rule: rule, Detail: detail, Strikes: s.count, Response: Warn}
+	switch {
+	case c.conf.KickStrikes > 0 && s.count >= c.conf.KickStrikes:
+		v.Response = Kick
+		delete(c.strikes, ipid)
+	case c.conf.MuteStrikes > 0 && s.count >= c.conf.MuteStrikes:
+		v.Response = Mute
+	}
+	return v, false
+}
+
+// checkFlood records a message from ipid and reports whether the IPID's flood limit has been exceeded.
+func (c *Checker) checkFlood(ipid string, now time.Time) (string, string) {
+	if c.conf.FloodLimit <= 0 {
+		return "", ""
+	}
+	times := pruneTimes(c.flood[ipid], now.Add(-c.conf.FloodWindow))
+	times = append(times, now)
+	c.flood[ipid] = times
+	if len(times) > c.conf.FloodLimit {
+		return RuleFlood, fmt.Sprintf("%v messages in %v", len(times), c.conf.FloodWindow)
+	}
+	return "", ""
+}
+
+// checkContent runs the stateless content rules.
+func (c *Checker) checkContent(text string) (string, string) {
+	var letters, upper, combining int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			combining++
+		case unicode.IsLetter(r):
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if c.conf.MaxCombining > 0 && combining > c.conf.MaxCombining {
+		return RuleZalgo, fmt.Sprintf("%v combining marks", combining)
+	}
+	// AO2 renders the two-character sequence \n as a line break in IC.
+	if newlines := strings.Count(text, "\n") + strings.Count(text, `\n`); c.conf.MaxNewlines > 0 && newlines > c.conf.MaxNewlines {
+		return RuleNewlines, fmt.Sprintf("%v line breaks", newlines)
+	}
+	if c.conf.CapsRatio > 0 && letters >= c.conf.CapsMinLength && letters > 0 {
+		if ratio := float64(upper) / float64(letters); ratio > c.conf.CapsRatio {
+			return RuleCaps, fmt.Sprintf("%.0f%% uppercase", ratio*100)
+		}
+	}
+	return "", ""
+}
+
+// checkDuplicate records a message and reports whether it has been sent too many times recently, by anyone.
+func (c *Checker) checkDuplicate(text string, now time.Time) (string, string) {
+	if c.conf.DuplicateLimit <= 0 {
+		return "", ""
+	}
+	norm := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	if len([]rune(norm)) < c.conf.DuplicateMinLength {
+		return "", ""
+	}
+	cutoff := now.Add(-c.conf.DuplicateWindow)
+	i := 0
+	for i < len(c.recent) && !c.recent[i].at.After(cutoff) {
+		i++
+	}
+	c.recent = append(c.recent[i:], sentMessage{text: norm, at: now})
+	var count int
+	for _, m := range c.recent {
+		if m.text == norm {
+			count++
+		}
+	}
+	if count > c.conf.DuplicateLimit {
+		return RuleDuplicate, fmt.Sprintf("sent %v times in %v", count, c.conf.DuplicateWindow)
+	}
+	return "", ""
+}
+
+// sweep drops state for IPIDs that have been quiet long enough to no longer matter.
+func (c *Checker) sweep(now time.Time) {
+	c.lastSweep = now
+	for ipid, times := range c.flood {
+		if times = pruneTimes(times, now.Add(-c.conf.FloodWindow)); len(times) == 0 {
+			delete(c.flood, ipid)
+		} else {
+			c.flood[ipid] = times
+		}
+	}
+	if c.conf.StrikeDecay > 0 {
+		for ipid, s := range c.strikes {
+			if now.Sub(s.last) > c.conf.StrikeDecay {
+				delete(c.strikes, ipid)
+			}
+		}
+	}
+}
+
+// pruneTimes removes timestamps at or before cutoff from a sorted slice.
+func pruneTimes(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	if i == len(times) {
+		return nil
+	}
+	return times[i:]
+}
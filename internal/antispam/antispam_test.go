@@ -0,0 +1,105 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package antispam
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestChecker returns a checker with a controllable clock.
+func newTestChecker(conf Config) (*Checker, *time.Time) {
+	c := New(conf)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestContentRules(t *testing.T) {
+	c, _ := newTestChecker(Config{CapsRatio: 0.7, CapsMinLength: 8, MaxCombining: 5, MaxNewlines: 3})
+	tests := []struct {
+		text string
+		rule string
+	}{
+		{"hello there", ""},
+		{"OK", ""},
+		{"WHY IS EVERYONE SHOUTING", RuleCaps},
+		{"Objection! The Witness Is Lying", ""},
+		{"h̀́̂̃̄̅i", RuleZalgo},
+		{`a\nb\nc\nd\ne`, RuleNewlines},
+		{"a\nb\nc", ""},
+	}
+	for _, tt := range tests {
+		v, ok := c.Check("ipid", tt.text)
+		if tt.rule == "" && !ok {
+			t.Errorf("Check(%q) tripped %v, want pass", tt.text, v.Rule)
+		} else if tt.rule != "" && v.Rule != tt.rule {
+			t.Errorf("Check(%q) rule = %q, want %q", tt.text, v.Rule, tt.rule)
+		}
+	}
+}
+
+func TestDuplicateAcrossUsers(t *testing.T) {
+	c, now := newTestChecker(Config{DuplicateLimit: 2, DuplicateWindow: 10 * time.Second, DuplicateMinLength: 5})
+	c.Check("a", "buy cheap gold")
+	c.Check("b", "Buy  cheap gold")
+	if v, ok := c.Check("c", "buy cheap GOLD"); ok || v.Rule != RuleDuplicate {
+		t.Fatalf("third duplicate passed, verdict %+v", v)
+	}
+	if _, ok := c.Check("d", "lol"); !ok {
+		t.Error("short message should never count as a duplicate")
+	}
+	*now = now.Add(11 * time.Second)
+	if _, ok := c.Check("e", "buy cheap gold"); !ok {
+		t.Error("duplicate outside the window should pass")
+	}
+}
+
+func TestFloodPerIPID(t *testing.T) {
+	c, now := newTestChecker(Config{FloodLimit: 3, FloodWindow: 5 * time.Second})
+	for i := 0; i < 3; i++ {
+		if _, ok := c.Check("multi", "msg"); !ok {
+			t.Fatalf("message %v tripped flood early", i+1)
+		}
+	}
+	if v, ok := c.Check("multi", "msg"); ok || v.Rule != RuleFlood {
+		t.Fatalf("fourth message passed, verdict %+v", v)
+	}
+	if _, ok := c.Check("other", "msg"); !ok {
+		t.Error("flood limit should be per IPID")
+	}
+	*now = now.Add(6 * time.Second)
+	if _, ok := c.Check("multi", "msg"); !ok {
+		t.Error("flood window should expire")
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	c, now := newTestChecker(Config{MaxNewlines: 1, MuteStrikes: 2, KickStrikes: 3, StrikeDecay: time.Minute})
+	spam := "a\nb\nc"
+	want := []Response{Warn, Mute, Kick, Warn}
+	for i, r := range want {
+		v, ok := c.Check("ipid", spam)
+		if ok || v.Response != r {
+			t.Errorf("strike %v: response = %v, want %v", i+1, v.Response, r)
+		}
+	}
+	*now = now.Add(2 * time.Minute)
+	if v, _ := c.Check("ipid", spam); v.Strikes != 1 {
+		t.Errorf("strikes after decay = %v, want 1", v.Strikes)
+	}
+}
@@ -0,0 +1,296 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/MangosArentLiterature/Athena/internal/totp"
+)
+
+const (
+	freeLoginAttempts = 3               // Failed logins allowed before an account is locked.
+	baseLockout       = 5 * time.Second // Lockout after the first failure past the free attempts, doubled for each further failure.
+	maxLockout        = time.Hour       // Longest lockout.
+	failureMemory     = 24 * time.Hour  // How long failed logins are remembered for.
+)
+
+// loginKey identifies the logins to an account from a single IPID.
+// Lockouts are kept per IPID so that other players can't lock a moderator out of their own account.
+type loginKey struct {
+	ipid     string
+	username string
+}
+
+// loginFailures tracks failed logins to a single account from a single IPID.
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// loginLimiter locks accounts out with exponential backoff after repeated failed logins.
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[loginKey]*loginFailures
+}
+
+var loginLimits = loginLimiter{failures: make(map[loginKey]*loginFailures)}
+
+// locked reports whether username is locked out for ipid, and for how much longer.
+func (l *loginLimiter) locked(ipid string, username string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.failures[loginKey{ipid, username}]
+	if !ok || !now.Before(f.lockedUntil) {
+		return 0, false
+	}
+	return f.lockedUntil.Sub(now), true
+}
+
+// fail records a failed login to username from ipid, returning how long the account is now locked for that IPID.
+func (l *loginLimiter) fail(ipid string, username string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, f := range l.failures {
+		if now.Sub(f.last) > failureMemory {
+			delete(l.failures, k)
+		}
+	}
+	key := loginKey{ipid, username}
+	f, ok := l.failures[key]
+	if !ok {
+		f = &loginFailures{}
+		l.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	if f.count <= freeLoginAttempts {
+		return 0
+	}
+	lockout := maxLockout
+	if n := f.count - freeLoginAttempts - 1; n < 10 {
+		lockout = baseLockout << n
+		if lockout > maxLockout {
+			lockout = maxLockout
+		}
+	}
+	f.lockedUntil = now.Add(lockout)
+	return lockout
+}
+
+// reset clears the failed logins to username from ipid.
+func (l *loginLimiter) reset(ipid string, username string) {
+	l.mu.Lock()
+	delete(l.failures, loginKey{ipid, username})
+	l.mu.Unlock()
+}
+
+// resetUser clears the failed logins to username from every IPID.
+func (l *loginLimiter) resetUser(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k := range l.failures {
+		if k.username == username {
+			delete(l.failures, k)
+		}
+	}
+}
+
+// usedTOTP holds the time step of the last two-factor code accepted for each account.
+var usedTOTP = struct {
+	mu    sync.Mutex
+	steps map[string]int64
+}{steps: make(map[string]int64)}
+
+// checkTOTP reports whether code is a valid two-factor code for username's secret that hasn't been used before.
+// An accepted code, and every earlier one, can't be used again.
+func checkTOTP(username string, secret string, code string) bool {
+	step, ok := totp.Match(secret, code, time.Now())
+	if !ok {
+		return false
+	}
+	usedTOTP.mu.Lock()
+	defer usedTOTP.mu.Unlock()
+	if last, used := usedTOTP.steps[username]; used && step <= last {
+		return false
+	}
+	usedTOTP.steps[username] = step
+	return true
+}
+
+// loginFailed records a failed login to username, locking the account for the client's IPID if there have been too many.
+func loginFailed(client *Client, username string, msg string) {
+	client.SendPacket("AUTH", "0")
+	addToBuffer(client, "AUTH", msg, true)
+	if lockout := loginLimits.fail(client.Ipid(), username, time.Now()); lockout > 0 {
+		client.SendServerMessage(fmt.Sprintf("Too many failed login attempts. Try again in %v.", lockout))
+		addToBuffer(client, "AUTH", fmt.Sprintf("Locked login to %v for %v after repeated failures.", username, lockout), true)
+	}
+}
+
+// checkPassword checks the password of a logged in client before a change to their account, described by action.
+// Failures count towards the account's lockout.
+func checkPassword(client *Client, username string, password string, action string) bool {
+	if wait, locked := loginLimits.locked(client.Ipid(), username, time.Now()); locked {
+		client.SendServerMessage(fmt.Sprintf("Too many failed attempts. Try again in %v.", wait.Round(time.Second)))
+		return false
+	}
+	if auth, _, _ := db.AuthenticateUser(username, []byte(password)); !auth {
+		client.SendServerMessage("Incorrect password.")
+		addToBuffer(client, "AUTH", fmt.Sprintf("Failed %v for %v: incorrect password.", action, username), true)
+		if lockout := loginLimits.fail(client.Ipid(), username, time.Now()); lockout > 0 {
+			addToBuffer(client, "AUTH", fmt.Sprintf("Locked login to %v for %v after repeated failures.", username, lockout), true)
+		}
+		return false
+	}
+	return true
+}
+
+// Handles /passwd
+func cmdPasswd(client *Client, args []string, usage string) {
+	if !client.Authenticated() {
+		client.SendServerMessage("You are not logged in.")
+		return
+	}
+	username := client.ModName()
+	if !checkPassword(client, username, args[0], "password change") {
+		return
+	}
+	if args[1] == "" {
+		client.SendServerMessage("Invalid password.\n" + usage)
+		return
+	}
+	if err := db.ChangePassword(username, []byte(args[1])); err != nil {
+		logger.LogErrorf("Failed to change password of %v: %v", username, err)
+		client.SendServerMessage("Failed to change password.")
+		return
+	}
+	loginLimits.resetUser(username)
+	client.SendServerMessage("Password changed.")
+	addToBuffer(client, "AUTH", fmt.Sprintf("Changed password for %v.", username), true)
+}
+
+// Handles /2fa
+func cmd2FA(client *Client, args []string, usage string) {
+	if !client.Authenticated() {
+		client.SendServerMessage("You are not logged in.")
+		return
+	}
+	username := client.ModName()
+	switch args[0] {
+	case "enable":
+		secret, err := db.GetTOTPSecret(username)
+		if err != nil {
+			logger.LogErrorf("Failed to get two-factor secret of %v: %v", username, err)
+			client.SendServerMessage("Failed to enable two-factor authentication.")
+			return
+		}
+		if secret != "" {
+			client.SendServerMessage("Two-factor authentication is already enabled.")
+			return
+		}
+		secret, err = totp.GenerateSecret()
+		if err != nil {
+			logger.LogErrorf("Failed to generate two-factor secret: %v", err)
+			client.SendServerMessage("Failed to enable two-factor authentication.")
+			return
+		}
+		client.SetPendingTOTP(secret)
+		client.SendServerMessage(fmt.Sprintf("Add this secret to your authenticator app: %v\n%v\nThen run /2fa confirm <code> to finish enabling two-factor authentication.",
+			secret, totp.URI(config.Name, username, secret)))
+
+	case "confirm":
+		if len(args) < 2 {
+			client.SendServerMessage("Not enough arguments.\n" + usage)
+			return
+		}
+		secret := client.PendingTOTP()
+		if secret == "" {
+			client.SendServerMessage("Run /2fa enable first.")
+			return
+		}
+		if !checkTOTP(username, secret, args[1]) {
+			client.SendServerMessage("Invalid code.")
+			return
+		}
+		if err := db.SetTOTPSecret(username, secret); err != nil {
+			logger.LogErrorf("Failed to set two-factor secret of %v: %v", username, err)
+			client.SendServerMessage("Failed to enable two-factor authentication.")
+			return
+		}
+		client.SetPendingTOTP("")
+		client.SendServerMessage("Two-factor authentication enabled. You will need a code from your authenticator app to log in.")
+		addToBuffer(client, "AUTH", fmt.Sprintf("Enabled two-factor authentication for %v.", username), true)
+
+	case "disable":
+		if len(args) < 3 {
+			client.SendServerMessage("Not enough arguments.\n" + usage)
+			return
+		}
+		secret, err := db.GetTOTPSecret(username)
+		if err != nil || secret == "" {
+			client.SendServerMessage("Two-factor authentication is not enabled.")
+			return
+		}
+		if !checkPassword(client, username, args[1], "disabling two-factor authentication") {
+			return
+		}
+		if !checkTOTP(username, secret, args[2]) {
+			client.SendServerMessage("Invalid code.")
+			addToBuffer(client, "AUTH", fmt.Sprintf("Failed to disable two-factor authentication for %v: invalid code.", username), true)
+			return
+		}
+		if err := db.SetTOTPSecret(username, ""); err != nil {
+			logger.LogErrorf("Failed to clear two-factor secret of %v: %v", username, err)
+			client.SendServerMessage("Failed to disable two-factor authentication.")
+			return
+		}
+		client.SendServerMessage("Two-factor authentication disabled.")
+		addToBuffer(client, "AUTH", fmt.Sprintf("Disabled two-factor authentication for %v.", username), true)
+
+	case "reset":
+		if len(args) < 2 {
+			client.SendServerMessage("Not enough arguments.\n" + usage)
+			return
+		}
+		if !permissions.HasPermission(client.Perms(), permissions.PermissionField["ADMIN"]) {
+			client.SendServerMessage("You do not have permission to reset another user's two-factor authentication.")
+			return
+		}
+		if !db.UserExists(args[1]) {
+			client.SendServerMessage("User does not exist.")
+			return
+		}
+		if err := db.SetTOTPSecret(args[1], ""); err != nil {
+			logger.LogErrorf("Failed to clear two-factor secret of %v: %v", args[1], err)
+			client.SendServerMessage("Failed to reset two-factor authentication.")
+			return
+		}
+		loginLimits.resetUser(args[1])
+		client.SendServerMessage(fmt.Sprintf("Reset two-factor authentication for %v.", args[1]))
+		addToBuffer(client, "AUTH", fmt.Sprintf("Reset two-factor authentication for %v.", args[1]), true)
+
+	default:
+		client.SendServerMessage(usage)
+	}
+}
@@ -0,0 +1,111 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/totp"
+)
+
+// TestLoginLockout tests that accounts are locked with exponential backoff after repeated failed logins
+func TestLoginLockout(t *testing.T) {
+	l := loginLimiter{failures: make(map[loginKey]*loginFailures)}
+	now := time.Now()
+
+	for i := 0; i < freeLoginAttempts; i++ {
+		if lockout := l.fail("ipid", "mod", now); lockout != 0 {
+			t.Fatalf("Failure %d locked the account (free attempts: %d)", i+1, freeLoginAttempts)
+		}
+	}
+	if _, locked := l.locked("ipid", "mod", now); locked {
+		t.Fatalf("Account was locked within the free attempts")
+	}
+
+	want := []time.Duration{baseLockout, 2 * baseLockout, 4 * baseLockout}
+	for _, w := range want {
+		if lockout := l.fail("ipid", "mod", now); lockout != w {
+			t.Errorf("Lockout = %v, want %v", lockout, w)
+		}
+	}
+	if wait, locked := l.locked("ipid", "mod", now.Add(time.Second)); !locked || wait != 4*baseLockout-time.Second {
+		t.Errorf("locked() = %v, %v; want %v, true", wait, locked, 4*baseLockout-time.Second)
+	}
+	if _, locked := l.locked("ipid", "other", now); locked {
+		t.Errorf("Unrelated account was locked")
+	}
+	if _, locked := l.locked("otheripid", "mod", now); locked {
+		t.Errorf("Account was locked for another IPID")
+	}
+	if _, locked := l.locked("ipid", "mod", now.Add(4*baseLockout)); locked {
+		t.Errorf("Account was still locked after the lockout expired")
+	}
+
+	for i := 0; i < 20; i++ {
+		l.fail("ipid", "mod", now)
+	}
+	if lockout := l.fail("ipid", "mod", now); lockout != maxLockout {
+		t.Errorf("Lockout = %v, want the maximum of %v", lockout, maxLockout)
+	}
+
+	l.reset("ipid", "mod")
+	if lockout := l.fail("ipid", "mod", now); lockout != 0 {
+		t.Errorf("Failure after a reset locked the account")
+	}
+}
+
+// TestLoginLockoutResetUser tests that resetting an account clears its lockouts from every IPID
+func TestLoginLockoutResetUser(t *testing.T) {
+	l := loginLimiter{failures: make(map[loginKey]*loginFailures)}
+	now := time.Now()
+	for i := 0; i <= freeLoginAttempts; i++ {
+		l.fail("ipid1", "mod", now)
+		l.fail("ipid2", "mod", now)
+		l.fail("ipid1", "other", now)
+	}
+	l.resetUser("mod")
+	if _, locked := l.locked("ipid1", "mod", now); locked {
+		t.Error("Account was still locked for ipid1 after a reset")
+	}
+	if _, locked := l.locked("ipid2", "mod", now); locked {
+		t.Error("Account was still locked for ipid2 after a reset")
+	}
+	if _, locked := l.locked("ipid1", "other", now); !locked {
+		t.Error("Resetting an account unlocked another one")
+	}
+}
+
+// TestCheckTOTPReuse tests that a two-factor code can only be used once
+func TestCheckTOTPReuse(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	defer func() {
+		usedTOTP.mu.Lock()
+		delete(usedTOTP.steps, "totpmod")
+		usedTOTP.mu.Unlock()
+	}()
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatalf("Code() error: %v", err)
+	}
+	if !checkTOTP("totpmod", secret, code) {
+		t.Fatal("checkTOTP() rejected a valid code")
+	}
+	if checkTOTP("totpmod", secret, code) {
+		t.Error("checkTOTP() accepted a code that was already used")
+	}
+}
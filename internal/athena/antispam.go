@@ -0,0 +1,85 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/antispam"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+)
+
+var spamGuard *antispam.Checker
+
+// newSpamGuard builds the anti-spam checker from the server config, or returns nil if anti-spam is disabled.
+func newSpamGuard(conf *settings.Config) *antispam.Checker {
+	if !conf.SpamEnabled {
+		return nil
+	}
+	return antispam.New(antispam.Config{
+		FloodLimit:         conf.SpamFloodLimit,
+		FloodWindow:        time.Duration(conf.SpamFloodWindow) * time.Second,
+		DuplicateLimit:     conf.SpamDuplicateLimit,
+		DuplicateWindow:    time.Duration(conf.SpamDuplicateWindow) * time.Second,
+		DuplicateMinLength: conf.SpamDuplicateMinLength,
+		CapsRatio:          conf.SpamCapsRatio,
+		CapsMinLength:      conf.SpamCapsMinLength,
+		MaxCombining:       conf.SpamMaxCombining,
+		MaxNewlines:        conf.SpamMaxNewlines,
+		MuteStrikes:        conf.SpamMuteStrikes,
+		KickStrikes:        conf.SpamKickStrikes,
+		StrikeDecay:        time.Duration(conf.SpamStrikeDecay) * time.Second,
+	})
+}
+
+// checkSpam runs a message through the anti-spam heuristics, punishing the sender's IPID if it trips a rule.
+// It returns whether the message should still be sent.
+func checkSpam(client *Client, field string, text string) bool {
+	if spamGuard == nil || permissions.IsModerator(client.Perms()) {
+		return true
+	}
+	v, ok := spamGuard.Check(client.Ipid(), text)
+	if ok {
+		return true
+	}
+	addToBuffer(client, "SPAM", fmt.Sprintf("%v message tripped the %v rule (%v). Strike %v, response: %v.",
+		field, v.Rule, v.Detail, v.Strikes, v.Response), false)
+	logger.LogInfof("Client (IPID:%v UID:%v) tripped anti-spam rule %v (%v), response: %v",
+		client.Ipid(), client.Uid(), v.Rule, v.Detail, v.Response)
+
+	// Strikes are tracked per IPID, so the response applies to every client sharing it.
+	offenders := getClientsByIpid(client.Ipid())
+	switch v.Response {
+	case antispam.Warn:
+		client.SendServerMessage(fmt.Sprintf("Your message was blocked for spam (%v). Continuing will get you muted.", v.Rule))
+	case antispam.Mute:
+		for _, c := range offenders {
+			c.AddMute(ICOOCMuted, time.Now().UTC().Add(time.Duration(config.SpamMuteDuration)*time.Second))
+			c.SendServerMessage(fmt.Sprintf("You have been muted for %v seconds for spam (%v).", config.SpamMuteDuration, v.Rule))
+		}
+	case antispam.Kick:
+		for _, c := range offenders {
+			c.SendPacket("KK", "Kicked for spam.")
+			c.conn.Close()
+		}
+		sendModServerMessage(fmt.Sprintf("[SPAM] Kicked %v (%v) after repeated %v violations.", client.OOCName(), client.Ipid(), v.Rule))
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/antispam"
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+)
+
+// TestSpamMuteKeepsPermanentMute tests that an anti-spam mute doesn't make a moderator's permanent mute expire
+func TestSpamMuteKeepsPermanentMute(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config = &settings.Config{}
+	config.SpamMuteDuration = 60
+	defer setupTestAreas([]*area.Area{makeTestArea("Lobby")})()
+	origGuard := spamGuard
+	spamGuard = antispam.New(antispam.Config{MaxNewlines: 1, MuteStrikes: 1, StrikeDecay: time.Minute})
+	defer func() { spamGuard = origGuard }()
+
+	muted, _ := newHeadlessClient("", 0)
+	muted.SetMuted(ICMuted)
+	free, _ := newHeadlessClient("", 0)
+	for _, c := range []*Client{muted, free} {
+		clients.AddClient(c)
+		defer clients.RemoveClient(c)
+	}
+
+	if checkSpam(free, "OOC", "a\nb\nc") {
+		t.Fatal("checkSpam() allowed spam")
+	}
+	if m, u := muted.Muted(), muted.UnmuteTime(); m != ICOOCMuted || !u.IsZero() {
+		t.Errorf("permanently muted client is %v until %v, want ICOOCMuted with no expiry", m, u)
+	}
+	if m, u := free.Muted(), free.UnmuteTime(); m != ICOOCMuted || u.IsZero() {
+		t.Errorf("unmuted client is %v until %v, want a timed ICOOCMuted", m, u)
+	}
+}
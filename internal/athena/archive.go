@@ -0,0 +1,223 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/xhit/go-str2duration/v2"
+)
+
+const (
+	maxSearchResults   = 25   // Number of messages /search shows.
+	archiveQueueSize   = 1024 // Messages waiting to be archived before new ones are dropped.
+	archiveBatchSize   = 100  // Messages written to the archive in one transaction.
+	archiveFlushPeriod = time.Second
+)
+
+var (
+	archiveMu    sync.RWMutex // Held for reading while queueing messages, so the queue isn't closed during a send.
+	archiveQueue chan db.ChatMessage
+	archiveDone  chan struct{}
+)
+
+// startChatArchive starts the goroutine that writes IC and OOC messages to the chat archive.
+// If retention is positive, messages older than it are deleted once a day.
+func startChatArchive(retention time.Duration) {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+	if archiveQueue != nil {
+		return
+	}
+	archiveQueue = make(chan db.ChatMessage, archiveQueueSize)
+	archiveDone = make(chan struct{})
+	go runChatArchive(archiveQueue, archiveDone, retention)
+}
+
+// stopChatArchive writes any queued messages to the archive and stops archiving.
+func stopChatArchive() {
+	archiveMu.Lock()
+	queue, done := archiveQueue, archiveDone
+	archiveQueue = nil
+	archiveMu.Unlock()
+	if queue == nil {
+		return
+	}
+	close(queue)
+	<-done
+}
+
+// chatArchiveEnabled reports whether messages are being archived.
+func chatArchiveEnabled() bool {
+	archiveMu.RLock()
+	defer archiveMu.RUnlock()
+	return archiveQueue != nil
+}
+
+// runChatArchive writes queued messages to the archive in batches until the queue is closed.
+func runChatArchive(queue chan db.ChatMessage, done chan struct{}, retention time.Duration) {
+	defer close(done)
+	ticker := time.NewTicker(archiveFlushPeriod)
+	defer ticker.Stop()
+	var prune <-chan time.Time
+	if retention > 0 {
+		pruneChatArchive(retention)
+		pruneTicker := time.NewTicker(24 * time.Hour)
+		defer pruneTicker.Stop()
+		prune = pruneTicker.C
+	}
+	batch := make([]db.ChatMessage, 0, archiveBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := db.AddChatMessages(batch); err != nil {
+			logger.LogErrorf("Failed to archive %v chat messages: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case m, ok := <-queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, m)
+			if len(batch) >= archiveBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-prune:
+			pruneChatArchive(retention)
+		}
+	}
+}
+
+// pruneChatArchive deletes archived messages older than the retention period.
+func pruneChatArchive(retention time.Duration) {
+	if err := db.PruneChatArchive(time.Now().Add(-retention).UTC().Unix()); err != nil {
+		logger.LogErrorf("Failed to prune the chat archive: %v", err)
+	}
+}
+
+// archiveMessage queues an IC or OOC message from a client for the chat archive.
+// Messages are dropped rather than blocking the client if the archive falls behind.
+func archiveMessage(client *Client, kind string, message string) {
+	archiveMu.RLock()
+	defer archiveMu.RUnlock()
+	if archiveQueue == nil {
+		return
+	}
+	// Buffer messages are AO2-encoded, and those sent by players are quoted.
+	if len(message) >= 2 && strings.HasPrefix(message, "\"") && strings.HasSuffix(message, "\"") {
+		message = message[1 : len(message)-1]
+	}
+	message = decode(message)
+	m := db.ChatMessage{
+		Time:      time.Now().UTC().Unix(),
+		Area:      client.Area().Name(),
+		Kind:      kind,
+		Character: client.CurrentCharacter(),
+		Showname:  client.Showname(),
+		OOCName:   client.OOCName(),
+		Ipid:      client.Ipid(),
+		Message:   message,
+	}
+	select {
+	case archiveQueue <- m:
+	default:
+		logger.LogDebug("Chat archive queue is full, dropping message.")
+	}
+}
+
+// formatChatMessage returns a single-line summary of an archived message.
+func formatChatMessage(m db.ChatMessage) string {
+	name := m.Character
+	if m.Kind == "OOC" {
+		name = m.OOCName
+	} else if m.Showname != "" && m.Showname != m.Character {
+		name = fmt.Sprintf("%v (%v)", m.Showname, m.Character)
+	}
+	return fmt.Sprintf("[%v] %v | %v | %v (%v): %v", time.Unix(m.Time, 0).UTC().Format("02 Jan 15:04"),
+		m.Area, m.Kind, name, m.Ipid, m.Message)
+}
+
+// Handles /search
+func cmdSearch(client *Client, args []string, usage string) {
+	flags := flag.NewFlagSet("", 0)
+	flags.SetOutput(io.Discard)
+	areaName := flags.String("a", "", "")
+	period := flags.String("p", "", "")
+	before := flags.String("b", "", "")
+	limit := flags.Int("n", maxSearchResults, "")
+	if err := flags.Parse(args); err != nil {
+		client.SendServerMessage("Invalid arguments.\n" + usage)
+		return
+	}
+	if !chatArchiveEnabled() {
+		client.SendServerMessage("The chat archive is disabled.")
+		return
+	}
+	q := db.ChatQuery{
+		Text:  strings.Join(flags.Args(), " "),
+		Area:  *areaName,
+		Limit: *limit,
+	}
+	if q.Limit <= 0 || q.Limit > maxSearchResults {
+		q.Limit = maxSearchResults
+	}
+	now := time.Now()
+	for _, f := range []struct {
+		arg string
+		dst *int64
+	}{{*period, &q.Since}, {*before, &q.Until}} {
+		if f.arg == "" {
+			continue
+		}
+		d, err := str2duration.ParseDuration(f.arg)
+		if err != nil {
+			client.SendServerMessage("Invalid period.")
+			return
+		}
+		*f.dst = now.Add(-d).UTC().Unix()
+	}
+	msgs, err := db.SearchChat(q)
+	if err != nil {
+		logger.LogErrorf("Failed to search the chat archive: %v", err)
+		client.SendServerMessage("Failed to search the chat archive.")
+		return
+	}
+	if len(msgs) == 0 {
+		client.SendServerMessage("No matching messages.")
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nChat archive (%v messages):", len(msgs))
+	for _, m := range msgs {
+		b.WriteString("\n" + formatChatMessage(m))
+	}
+	client.SendServerMessage(b.String())
+}
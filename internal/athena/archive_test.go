@@ -0,0 +1,140 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+)
+
+// TestFormatChatMessage tests that archived messages are shown with the name their author used
+func TestFormatChatMessage(t *testing.T) {
+	tests := []struct {
+		msg  db.ChatMessage
+		want string
+	}{
+		{db.ChatMessage{Time: 0, Area: "Basement", Kind: "IC", Character: "Phoenix", Showname: "Nick", Ipid: "abc", Message: "Objection!"},
+			"[01 Jan 00:00] Basement | IC | Nick (Phoenix) (abc): Objection!"},
+		{db.ChatMessage{Time: 0, Area: "Basement", Kind: "IC", Character: "Phoenix", Showname: "Phoenix", Ipid: "abc", Message: "Hold it!"},
+			"[01 Jan 00:00] Basement | IC | Phoenix (abc): Hold it!"},
+		{db.ChatMessage{Time: 0, Area: "Basement", Kind: "OOC", Character: "Phoenix", OOCName: "nick", Ipid: "abc", Message: "brb"},
+			"[01 Jan 00:00] Basement | OOC | nick (abc): brb"},
+	}
+	for _, tt := range tests {
+		if got := formatChatMessage(tt.msg); got != tt.want {
+			t.Errorf("formatChatMessage() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// TestSearchChat tests full-text search of the chat archive with area and time filters
+func TestSearchChat(t *testing.T) {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	defer db.Close()
+	err := db.AddChatMessages([]db.ChatMessage{
+		{Time: 100, Area: "Basement", Kind: "IC", Character: "Phoenix", Message: "Take that!"},
+		{Time: 200, Area: "Courtroom", Kind: "IC", Character: "Edgeworth", Message: "Objection! That's \"wrong\"."},
+		{Time: 300, Area: "Basement", Kind: "OOC", OOCName: "maya", Message: "objection spam again?"},
+	})
+	if err != nil {
+		t.Fatalf("AddChatMessages() error: %v", err)
+	}
+	tests := []struct {
+		name  string
+		query db.ChatQuery
+		want  []int64
+	}{
+		{"text", db.ChatQuery{Text: "objection"}, []int64{300, 200}},
+		{"every word", db.ChatQuery{Text: "objection wrong"}, []int64{200}},
+		{"punctuation", db.ChatQuery{Text: `"wrong". -`}, []int64{200}},
+		{"author", db.ChatQuery{Text: "phoenix"}, []int64{100}},
+		{"area", db.ChatQuery{Text: "objection", Area: "basement"}, []int64{300}},
+		{"since", db.ChatQuery{Since: 200}, []int64{300, 200}},
+		{"until", db.ChatQuery{Until: 200}, []int64{200, 100}},
+		{"limit", db.ChatQuery{Limit: 1}, []int64{300}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := db.SearchChat(tt.query)
+			if err != nil {
+				t.Fatalf("SearchChat() error: %v", err)
+			}
+			var got []int64
+			for _, m := range msgs {
+				got = append(got, m.Time)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("SearchChat() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("SearchChat() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+	if err := db.PruneChatArchive(250); err != nil {
+		t.Fatalf("PruneChatArchive() error: %v", err)
+	}
+	if msgs, _ := db.SearchChat(db.ChatQuery{}); len(msgs) != 1 {
+		t.Errorf("expected 1 message after pruning, got %v", len(msgs))
+	}
+}
+
+// TestArchiveMessageDecoded tests that messages are archived decoded and unquoted, so searches for encoded characters find them
+func TestArchiveMessageDecoded(t *testing.T) {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	defer db.Close()
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config = &settings.Config{}
+	cleanup := setupTestAreas([]*area.Area{makeTestArea("Lobby")})
+	defer cleanup()
+
+	client, _ := newHeadlessClient("mod", 0)
+	startChatArchive(0)
+	archiveMessage(client, "OOC", "\"rock<and>roll <num>1\"")
+	archiveMessage(client, "IC", "\"100<percent> <dollar>5\"")
+	stopChatArchive()
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"rock&roll", "rock&roll #1"},
+		{"100%", "100% $5"},
+	}
+	for _, tt := range tests {
+		msgs, err := db.SearchChat(db.ChatQuery{Text: tt.text})
+		if err != nil {
+			t.Fatalf("SearchChat() error: %v", err)
+		}
+		if len(msgs) != 1 || msgs[0].Message != tt.want {
+			t.Errorf("SearchChat(%q) = %+v, want %q", tt.text, msgs, tt.want)
+		}
+	}
+}
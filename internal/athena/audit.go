@@ -0,0 +1,112 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/xhit/go-str2duration/v2"
+)
+
+// maxAuditResults is the number of records /audit shows.
+const maxAuditResults = 25
+
+// auditRecord returns an audit record of an action by a client.
+// The action is named after the command the client is running, if any.
+func auditRecord(client *Client, action string, message string) logger.AuditRecord {
+	actor := client.ModName()
+	if actor == "" {
+		actor = client.OOCName()
+	}
+	if cmd := client.RunningCommand(); cmd != "" {
+		action = cmd
+	}
+	return logger.AuditRecord{
+		Time:      time.Now().UTC(),
+		Actor:     actor,
+		ActorIPID: client.Ipid(),
+		Action:    strings.ToLower(action),
+		Area:      client.Area().Name(),
+		Message:   message,
+	}
+}
+
+// addAuditToBuffer is addToBuffer for audited actions with a known target and reason, which are recorded in their own audit fields.
+// activity is the kind of moderator activity the action counts as in /modstats.
+func addAuditToBuffer(client *Client, action string, activity string, message string, target string, reason string, metadata map[string]string) {
+	addToBuffer(client, action, message, false)
+	r := auditRecord(client, action, message)
+	r.Target, r.Reason, r.Metadata = target, reason, metadata
+	logger.WriteAudit(r)
+	if client.Authenticated() {
+		recordModActivity(client, activity, message)
+	}
+}
+
+// Handles /audit
+func cmdAudit(client *Client, args []string, usage string) {
+	flags := flag.NewFlagSet("", 0)
+	flags.SetOutput(io.Discard)
+	actor := flags.String("m", "", "")
+	target := flags.String("t", "", "")
+	action := flags.String("a", "", "")
+	period := flags.String("p", "", "")
+	limit := flags.Int("n", maxAuditResults, "")
+	if err := flags.Parse(args); err != nil {
+		client.SendServerMessage("Invalid arguments.\n" + usage)
+		return
+	}
+	q := logger.AuditQuery{
+		Actor:  *actor,
+		Target: *target,
+		Action: strings.TrimPrefix(*action, "/"),
+		Text:   strings.Join(flags.Args(), " "),
+		Limit:  *limit,
+	}
+	if q.Limit <= 0 || q.Limit > maxAuditResults {
+		q.Limit = maxAuditResults
+	}
+	if *period != "" {
+		d, err := str2duration.ParseDuration(*period)
+		if err != nil {
+			client.SendServerMessage("Invalid period.")
+			return
+		}
+		q.Since = time.Now().Add(-d)
+	}
+	records, err := logger.QueryAudit(q)
+	if err != nil {
+		logger.LogErrorf("Failed to read audit log: %v", err)
+		client.SendServerMessage("Failed to read the audit log.")
+		return
+	}
+	if len(records) == 0 {
+		client.SendServerMessage("No matching audit log entries.")
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nAudit log (%v entries):", len(records))
+	for _, r := range records {
+		b.WriteString("\n" + r.String())
+	}
+	client.SendServerMessage(b.String())
+}
@@ -0,0 +1,85 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	discordbot "github.com/MangosArentLiterature/Athena/internal/discord/bot"
+	"github.com/MangosArentLiterature/Athena/internal/wordfilter"
+)
+
+// discordBot is the running Discord bot, if any.
+var discordBot atomic.Pointer[discordbot.Bot]
+
+// relayOOC relays an OOC message sent in game to any Discord channels bridged with the client's area.
+// It is called after mute, filter and spam checks, so only messages other players can see are relayed.
+func relayOOC(client *Client, msg string) {
+	if b := discordBot.Load(); b != nil {
+		b.RelayOOC(client.Area().Name(), client.OOCName(), decode(msg))
+	}
+}
+
+// bridgeMessage shows a message from a bridged Discord channel in OOC, in one area or all of them.
+// Messages are checked against the chat filter; any hit other than notify or censor blocks the message.
+func bridgeMessage(target *area.Area, author string, message string) error {
+	if chatFilter != nil {
+		if res := chatFilter.Check(message); len(res.Hits) > 0 {
+			where := "all areas"
+			if target != nil {
+				where = target.Name()
+			}
+			sendModServerMessage(fmt.Sprintf("[FILTER] Discord user %v in %v matched the filter (%v): \"%v\"", author, where, res.Action, message))
+			if res.Action != wordfilter.Notify && res.Action != wordfilter.Censor {
+				return fmt.Errorf("it was blocked by the server's filter")
+			}
+			message = res.Text
+		}
+	}
+	name := "[Discord] " + author
+	line := fmt.Sprintf("%v | OOC | Discord | - | %v | %v", time.Now().UTC().Format("15:04:05"), name, message)
+	if target == nil {
+		writeToAll("CT", encode(name), encode(message), "0")
+		for _, a := range areas {
+			a.UpdateBuffer(line)
+		}
+		return nil
+	}
+	writeToArea(target, "CT", encode(name), encode(message), "0")
+	target.UpdateBuffer(line)
+	return nil
+}
+
+// BridgeMessage shows a message from a bridged Discord channel in an area's OOC chat, or every area's if areaName is empty.
+func (a *ServerAdapter) BridgeMessage(areaName string, author string, message string) error {
+	if strings.TrimSpace(message) == "" {
+		return nil
+	}
+	if areaName == "" {
+		return bridgeMessage(nil, author, message)
+	}
+	for _, ar := range areas {
+		if strings.EqualFold(ar.Name(), areaName) {
+			return bridgeMessage(ar, author, message)
+		}
+	}
+	return fmt.Errorf("area %v does not exist", areaName)
+}
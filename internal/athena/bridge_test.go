@@ -0,0 +1,64 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/wordfilter"
+)
+
+// TestBridgeMessage tests that Discord messages reach the right areas and go through the chat filter
+func TestBridgeMessage(t *testing.T) {
+	basement, courtroom := makeTestArea("Basement"), makeTestArea("Courtroom")
+	defer setupTestAreas([]*area.Area{basement, courtroom})()
+	f, err := wordfilter.New([]wordfilter.Rule{
+		{Pattern: "darn", Action: "censor"},
+		{Pattern: "spam", Action: "block"},
+	})
+	if err != nil {
+		t.Fatalf("wordfilter.New() error: %v", err)
+	}
+	origFilter := chatFilter
+	chatFilter = f
+	defer func() { chatFilter = origFilter }()
+
+	s := NewServerAdapter()
+	if err := s.BridgeMessage("basement", "maya", "darn it"); err != nil {
+		t.Fatalf("BridgeMessage() error: %v", err)
+	}
+	if got := strings.Join(basement.Buffer(), "\n"); !strings.Contains(got, "[Discord] maya | **** it") {
+		t.Errorf("Basement buffer = %q, want the censored message", got)
+	}
+	if len(courtroom.Buffer()) != 0 {
+		t.Error("message to one area should not reach another")
+	}
+	if err := s.BridgeMessage("basement", "maya", "buy spam"); err == nil {
+		t.Error("expected blocked message to return an error")
+	}
+	if err := s.BridgeMessage("Lobby", "maya", "hi"); err == nil {
+		t.Error("expected an error for an unknown area")
+	}
+	if err := s.BridgeMessage("", "maya", "hello all"); err != nil {
+		t.Fatalf("BridgeMessage() to all areas error: %v", err)
+	}
+	if got := strings.Join(courtroom.Buffer(), "\n"); !strings.Contains(got, "hello all") {
+		t.Errorf("Courtroom buffer = %q, want the global message", got)
+	}
+}
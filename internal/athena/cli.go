@@ -50,7 +50,7 @@ func ListenInput() {
 				break
 			}
 
-			err = db.CreateUser(user, []byte(pass), role.GetPermissions())
+			err = db.CreateUser(user, []byte(pass), role.Name, role.GetPermissions())
 			if err != nil {
 				logger.LogInfof("Failed to create user: %v.", err.Error())
 				break
@@ -18,7 +18,6 @@ package athena
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
 	"math"
 	"net"
@@ -124,11 +123,18 @@ type Client struct {
 	char          int
 	ipid          string
 	oocName       string
+	nameChecked   string // The last OOC name sent to the word filter, and the filter's result.
+	nameFiltered  string
+	nameAllowed   bool
 	lastmsg       string
 	lastTextColor string
 	perms         uint64
 	authenticated bool
 	mod_name      string
+	mod_role      string
+	pending_totp  string
+	auth_time     time.Time
+	running_cmd   string
 	pos           string
 	case_prefs    [5]bool
 	muted         MuteState
@@ -143,6 +149,7 @@ type Client struct {
 	forcePairUID    int         // UID of the client this client is force-paired with (-1 if none)
 	possessing      int         // UID of the client being possessed (-1 if not possessing anyone)
 	possessedPos    string      // Position of the possessed target (saved at time of possession)
+	pending         bool        // Whether the client holds one of the server's unjoined connection slots
 }
 
 // NewClient returns a new client.
@@ -162,6 +169,20 @@ func NewClient(conn net.Conn, ipid string) *Client {
 func (client *Client) HandleClient() {
 	defer client.clientCleanup()
 
+	if !connLimits.allow(client.Ipid(), config.ConnRateLimit, time.Duration(config.ConnRateLimitWindow)*time.Second, time.Now()) {
+		logger.LogDebugf("%v exceeded the connection rate limit", client.Ipid())
+		client.conn.Close()
+		return
+	}
+	if !connLimits.acquirePending(config.MaxPendingConns) {
+		logger.LogDebugf("Dropped connection from %v: too many pending connections", client.Ipid())
+		client.conn.Close()
+		return
+	}
+	client.mu.Lock()
+	client.pending = true
+	client.mu.Unlock()
+
 	client.CheckBanned(db.IPID)
 
 	var mc int
@@ -184,19 +205,10 @@ func (client *Client) HandleClient() {
 	client.SendPacket("decryptor", "NOENCRYPT") // Relic of FantaCrypt. AO2 requires a server to send this to proceed with the handshake.
 	input := bufio.NewScanner(client.conn)
 
-	splitfn := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		if atEOF && len(data) == 0 {
-			return 0, nil, nil
-		}
-		if i := bytes.IndexByte(data, '%'); i >= 0 {
-			return i + 1, data[:i], nil
-		}
-		if atEOF {
-			return len(data), data, nil
-		}
-		return 0, nil, nil
+	if config.MaxPacketSize > 0 {
+		input.Buffer(make([]byte, 0, 4096), config.MaxPacketSize+1)
 	}
-	input.Split(splitfn) // Split input when a packet delimiter ('%') is found
+	input.Split(splitPacket(config.MaxPacketSize)) // Split input when a packet delimiter ('%') is found
 
 	for input.Scan() {
 		if logger.DebugNetwork {
@@ -214,6 +226,9 @@ func (client *Client) HandleClient() {
 			v.Func(client, packet)
 		}
 	}
+	if input.Err() == errPacketTooLarge {
+		logger.LogInfof("Client (IPID:%v UID:%v) disconnected for sending an oversized packet", client.ipid, client.Uid())
+	}
 	logger.LogDebugf("%v disconnected", client.ipid)
 }
 
@@ -234,6 +249,10 @@ func (client *Client) SendPacket(header string, contents ...string) {
 
 // clientClenup cleans up a disconnected client.
 func (client *Client) clientCleanup() {
+	client.releasePending()
+	if client.Authenticated() {
+		recordModActivity(client, db.ActivityDisconnect, "")
+	}
 	if client.Uid() != -1 {
 		logger.LogInfof("Client (IPID:%v UID:%v) left the server", client.ipid, client.Uid())
 
@@ -273,6 +292,8 @@ func (client *Client) clientCleanup() {
 		client.Area().RemoveChar(client.CharID())
 		writeToAll("PR", strconv.Itoa(client.Uid()), "1")
 		sendPlayerArup()
+		feedEvent(eventLeave, fmt.Sprintf("%v (UID %v) left the server (%v online).",
+			feedName(client.CurrentCharacter()), client.Uid(), players.GetPlayerCount()))
 	}
 	client.conn.Close()
 	clients.RemoveClient(client)
@@ -299,14 +320,29 @@ func (client *Client) CurrentCharacter() string {
 	}
 }
 
-// timeout closes an unjoined client's connection after 1 minute.
+// timeout closes an unjoined client's connection once the handshake timeout has passed.
 func timeout(client *Client) {
-	time.Sleep(1 * time.Minute)
+	d := time.Duration(config.HandshakeTimeout) * time.Second
+	if d <= 0 {
+		d = time.Minute
+	}
+	time.Sleep(d)
 	if client.Uid() == -1 {
 		client.conn.Close()
 	}
 }
 
+// releasePending frees the client's unjoined connection slot, if it holds one.
+func (client *Client) releasePending() {
+	client.mu.Lock()
+	held := client.pending
+	client.pending = false
+	client.mu.Unlock()
+	if held {
+		connLimits.releasePending()
+	}
+}
+
 // Hdid returns the client's hdid.
 func (client *Client) Hdid() string {
 	client.mu.Lock()
@@ -384,6 +420,23 @@ func (client *Client) SetOocName(name string) {
 	client.mu.Unlock()
 }
 
+// CheckedOOCName returns the word filter's result for an OOC name, if it was the last name the client's filter checked.
+func (client *Client) CheckedOOCName(name string) (filtered string, allowed bool, checked bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.nameChecked == "" || client.nameChecked != name {
+		return "", false, false
+	}
+	return client.nameFiltered, client.nameAllowed, true
+}
+
+// SetCheckedOOCName records the word filter's result for an OOC name.
+func (client *Client) SetCheckedOOCName(name string, filtered string, allowed bool) {
+	client.mu.Lock()
+	client.nameChecked, client.nameFiltered, client.nameAllowed = name, filtered, allowed
+	client.mu.Unlock()
+}
+
 // LastMsg returns the client's last sent IC message.
 func (client *Client) LastMsg() string {
 	client.mu.Lock()
@@ -437,9 +490,33 @@ func (client *Client) Authenticated() bool {
 func (client *Client) SetAuthenticated(auth bool) {
 	client.mu.Lock()
 	client.authenticated = auth
+	if auth {
+		client.auth_time = time.Now()
+	}
 	client.mu.Unlock()
 }
 
+// RunningCommand returns the name of the command the client is running, if any.
+func (client *Client) RunningCommand() string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.running_cmd
+}
+
+// SetRunningCommand sets the name of the command the client is running.
+func (client *Client) SetRunningCommand(cmd string) {
+	client.mu.Lock()
+	client.running_cmd = cmd
+	client.mu.Unlock()
+}
+
+// AuthTime returns when the client logged in.
+func (client *Client) AuthTime() time.Time {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.auth_time
+}
+
 // ModName returns the client's moderator username.
 func (client *Client) ModName() string {
 	client.mu.Lock()
@@ -454,6 +531,34 @@ func (client *Client) SetModName(name string) {
 	client.mu.Unlock()
 }
 
+// ModRole returns the name of the client's moderator role.
+func (client *Client) ModRole() string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.mod_role
+}
+
+// SetModRole sets the name of the client's moderator role.
+func (client *Client) SetModRole(role string) {
+	client.mu.Lock()
+	client.mod_role = role
+	client.mu.Unlock()
+}
+
+// PendingTOTP returns the two-factor secret the client is enabling, if any.
+func (client *Client) PendingTOTP() string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.pending_totp
+}
+
+// SetPendingTOTP sets the two-factor secret the client is enabling.
+func (client *Client) SetPendingTOTP(secret string) {
+	client.mu.Lock()
+	client.pending_totp = secret
+	client.mu.Unlock()
+}
+
 // Pos returns the client's current position.
 func (client *Client) Pos() string {
 	client.mu.Lock()
@@ -534,7 +639,7 @@ func (client *Client) SetForcePairUID(uid int) {
 // RemoveAuth logs a client out as moderator.
 func (client *Client) RemoveAuth() {
 	client.mu.Lock()
-	client.authenticated, client.perms, client.mod_name = false, 0, ""
+	client.authenticated, client.perms, client.mod_name, client.mod_role, client.pending_totp = false, 0, "", "", ""
 	client.mu.Unlock()
 	client.SendServerMessage("Logged out as moderator.")
 	client.SendPacket("AUTH", "-1")
@@ -766,6 +871,29 @@ func (client *Client) SetMuted(m MuteState) {
 	client.mu.Unlock()
 }
 
+// AddMute mutes the client until the given time without lifting or shortening an existing mute.
+// IC and OOC mutes combine, a mute that already covers m is kept, and a mute that does not expire stays that way.
+func (client *Client) AddMute(m MuteState, until time.Time) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.muted == Unmuted {
+		client.muted, client.muteuntil = m, until
+		return
+	}
+	switch {
+	case client.muted == m || client.muted == ICOOCMuted:
+	case m == ICOOCMuted,
+		client.muted == ICMuted && m == OOCMuted, client.muted == OOCMuted && m == ICMuted:
+		client.muted = ICOOCMuted
+	case m == ICMuted && (client.muted == MusicMuted || client.muted == JudMuted):
+		// An IC mute also stops music and judge actions.
+		client.muted = ICMuted
+	}
+	if !client.muteuntil.IsZero() && client.muteuntil.Before(until) {
+		client.muteuntil = until
+	}
+}
+
 // UnmuteTime returns the time when the client should be unmuted.
 // If this the time is zero, the mute does not expire.
 func (client *Client) UnmuteTime() time.Time {
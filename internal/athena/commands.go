@@ -32,6 +32,7 @@ import (
 	"github.com/MangosArentLiterature/Athena/internal/db"
 	"github.com/MangosArentLiterature/Athena/internal/logger"
 	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
 	"github.com/MangosArentLiterature/Athena/internal/sliceutil"
 	"github.com/xhit/go-str2duration/v2"
 )
@@ -46,8 +47,23 @@ type Command struct {
 
 var Commands map[string]Command
 
+// subcommandPerms holds the permissions required by subcommands that need more than their command, such as starting
+// a game that anyone can join. They are keyed by command and subcommand, and can be overridden like commands.
+var subcommandPerms map[string]uint64
+
 func initCommands() {
+	subcommandPerms = map[string]uint64{
+		"giveaway start":  permissions.PermissionField["EVENTS"],
+		"hotpotato start": permissions.PermissionField["EVENTS"],
+	}
 	Commands = map[string]Command{
+		"2fa": {
+			handler:  cmd2FA,
+			minArgs:  1,
+			usage:    "Usage: /2fa enable | /2fa confirm <code> | /2fa disable <password> <code> | /2fa reset <username>",
+			desc:     "Manages two-factor authentication for your account.",
+			reqPerms: permissions.PermissionField["NONE"],
+		},
 		"about": {
 			handler:  cmdAbout,
 			minArgs:  0,
@@ -69,6 +85,13 @@ func initCommands() {
 			desc:     "Toggles iniswapping on or off.",
 			reqPerms: permissions.PermissionField["MODIFY_AREA"],
 		},
+		"announce": {
+			handler:  cmdAnnounce,
+			minArgs:  1,
+			usage:    "Usage: /announce <message>",
+			desc:     "Sends an announcement to everyone on the server.",
+			reqPerms: permissions.PermissionField["ANNOUNCE"],
+		},
 		"areainfo": {
 			handler:  cmdAreaInfo,
 			minArgs:  0,
@@ -76,6 +99,20 @@ func initCommands() {
 			desc:     "Prints area settings.",
 			reqPerms: permissions.PermissionField["NONE"],
 		},
+		"approve": {
+			handler:  cmdApprove,
+			minArgs:  1,
+			usage:    "Usage: /approve <id>",
+			desc:     "Approves another moderator's pending action, such as a permanent ban.",
+			reqPerms: permissions.PermissionField["BAN"],
+		},
+		"audit": {
+			handler:  cmdAudit,
+			minArgs:  0,
+			usage:    "Usage: /audit [-m moderator] [-t target] [-a action] [-p period] [-n count] [text]\n-m: Moderator name or IPID.\n-t: Target, such as an IPID.\n-a: Action, such as ban, kick or login.\n-p: How far back to look, e.g. 1d.\n-n: Number of entries to show (max 25).",
+			desc:     "Searches the audit log.",
+			reqPerms: permissions.PermissionField["LOG"],
+		},
 		"ban": {
 			handler:  cmdBan,
 			minArgs:  3,
@@ -97,6 +134,13 @@ func initCommands() {
 			desc:     "Return to character select.",
 			reqPerms: permissions.PermissionField["NONE"],
 		},
+		"claim": {
+			handler:  cmdClaim,
+			minArgs:  1,
+			usage:    "Usage: /claim <id>",
+			desc:     "Claims a modcall ticket.",
+			reqPerms: permissions.PermissionField["KICK"],
+		},
 		"cm": {
 			handler:  cmdCM,
 			minArgs:  0,
@@ -118,6 +162,13 @@ func initCommands() {
 			desc:     "Changes the reason of ban(s).",
 			reqPerms: permissions.PermissionField["BAN"],
 		},
+		"editrole": {
+			handler:  cmdEditRole,
+			minArgs:  2,
+			usage:    "Usage: /editrole <role> <permission1>,<permission2>... | /editrole -d <role>\n-d: Deletes the role. Users with a deleted role keep their last permissions.",
+			desc:     "Creates or edits a moderator role.",
+			reqPerms: permissions.PermissionField["ADMIN"],
+		},
 		"evimode": {
 			handler:  cmdSetEviMod,
 			minArgs:  1,
@@ -125,6 +176,13 @@ func initCommands() {
 			desc:     "Sets the area's evidence mode.",
 			reqPerms: permissions.PermissionField["CM"],
 		},
+		"filter": {
+			handler:  cmdFilter,
+			minArgs:  1,
+			usage:    "Usage: /filter add [-r] [-a action] <pattern> | /filter remove <id> | /filter list\n-r: Treat the pattern as a regular expression.\n-a: Action to take on a match: notify, censor, block, warn, mute or kick. Defaults to censor.",
+			desc:     "Manages the chat filter.",
+			reqPerms: permissions.PermissionField["MUTE"],
+		},
 		"forcebglist": {
 			handler:  cmdForceBGList,
 			minArgs:  1,
@@ -202,10 +260,17 @@ func initCommands() {
 			desc:     "Prints an area's log buffer.",
 			reqPerms: permissions.PermissionField["LOG"],
 		},
+		"link": {
+			handler:  cmdLink,
+			minArgs:  0,
+			usage:    "Usage: /link [-p]\n-p: Link this player rather than your moderator account.",
+			desc:     "Gives a code to link your Discord account with /link on Discord.",
+			reqPerms: permissions.PermissionField["NONE"],
+		},
 		"login": {
 			handler:  cmdLogin,
-			minArgs:  2,
-			usage:    "Usage: /login <username> <password>",
+			minArgs:  1,
+			usage:    "Usage: /login <username> [password] [code]\npassword: Leave out, or use -d, to approve the login on Discord, if your account is linked.\ncode: Two-factor code, if enabled for the account.",
 			desc:     "Logs in as moderator.",
 			reqPerms: permissions.PermissionField["NONE"],
 		},
@@ -237,6 +302,20 @@ func initCommands() {
 			desc:     "Sends a message to other moderators.",
 			reqPerms: permissions.PermissionField["MOD_CHAT"],
 		},
+		"modcalls": {
+			handler:  cmdModcalls,
+			minArgs:  0,
+			usage:    "Usage: /modcalls",
+			desc:     "Lists open modcall tickets.",
+			reqPerms: permissions.PermissionField["KICK"],
+		},
+		"modstats": {
+			handler:  cmdModStats,
+			minArgs:  0,
+			usage:    "Usage: /modstats [username] [period]\nperiod: How far back to look, e.g. 1d, 2w. Defaults to 1w.",
+			desc:     "Summarises moderator activity.",
+			reqPerms: permissions.PermissionField["ADMIN"],
+		},
 		"motd": {
 			handler:  cmdMotd,
 			minArgs:  0,
@@ -272,12 +351,33 @@ func initCommands() {
 			desc:     "Toggles non-interrupting preanims in the current area on or off.",
 			reqPerms: permissions.PermissionField["MODIFY_AREA"],
 		},
+		"note": {
+			handler:  cmdNote,
+			minArgs:  2,
+			usage:    "Usage: /note add <uid|ipid> <text> | /note list <uid|ipid>",
+			desc:     "Adds or lists moderator notes on a player.",
+			reqPerms: permissions.PermissionField["BAN_INFO"],
+		},
 		"parrot": {
 			handler:  cmdParrot,
 			minArgs:  1,
 			usage:    "Usage: /parrot [-d duration][-r reason] <uid1>,<uid2>...",
 			desc:     "Parrots user(s).",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
+		},
+		"passwd": {
+			handler:  cmdPasswd,
+			minArgs:  2,
+			usage:    "Usage: /passwd <old password> <new password>",
+			desc:     "Changes your account's password.",
+			reqPerms: permissions.PermissionField["NONE"],
+		},
+		"pending": {
+			handler:  cmdPending,
+			minArgs:  0,
+			usage:    "Usage: /pending",
+			desc:     "Lists moderator actions awaiting approval.",
+			reqPerms: permissions.PermissionField["BAN"],
 		},
 		"play": {
 			handler:  cmdPlay,
@@ -305,7 +405,7 @@ func initCommands() {
 			minArgs:  2,
 			usage:    "Usage: /forcepair <uid1> <uid2>",
 			desc:     "Forces two players to pair without requiring mutual consent.",
-			reqPerms: permissions.PermissionField["KICK"],
+			reqPerms: permissions.PermissionField["FORCE_PAIR"],
 		},
 		"unpair": {
 			handler:  cmdUnpair,
@@ -319,7 +419,7 @@ func initCommands() {
 			minArgs:  1,
 			usage:    "Usage: /forceunpair <uid>",
 			desc:     "Forces a player to unpair from their current pair.",
-			reqPerms: permissions.PermissionField["KICK"],
+			reqPerms: permissions.PermissionField["FORCE_PAIR"],
 		},
 		"pm": {
 			handler:  cmdPM,
@@ -340,21 +440,21 @@ func initCommands() {
 			minArgs:  2,
 			usage:    "Usage: /possess <uid> <message>",
 			desc:     "Makes target say a message once, copying their appearance.",
-			reqPerms: permissions.PermissionField["ADMIN"],
+			reqPerms: permissions.PermissionField["POSSESS"],
 		},
 		"fullpossess": {
 			handler:  cmdFullPossess,
 			minArgs:  1,
 			usage:    "Usage: /fullpossess <uid>",
 			desc:     "Makes all YOUR IC messages appear as the target until /unpossess.",
-			reqPerms: permissions.PermissionField["ADMIN"],
+			reqPerms: permissions.PermissionField["POSSESS"],
 		},
 		"unpossess": {
 			handler:  cmdUnpossess,
 			minArgs:  0,
 			usage:    "Usage: /unpossess",
 			desc:     "Stops full possession of a player.",
-			reqPerms: permissions.PermissionField["ADMIN"],
+			reqPerms: permissions.PermissionField["POSSESS"],
 		},
 		"poll": {
 			handler:  cmdPoll,
@@ -363,6 +463,13 @@ func initCommands() {
 			desc:     "Creates a poll in the current area.",
 			reqPerms: permissions.PermissionField["CM"],
 		},
+		"resolve": {
+			handler:  cmdResolve,
+			minArgs:  2,
+			usage:    "Usage: /resolve <id> <note>",
+			desc:     "Resolves a modcall ticket with a note on what was done.",
+			reqPerms: permissions.PermissionField["KICK"],
+		},
 		"rmusr": {
 			handler:  cmdRemoveUser,
 			minArgs:  1,
@@ -370,6 +477,13 @@ func initCommands() {
 			desc:     "Removes a moderator user.",
 			reqPerms: permissions.PermissionField["ADMIN"],
 		},
+		"roles": {
+			handler:  cmdRoles,
+			minArgs:  0,
+			usage:    "Usage: /roles",
+			desc:     "Lists the server's moderator roles and their permissions.",
+			reqPerms: permissions.PermissionField["ADMIN"],
+		},
 		"roll": {
 			handler:  cmdRoll,
 			minArgs:  1,
@@ -384,6 +498,20 @@ func initCommands() {
 			desc:     "Selects a random free character.",
 			reqPerms: permissions.PermissionField["NONE"],
 		},
+		"search": {
+			handler:  cmdSearch,
+			minArgs:  1,
+			usage:    "Usage: /search [-a area] [-p period] [-b before] [-n count] <query>\n-a: Only search this area.\n-p: How far back to look, e.g. 1d.\n-b: Only show messages older than this, e.g. 2h.\n-n: Number of messages to show (max 25).",
+			desc:     "Searches archived IC and OOC messages.",
+			reqPerms: permissions.PermissionField["LOG"],
+		},
+		"transcript": {
+			handler:  cmdTranscript,
+			minArgs:  0,
+			usage:    "Usage: /transcript [-f html|md] [-u] [from] [to]\nfrom, to: A time of day such as 18:30 (UTC), or how long ago, such as 2h.\n-f: File format, HTML or Markdown.\n-u: Also upload the transcript to the Discord webhook.",
+			desc:     "Saves the area's IC history as a readable transcript.",
+			reqPerms: permissions.PermissionField["CM"],
+		},
 		"rps": {
 			handler:  cmdRps,
 			minArgs:  1,
@@ -488,91 +616,91 @@ func initCommands() {
 			minArgs:  1,
 			usage:    "Usage: /whisper [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Makes messages only visible to mods and CMs.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"backward": {
 			handler:  cmdBackward,
 			minArgs:  1,
 			usage:    "Usage: /backward [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Reverses character order in messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"stutterstep": {
 			handler:  cmdStutterstep,
 			minArgs:  1,
 			usage:    "Usage: /stutterstep [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Doubles every word in messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"elongate": {
 			handler:  cmdElongate,
 			minArgs:  1,
 			usage:    "Usage: /elongate [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Repeats vowels in messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"uppercase": {
 			handler:  cmdUppercase,
 			minArgs:  1,
 			usage:    "Usage: /uppercase [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Forces messages to UPPERCASE.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"lowercase": {
 			handler:  cmdLowercase,
 			minArgs:  1,
 			usage:    "Usage: /lowercase [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Forces messages to lowercase.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"robotic": {
 			handler:  cmdRobotic,
 			minArgs:  1,
 			usage:    "Usage: /robotic [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Replaces messages with [BEEP] [BOOP] robotic sounds.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"alternating": {
 			handler:  cmdAlternating,
 			minArgs:  1,
 			usage:    "Usage: /alternating [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Makes messages AlTeRnAtInG cAsE.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"fancy": {
 			handler:  cmdFancy,
 			minArgs:  1,
 			usage:    "Usage: /fancy [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Converts messages to Unicode fancy characters.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"uwu": {
 			handler:  cmdUwu,
 			minArgs:  1,
 			usage:    "Usage: /uwu [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Converts messages to UwU speak.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"pirate": {
 			handler:  cmdPirate,
 			minArgs:  1,
 			usage:    "Usage: /pirate [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Converts messages to pirate speech.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"shakespearean": {
 			handler:  cmdShakespearean,
 			minArgs:  1,
 			usage:    "Usage: /shakespearean [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Converts messages to Shakespearean English.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"caveman": {
 			handler:  cmdCaveman,
 			minArgs:  1,
 			usage:    "Usage: /caveman [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Converts messages to caveman grunts.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		// Punishment commands - Visibility/Cosmetic
 		"emoji": {
@@ -580,14 +708,14 @@ func initCommands() {
 			minArgs:  1,
 			usage:    "Usage: /emoji [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Replaces name with random emojis.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"invisible": {
 			handler:  cmdInvisible,
 			minArgs:  1,
 			usage:    "Usage: /invisible [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Prevents user from seeing other players' messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		// Punishment commands - Timing Effects
 		"slowpoke": {
@@ -595,28 +723,28 @@ func initCommands() {
 			minArgs:  1,
 			usage:    "Usage: /slowpoke [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Delays messages before sending.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"fastspammer": {
 			handler:  cmdFastspammer,
 			minArgs:  1,
 			usage:    "Usage: /fastspammer [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Rate limits messages heavily.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"pause": {
 			handler:  cmdPause,
 			minArgs:  1,
 			usage:    "Usage: /pause [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Forces wait between messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"lag": {
 			handler:  cmdLag,
 			minArgs:  1,
 			usage:    "Usage: /lag [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Batches and delays messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		// Punishment commands - Social Chaos
 		"subtitles": {
@@ -624,21 +752,21 @@ func initCommands() {
 			minArgs:  1,
 			usage:    "Usage: /subtitles [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Adds confusing subtitles to messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"roulette": {
 			handler:  cmdRoulette,
 			minArgs:  1,
 			usage:    "Usage: /roulette [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Random chance message doesn't send.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"spotlight": {
 			handler:  cmdSpotlight,
 			minArgs:  1,
 			usage:    "Usage: /spotlight [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Announces all actions publicly.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		// Punishment commands - Text Processing
 		"censor": {
@@ -646,49 +774,49 @@ func initCommands() {
 			minArgs:  1,
 			usage:    "Usage: /censor [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Replaces words with [CENSORED].",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"confused": {
 			handler:  cmdConfused,
 			minArgs:  1,
 			usage:    "Usage: /confused [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Randomly reorders words in messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"paranoid": {
 			handler:  cmdParanoid,
 			minArgs:  1,
 			usage:    "Usage: /paranoid [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Adds paranoid text to messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"drunk": {
 			handler:  cmdDrunk,
 			minArgs:  1,
 			usage:    "Usage: /drunk [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Slurs and repeats words in messages.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"hiccup": {
 			handler:  cmdHiccup,
 			minArgs:  1,
 			usage:    "Usage: /hiccup [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Interrupts words with 'hic'.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"whistle": {
 			handler:  cmdWhistle,
 			minArgs:  1,
 			usage:    "Usage: /whistle [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Replaces letters with whistles.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"mumble": {
 			handler:  cmdMumble,
 			minArgs:  1,
 			usage:    "Usage: /mumble [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Obscures message text.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		// Punishment commands - Complex Effects
 		"spaghetti": {
@@ -696,28 +824,28 @@ func initCommands() {
 			minArgs:  1,
 			usage:    "Usage: /spaghetti [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Combines multiple random effects.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"torment": {
 			handler:  cmdTorment,
 			minArgs:  1,
 			usage:    "Usage: /torment [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Cycles through different effects.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"rng": {
 			handler:  cmdRng,
 			minArgs:  1,
 			usage:    "Usage: /rng [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Applies random effect from pool each message.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"essay": {
 			handler:  cmdEssay,
 			minArgs:  1,
 			usage:    "Usage: /essay [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Requires minimum 50 characters.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		// Punishment commands - Advanced
 		"haiku": {
@@ -725,35 +853,35 @@ func initCommands() {
 			minArgs:  1,
 			usage:    "Usage: /haiku [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Requires 5-7-5 syllable format.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"autospell": {
 			handler:  cmdAutospell,
 			minArgs:  1,
 			usage:    "Usage: /autospell [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Autocorrects to wrong words.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"unpunish": {
 			handler:  cmdUnpunish,
 			minArgs:  1,
 			usage:    "Usage: /unpunish [-t punishment_type] <uid1>,<uid2>...\n-t: Specific punishment type to remove (omit to remove all).",
 			desc:     "Removes punishment(s) from user(s).",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"stack": {
 			handler:  cmdStack,
 			minArgs:  2,
 			usage:    "Usage: /stack <punishment1> <punishment2> [<punishment3>...] [-d duration] [-r reason] <uid1>,<uid2>...",
 			desc:     "Applies multiple punishment effects to user(s) simultaneously.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["PUNISH"],
 		},
 		"tournament": {
 			handler:  cmdTournament,
 			minArgs:  1,
 			usage:    "Usage: /tournament <start|stop|status>",
 			desc:     "Manages punishment tournament mode.",
-			reqPerms: permissions.PermissionField["MUTE"],
+			reqPerms: permissions.PermissionField["EVENTS"],
 		},
 		"join-tournament": {
 			handler:  cmdJoinTournament,
@@ -777,6 +905,44 @@ func initCommands() {
 			reqPerms: permissions.PermissionField["NONE"],
 		},
 	}
+	applyCommandOverrides()
+}
+
+// applyCommandOverrides replaces the required permissions of commands listed in the command permission file.
+func applyCommandOverrides() {
+	overrides, err := settings.LoadCommandPerms()
+	if err != nil {
+		logger.LogErrorf("Failed to read command permissions: %v", err)
+		return
+	}
+	for name, perms := range overrides {
+		cmd, ok := Commands[name]
+		_, isSubcommand := subcommandPerms[name]
+		if !ok && !isSubcommand {
+			logger.LogWarningf("Command permissions: unknown command %v.", name)
+			continue
+		}
+		var required uint64
+		valid := true
+		for _, p := range perms {
+			bit, ok := permissions.PermissionField[strings.ToUpper(p)]
+			if !ok {
+				logger.LogWarningf("Command permissions: unknown permission %v for command %v.", p, name)
+				valid = false
+				break
+			}
+			required |= bit
+		}
+		if !valid {
+			continue
+		}
+		if isSubcommand {
+			subcommandPerms[name] = required
+			continue
+		}
+		cmd.reqPerms = required
+		Commands[name] = cmd
+	}
 }
 
 // ParseCommand calls the appropriate function for a given command.
@@ -805,7 +971,9 @@ func ParseCommand(client *Client, command string, args []string) {
 			client.SendServerMessage("Not enough arguments.\n" + cmd.usage)
 			return
 		}
+		client.SetRunningCommand(command)
 		cmd.handler(client, args, cmd.usage)
+		client.SetRunningCommand("")
 	} else {
 		client.SendServerMessage("You do not have permission to use that command.")
 		return
@@ -853,6 +1021,13 @@ func cmdAllowIniswap(client *Client, args []string, _ string) {
 	addToBuffer(client, "CMD", fmt.Sprintf("Set iniswapping to %v.", args[0]), false)
 }
 
+// Handles /announce
+func cmdAnnounce(client *Client, args []string, _ string) {
+	msg := strings.Join(args, " ")
+	sendGlobalServerMessage("[Announcement] " + msg)
+	addToBuffer(client, "CMD", fmt.Sprintf("Sent announcement: %v", msg), true)
+}
+
 // Handles /areainfo
 func cmdAreaInfo(client *Client, _ []string, _ string) {
 	out := fmt.Sprintf("\nBG: %v\nEvi mode: %v\nAllow iniswap: %v\nNon-interrupting pres: %v\nCMs allowed: %v\nForce BG list: %v\nBG locked: %v\nMusic locked: %v",
@@ -895,6 +1070,11 @@ func cmdBan(client *Client, args []string, usage string) {
 		until = time.Now().UTC().Add(parsedDur).Unix()
 	}
 
+	if until == -1 && !permissions.HasPermission(client.Perms(), permissions.PermissionField["BAN_PERMA"]) {
+		requestPermaBan(client, *uids, *ipids, reason)
+		return
+	}
+
 	var untilS string
 	if until == -1 {
 		untilS = "∞"
@@ -963,7 +1143,12 @@ func cmdBan(client *Client, args []string, usage string) {
 		client.SendServerMessage(fmt.Sprintf("Banned %v clients.", count))
 	}
 	sendPlayerArup()
-	addToBuffer(client, "CMD", fmt.Sprintf("Banned %v from server for %v: %v.", report, *duration, reason), true)
+	addAuditToBuffer(client, "CMD", db.ActivityBan, fmt.Sprintf("Banned %v from server for %v: %v.", report, *duration, reason), report, reason,
+		map[string]string{"duration": *duration})
+	if count > 0 {
+		feedEvent(eventBan, fmt.Sprintf("%v banned %v player(s) for %v: %v", feedName(client.ModName()), count, *duration, feedName(reason)))
+		sendBanWebhook(client.ModName(), strings.Split(report, ", "), until, reason)
+	}
 }
 
 // Handles /bg
@@ -1316,7 +1501,10 @@ func cmdKick(client *Client, args []string, usage string) {
 	report = strings.TrimSuffix(report, ", ")
 	client.SendServerMessage(fmt.Sprintf("Kicked %v clients.", count))
 	sendPlayerArup()
-	addToBuffer(client, "CMD", fmt.Sprintf("Kicked %v from server for reason: %v.", report, reason), true)
+	addAuditToBuffer(client, "CMD", db.ActivityKick, fmt.Sprintf("Kicked %v from server for reason: %v.", report, reason), report, reason, nil)
+	if count > 0 {
+		feedEvent(eventKick, fmt.Sprintf("%v kicked %v: %v", feedName(client.ModName()), report, feedName(reason)))
+	}
 }
 
 // Handles /kickarea
@@ -1371,6 +1559,7 @@ func cmdLock(client *Client, args []string, _ string) {
 		}
 	}
 	sendLockArup()
+	feedEvent(eventArea, fmt.Sprintf("%v set %v to %v.", feedName(client.OOCName()), client.Area().Name(), strings.ToLower(client.Area().Lock().String())))
 }
 
 // Handles /lockbg
@@ -1431,22 +1620,68 @@ func cmdLogin(client *Client, args []string, _ string) {
 		client.SendServerMessage("You are already logged in.")
 		return
 	}
-	auth, perms := db.AuthenticateUser(args[0], []byte(args[1]))
-	addToBuffer(client, "AUTH", fmt.Sprintf("Attempted login as %v.", args[0]), true)
-	if auth {
-		client.SetAuthenticated(true)
-		client.SetPerms(perms)
-		client.SetModName(args[0])
-		if permissions.IsModerator(perms) {
-			client.SendServerMessage("Logged in as moderator.")
+	username := args[0]
+	if wait, locked := loginLimits.locked(client.Ipid(), username, time.Now()); locked {
+		client.SendPacket("AUTH", "0")
+		client.SendServerMessage(fmt.Sprintf("Too many failed login attempts. Try again in %v.", wait.Round(time.Second)))
+		addToBuffer(client, "AUTH", fmt.Sprintf("Rejected login as %v while locked out.", username), true)
+		return
+	}
+	if len(args) == 1 || args[1] == "-d" {
+		var code string
+		if len(args) > 2 {
+			code = args[2]
 		}
-		client.SendPacket("AUTH", "1")
-		client.SendServerMessage(fmt.Sprintf("Welcome, %v.", args[0]))
-		addToBuffer(client, "AUTH", fmt.Sprintf("Logged in as %v.", args[0]), true)
+		requestDiscordLogin(client, username, code)
+		return
+	}
+	auth, roleName, perms := db.AuthenticateUser(username, []byte(args[1]))
+	addToBuffer(client, "AUTH", fmt.Sprintf("Attempted login as %v.", username), true)
+	if !auth {
+		loginFailed(client, username, fmt.Sprintf("Failed login as %v.", username))
 		return
 	}
-	client.SendPacket("AUTH", "0")
-	addToBuffer(client, "AUTH", fmt.Sprintf("Failed login as %v.", args[0]), true)
+	secret, err := db.GetTOTPSecret(username)
+	if err != nil {
+		logger.LogErrorf("Failed to get two-factor secret of %v: %v", username, err)
+		client.SendPacket("AUTH", "0")
+		return
+	}
+	if secret != "" {
+		if len(args) < 3 {
+			client.SendServerMessage("This account requires a two-factor code: /login <username> <password> <code>")
+			loginFailed(client, username, fmt.Sprintf("Failed login as %v: missing two-factor code.", username))
+			return
+		}
+		if !checkTOTP(username, secret, args[2]) {
+			client.SendServerMessage("Invalid two-factor code.")
+			loginFailed(client, username, fmt.Sprintf("Failed login as %v: invalid two-factor code.", username))
+			return
+		}
+	}
+	completeLogin(client, username, roleName, perms, "")
+}
+
+// completeLogin logs a client in once their credentials have been checked.
+// via names how they logged in, if not with a password.
+func completeLogin(client *Client, username string, roleName string, perms uint64, via string) {
+	loginLimits.reset(client.Ipid(), username)
+	roleName, perms = resolveUserRole(username, roleName, perms)
+	client.SetAuthenticated(true)
+	client.SetPerms(perms)
+	client.SetModName(username)
+	client.SetModRole(roleName)
+	if permissions.IsModerator(perms) {
+		client.SendServerMessage("Logged in as moderator.")
+	}
+	client.SendPacket("AUTH", "1")
+	client.SendServerMessage(fmt.Sprintf("Welcome, %v.", username))
+	msg := fmt.Sprintf("Logged in as %v.", username)
+	if via != "" {
+		msg = fmt.Sprintf("Logged in as %v with %v.", username, via)
+	}
+	addToBuffer(client, "AUTH", msg, true)
+	recordModActivity(client, db.ActivityLogin, via)
 }
 
 // Handles /logout
@@ -1455,6 +1690,9 @@ func cmdLogout(client *Client, _ []string, _ string) {
 		client.SendServerMessage("You are not logged in.")
 	}
 	addToBuffer(client, "AUTH", fmt.Sprintf("Logged out as %v.", client.ModName()), true)
+	if client.Authenticated() {
+		recordModActivity(client, db.ActivityLogout, "")
+	}
 	client.RemoveAuth()
 }
 
@@ -1470,7 +1708,7 @@ func cmdMakeUser(client *Client, args []string, _ string) {
 		client.SendServerMessage("Invalid role.")
 		return
 	}
-	err = db.CreateUser(args[0], []byte(args[1]), role.GetPermissions())
+	err = db.CreateUser(args[0], []byte(args[1]), role.Name, role.GetPermissions())
 	if err != nil {
 		logger.LogError(err.Error())
 		client.SendServerMessage("Invalid username/password.")
@@ -1665,7 +1903,10 @@ func cmdMute(client *Client, args []string, usage string) {
 	}
 	report = strings.TrimSuffix(report, ", ")
 	client.SendServerMessage(fmt.Sprintf("Muted %v clients.", count))
-	addToBuffer(client, "CMD", fmt.Sprintf("Muted %v.", report), false)
+	addAuditToBuffer(client, "CMD", db.ActivityMute, fmt.Sprintf("Muted %v.", report), report, *reason, map[string]string{"duration": strconv.Itoa(*duration)})
+	if count > 0 {
+		feedEvent(eventMute, fmt.Sprintf("%v muted UID %v: %v", feedName(client.ModName()), report, feedName(*reason)))
+	}
 }
 
 // Handles /narrator
@@ -2233,7 +2474,7 @@ func cmdChangeRole(client *Client, args []string, _ string) {
 		return
 	}
 
-	err = db.ChangePermissions(args[0], role.GetPermissions())
+	err = db.ChangeRole(args[0], role.Name, role.GetPermissions())
 	if err != nil {
 		client.SendServerMessage("Failed to change permissions.")
 		logger.LogError(err.Error())
@@ -2244,6 +2485,7 @@ func cmdChangeRole(client *Client, args []string, _ string) {
 	for c := range clients.GetAllClients() {
 		if c.Authenticated() && c.ModName() == args[0] {
 			c.SetPerms(role.GetPermissions())
+			c.SetModRole(role.Name)
 		}
 	}
 	addToBuffer(client, "CMD", fmt.Sprintf("Updated role of %v to %v.", args[0], args[1]), true)
@@ -2271,6 +2513,7 @@ func cmdStatus(client *Client, args []string, _ string) {
 	sendAreaServerMessage(client.Area(), fmt.Sprintf("%v set the status to %v.", client.OOCName(), args[0]))
 	sendStatusArup()
 	addToBuffer(client, "CMD", fmt.Sprintf("Set the status to %v.", args[0]), false)
+	feedEvent(eventArea, fmt.Sprintf("%v set the status of %v to %v.", feedName(client.OOCName()), client.Area().Name(), strings.ToLower(args[0])))
 }
 
 // Handles swapevi
@@ -2447,6 +2690,7 @@ func cmdUnlock(client *Client, _ []string, _ string) {
 	sendLockArup()
 	sendAreaServerMessage(client.Area(), fmt.Sprintf("%v unlocked the area.", client.OOCName()))
 	addToBuffer(client, "CMD", "Unlocked the area.", false)
+	feedEvent(eventArea, fmt.Sprintf("%v unlocked %v.", feedName(client.OOCName()), client.Area().Name()))
 }
 
 // Handles /unmute
@@ -2465,7 +2709,7 @@ func cmdUnmute(client *Client, args []string, _ string) {
 	}
 	report = strings.TrimSuffix(report, ", ")
 	client.SendServerMessage(fmt.Sprintf("Unmuted %v clients.", count))
-	addToBuffer(client, "CMD", fmt.Sprintf("Unmuted %v.", report), false)
+	addAuditToBuffer(client, "CMD", db.ActivityAction, fmt.Sprintf("Unmuted %v.", report), report, "", nil)
 }
 
 // Handles /jail
@@ -2862,6 +3106,9 @@ func cmdPunishment(client *Client, args []string, usage string, pType Punishment
 	report = strings.TrimSuffix(report, ", ")
 	client.SendServerMessage(fmt.Sprintf("Applied '%v' punishment to %v clients.", pType.String(), count))
 	addToBuffer(client, "CMD", fmt.Sprintf("Applied '%v' punishment to %v.", pType.String(), report), false)
+	if count > 0 {
+		feedEvent(eventPunish, fmt.Sprintf("%v gave UID %v the '%v' punishment.", feedName(client.ModName()), report, pType.String()))
+	}
 }
 
 // Handlers for all punishment commands
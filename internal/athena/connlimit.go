@@ -0,0 +1,107 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+)
+
+var errPacketTooLarge = errors.New("packet exceeds maximum size")
+
+// connLimiter tracks connection attempts per IPID and the number of connections that have not finished joining.
+type connLimiter struct {
+	mu        sync.Mutex
+	attempts  map[string][]time.Time
+	pending   int
+	lastSweep time.Time
+}
+
+var connLimits = connLimiter{attempts: make(map[string][]time.Time)}
+
+// allow records a connection attempt from ipid, and reports whether it is within the connection rate limit.
+func (l *connLimiter) allow(ipid string, limit int, window time.Duration, now time.Time) bool {
+	if limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := now.Add(-window)
+	if now.Sub(l.lastSweep) > window {
+		for k, times := range l.attempts {
+			if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+				delete(l.attempts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	times := l.attempts[ipid]
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	times = append(times[i:], now)
+	l.attempts[ipid] = times
+	return len(times) <= limit
+}
+
+// acquirePending reserves a slot for an unjoined connection, reporting whether one was available.
+func (l *connLimiter) acquirePending(max int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if max > 0 && l.pending >= max {
+		return false
+	}
+	l.pending++
+	return true
+}
+
+// releasePending frees a slot reserved with acquirePending.
+func (l *connLimiter) releasePending() {
+	l.mu.Lock()
+	if l.pending > 0 {
+		l.pending--
+	}
+	l.mu.Unlock()
+}
+
+// splitPacket returns a split function that splits input on the packet delimiter ('%'),
+// failing with errPacketTooLarge if more than max bytes arrive without one.
+func splitPacket(max int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.IndexByte(data, '%'); i >= 0 {
+			if max > 0 && i > max {
+				return 0, nil, errPacketTooLarge
+			}
+			return i + 1, data[:i], nil
+		}
+		if max > 0 && len(data) > max {
+			return 0, nil, errPacketTooLarge
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
@@ -0,0 +1,84 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestConnRateLimit tests that connections are limited per IPID within the window
+func TestConnRateLimit(t *testing.T) {
+	l := connLimiter{attempts: make(map[string][]time.Time)}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !l.allow("a", 3, 10*time.Second, now) {
+			t.Fatalf("Connection %d was rejected (limit is 3)", i+1)
+		}
+	}
+	if l.allow("a", 3, 10*time.Second, now) {
+		t.Errorf("4th connection was not rejected")
+	}
+	if !l.allow("b", 3, 10*time.Second, now) {
+		t.Errorf("Connection from a different IPID was rejected")
+	}
+	if !l.allow("a", 3, 10*time.Second, now.Add(11*time.Second)) {
+		t.Errorf("Connection was rejected after the window expired")
+	}
+	if !l.allow("a", 0, 10*time.Second, now) {
+		t.Errorf("Connection was rejected with rate limiting disabled")
+	}
+}
+
+// TestPendingConnections tests the cap on unjoined connections
+func TestPendingConnections(t *testing.T) {
+	l := connLimiter{attempts: make(map[string][]time.Time)}
+	if !l.acquirePending(2) || !l.acquirePending(2) {
+		t.Fatalf("Failed to acquire pending slots under the cap")
+	}
+	if l.acquirePending(2) {
+		t.Errorf("Acquired a pending slot over the cap")
+	}
+	l.releasePending()
+	if !l.acquirePending(2) {
+		t.Errorf("Failed to acquire a slot after one was released")
+	}
+}
+
+// TestSplitPacketMaxSize tests that oversized packets stop the scanner
+func TestSplitPacketMaxSize(t *testing.T) {
+	input := bufio.NewScanner(strings.NewReader("HI#abc#%CT#name#msg#%"))
+	input.Split(splitPacket(16))
+	var got []string
+	for input.Scan() {
+		got = append(got, input.Text())
+	}
+	if len(got) != 2 || got[0] != "HI#abc#" || input.Err() != nil {
+		t.Errorf("Unexpected split result %q, err %v", got, input.Err())
+	}
+
+	input = bufio.NewScanner(strings.NewReader("HI#abc#%" + strings.Repeat("A", 100)))
+	input.Split(splitPacket(16))
+	for input.Scan() {
+	}
+	if input.Err() != errPacketTooLarge {
+		t.Errorf("Expected errPacketTooLarge for a delimiterless packet, got %v", input.Err())
+	}
+}
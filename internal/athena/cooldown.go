@@ -0,0 +1,47 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+)
+
+// areaCooldown limits how often something can happen in each area.
+type areaCooldown struct {
+	mu     sync.Mutex
+	period time.Duration
+	last   map[*area.Area]time.Time
+}
+
+func newAreaCooldown(period time.Duration) *areaCooldown {
+	return &areaCooldown{period: period, last: make(map[*area.Area]time.Time)}
+}
+
+// start reports how long an area must still wait before it can go again.
+// If it doesn't need to wait, the area's cooldown starts now.
+func (c *areaCooldown) start(a *area.Area, now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if remaining := c.last[a].Add(c.period).Sub(now); remaining > 0 {
+		return remaining
+	}
+	c.last[a] = now
+	return 0
+}
@@ -0,0 +1,41 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAreaCooldown tests that each area has to wait for its own cooldown
+func TestAreaCooldown(t *testing.T) {
+	c := newAreaCooldown(2 * time.Minute)
+	a, b := makeTestArea("A"), makeTestArea("B")
+	now := time.Now()
+	if wait := c.start(a, now); wait != 0 {
+		t.Fatalf("First start had to wait %v", wait)
+	}
+	if wait := c.start(a, now.Add(time.Minute)); wait != time.Minute {
+		t.Errorf("start() during the cooldown = %v, want %v", wait, time.Minute)
+	}
+	if wait := c.start(b, now); wait != 0 {
+		t.Errorf("Another area had to wait %v", wait)
+	}
+	if wait := c.start(a, now.Add(2*time.Minute)); wait != 0 {
+		t.Errorf("start() after the cooldown = %v, want 0", wait)
+	}
+}
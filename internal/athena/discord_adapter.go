@@ -17,9 +17,7 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>. */
 package athena
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -37,6 +35,19 @@ var (
 	warnings   = make(map[string][]bot.WarnRecord)
 )
 
+// writeDiscordAudit records an action taken by a moderator through Discord in the audit log.
+func writeDiscordAudit(moderator string, action string, target string, reason string, message string) {
+	logger.WriteAudit(logger.AuditRecord{
+		Time:     time.Now().UTC(),
+		Actor:    moderator,
+		Target:   target,
+		Action:   action,
+		Reason:   reason,
+		Message:  message,
+		Metadata: map[string]string{"source": "discord"},
+	})
+}
+
 // ServerAdapter implements bot.ServerInterface, bridging Discord bot commands to the AO2 server.
 type ServerAdapter struct{}
 
@@ -137,6 +148,7 @@ func (a *ServerAdapter) GetAreas() []bot.AreaInfo {
 			PlayerCount: ar.PlayerCount(),
 			Status:      ar.Status().String(),
 			Lock:        ar.Lock().String(),
+			CMs:         areaCMs(ar),
 		}
 	}
 	return result
@@ -160,7 +172,7 @@ func (a *ServerAdapter) FindArea(name string) *bot.AreaInfo {
 }
 
 // MutePlayer mutes a player by UID.
-func (a *ServerAdapter) MutePlayer(uid int, duration time.Duration, reason string) error {
+func (a *ServerAdapter) MutePlayer(uid int, duration time.Duration, reason string, moderator string) error {
 	c, err := getClientByUid(uid)
 	if err != nil {
 		return fmt.Errorf("player not found: UID %d", uid)
@@ -172,6 +184,7 @@ func (a *ServerAdapter) MutePlayer(uid int, duration time.Duration, reason strin
 		c.SetUnmuteTime(time.Time{})
 	}
 	c.SendServerMessage(fmt.Sprintf("You have been muted. Reason: %s", reason))
+	feedEvent(eventMute, fmt.Sprintf("%v muted UID %v on Discord: %v", feedName(moderator), uid, feedName(reason)))
 	return nil
 }
 
@@ -187,16 +200,32 @@ func (a *ServerAdapter) UnmutePlayer(uid int) error {
 }
 
 // KickPlayer kicks a player by UID.
-func (a *ServerAdapter) KickPlayer(uid int, reason string) error {
+func (a *ServerAdapter) KickPlayer(uid int, reason string, moderator string) error {
 	c, err := getClientByUid(uid)
 	if err != nil {
 		return fmt.Errorf("player not found: UID %d", uid)
 	}
 	c.SendServerMessage(fmt.Sprintf("You have been kicked. Reason: %s", reason))
 	c.conn.Close()
+	feedEvent(eventKick, fmt.Sprintf("%v kicked UID %v on Discord: %v", feedName(moderator), uid, feedName(reason)))
 	return nil
 }
 
+// RequestPermaBan records a permanent ban of an IPID as a pending action that another moderator must approve.
+// The action is attributed to the moderator account linked to the Discord user, so the self-approval check in /approve applies.
+func (a *ServerAdapter) RequestPermaBan(discordID string, ipid string, reason string) (int, error) {
+	moderator := linkedAccount(discordID)
+	if moderator == "" {
+		return 0, fmt.Errorf("link your moderator account with /link to request permanent bans")
+	}
+	id, err := addPendingBan(collectBanTargets(nil, []string{ipid}), reason, moderator)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add pending action: %w", err)
+	}
+	writeDiscordAudit(moderator, "ban_request", ipid, reason, fmt.Sprintf("Requested permanent ban of %v (pending action %v).", ipid, id))
+	return id, nil
+}
+
 // BanPlayer bans a player by IPID.
 func (a *ServerAdapter) BanPlayer(ipid string, duration time.Duration, reason string, moderator string) error {
 	var durUnix int64
@@ -205,7 +234,7 @@ func (a *ServerAdapter) BanPlayer(ipid string, duration time.Duration, reason st
 	} else {
 		durUnix = time.Now().UTC().Add(duration).Unix()
 	}
-	_, err := db.AddBan(ipid, "", time.Now().UTC().Unix(), durUnix, reason, moderator)
+	id, err := db.AddBan(ipid, "", time.Now().UTC().Unix(), durUnix, reason, moderator)
 	if err != nil {
 		return fmt.Errorf("failed to add ban: %w", err)
 	}
@@ -214,7 +243,9 @@ func (a *ServerAdapter) BanPlayer(ipid string, duration time.Duration, reason st
 		c.SendServerMessage(fmt.Sprintf("You have been banned. Reason: %s", reason))
 		c.conn.Close()
 	}
-	logger.WriteAudit(fmt.Sprintf("%v | BAN | IPID:%v | %v | By: %v", time.Now().UTC().Format("15:04:05"), ipid, reason, moderator))
+	writeDiscordAudit(moderator, "ban", ipid, reason, fmt.Sprintf("Banned %v for %v.", ipid, duration))
+	feedEvent(eventBan, fmt.Sprintf("%v banned a player on Discord (ban ID %v): %v", feedName(moderator), id, feedName(reason)))
+	sendBanWebhook(moderator, []string{ipid}, durUnix, reason)
 	return nil
 }
 
@@ -257,7 +288,7 @@ func (a *ServerAdapter) WarnPlayer(uid int, reason string, moderator string) err
 	})
 	warningsMu.Unlock()
 	c.SendServerMessage(fmt.Sprintf("⚠️ Warning from moderator: %s", reason))
-	logger.WriteAudit(fmt.Sprintf("%v | WARN | IPID:%v | %v | By: %v", time.Now().UTC().Format("15:04:05"), c.Ipid(), reason, moderator))
+	writeDiscordAudit(moderator, "warn", c.Ipid(), reason, fmt.Sprintf("Warned %v.", c.Ipid()))
 	return nil
 }
 
@@ -289,13 +320,111 @@ func (a *ServerAdapter) GetBanList() []bot.BanRecord {
 	return result
 }
 
+// GetModcalls returns all unresolved modcall tickets.
+func (a *ServerAdapter) GetModcalls() []bot.ModcallRecord {
+	calls, err := db.GetOpenModcalls()
+	if err != nil {
+		return nil
+	}
+	result := make([]bot.ModcallRecord, len(calls))
+	for i, m := range calls {
+		result[i] = modcallRecord(m)
+	}
+	return result
+}
+
+// GetModcall returns a modcall ticket by ID, or nil if there is none.
+func (a *ServerAdapter) GetModcall(id int) *bot.ModcallRecord {
+	m, err := db.GetModcall(id)
+	if err != nil {
+		return nil
+	}
+	r := modcallRecord(m)
+	return &r
+}
+
+// modcallRecord converts a modcall ticket for the Discord bot.
+func modcallRecord(m db.Modcall) bot.ModcallRecord {
+	return bot.ModcallRecord{
+		ID:        m.Id,
+		Time:      m.Time,
+		Area:      m.Area,
+		CallerUID: m.CallerUid,
+		Caller:    m.Caller,
+		IPID:      m.Ipid,
+		Reason:    m.Reason,
+		Status:    m.Status,
+		ClaimedBy: m.ClaimedBy,
+	}
+}
+
+// ClaimModcall claims a modcall ticket for a Discord moderator.
+func (a *ServerAdapter) ClaimModcall(id int, moderator string) error {
+	m, err := claimModcall(id, moderator)
+	if err != nil {
+		return err
+	}
+	writeDiscordAudit(moderator, "claim", m.Ipid, "", fmt.Sprintf("Claimed modcall #%v from %v (%v).", id, m.Caller, m.Ipid))
+	return nil
+}
+
+// ResolveModcall resolves a modcall ticket for a Discord moderator.
+func (a *ServerAdapter) ResolveModcall(id int, moderator string, note string) error {
+	m, err := resolveModcall(id, moderator, note)
+	if err != nil {
+		return err
+	}
+	writeDiscordAudit(moderator, "resolve", m.Ipid, note, fmt.Sprintf("Resolved modcall #%v from %v (%v).", id, m.Caller, m.Ipid))
+	return nil
+}
+
+// GetNotes returns all moderator notes on an IPID.
+func (a *ServerAdapter) GetNotes(ipid string) []bot.NoteRecord {
+	notes, err := db.GetNotes(ipid)
+	if err != nil {
+		return nil
+	}
+	result := make([]bot.NoteRecord, len(notes))
+	for i, n := range notes {
+		result[i] = bot.NoteRecord{
+			ID:     n.Id,
+			Author: n.Author,
+			Text:   n.Text,
+			Time:   n.Time,
+		}
+	}
+	return result
+}
+
+// GetPendingActions returns all moderator actions awaiting approval.
+func (a *ServerAdapter) GetPendingActions() []bot.PendingActionRecord {
+	db.ExpirePendingActions()
+	actions, err := db.GetPendingActions()
+	if err != nil {
+		return nil
+	}
+	result := make([]bot.PendingActionRecord, len(actions))
+	for i, p := range actions {
+		result[i] = bot.PendingActionRecord{
+			ID:        p.Id,
+			Action:    p.Action,
+			Targets:   banTargetIpids(p.Targets),
+			Reason:    p.Reason,
+			Moderator: p.Moderator,
+			Created:   p.Created,
+			Expires:   p.Expires,
+		}
+	}
+	return result
+}
+
 // UnbanByID removes a ban by its ID.
 func (a *ServerAdapter) UnbanByID(id int) error {
 	return db.UnBan(id)
 }
 
 // ApplyPunishment applies a named punishment to a player.
-func (a *ServerAdapter) ApplyPunishment(uid int, punishmentName string, duration time.Duration) error {
+func (a *ServerAdapter) ApplyPunishment(uid int, punishmentName string, duration time.Duration, moderator string) error {
 	c, err := getClientByUid(uid)
 	if err != nil {
 		return fmt.Errorf("player not found: UID %d", uid)
@@ -307,6 +436,7 @@ func (a *ServerAdapter) ApplyPunishment(uid int, punishmentName string, duration
 	}
 	c.AddPunishment(pType, duration, "Applied by Discord moderator.")
 	c.SendServerMessage(fmt.Sprintf("You have received the '%s' punishment.", punishmentName))
+	feedEvent(eventPunish, fmt.Sprintf("%v gave UID %v the '%v' punishment on Discord.", feedName(moderator), uid, punishmentName))
 	return nil
 }
 
@@ -429,7 +559,7 @@ func (a *ServerAdapter) ClearArea(areaName string) error {
 }
 
 // LockArea locks a named area.
-func (a *ServerAdapter) LockArea(areaName string) error {
+func (a *ServerAdapter) LockArea(areaName string, moderator string) error {
 	for _, ar := range areas {
 		if strings.EqualFold(ar.Name(), areaName) {
 			ar.SetLock(area.LockLocked)
@@ -441,6 +571,7 @@ func (a *ServerAdapter) LockArea(areaName string) error {
 			}
 			sendAreaServerMessage(ar, fmt.Sprintf("%s was locked by a Discord moderator.", ar.Name()))
 			sendLockArup()
+			feedEvent(eventArea, fmt.Sprintf("%v locked %v on Discord.", feedName(moderator), ar.Name()))
 			return nil
 		}
 	}
@@ -448,7 +579,7 @@ func (a *ServerAdapter) LockArea(areaName string) error {
 }
 
 // UnlockArea unlocks a named area.
-func (a *ServerAdapter) UnlockArea(areaName string) error {
+func (a *ServerAdapter) UnlockArea(areaName string, moderator string) error {
 	for _, ar := range areas {
 		if strings.EqualFold(ar.Name(), areaName) {
 			if ar.Lock() == area.LockFree {
@@ -458,6 +589,7 @@ func (a *ServerAdapter) UnlockArea(areaName string) error {
 			ar.ClearInvited()
 			sendAreaServerMessage(ar, fmt.Sprintf("%s was unlocked by a Discord moderator.", ar.Name()))
 			sendLockArup()
+			feedEvent(eventArea, fmt.Sprintf("%v unlocked %v on Discord.", feedName(moderator), ar.Name()))
 			return nil
 		}
 	}
@@ -478,30 +610,46 @@ func (a *ServerAdapter) GetPlayerLogs(ipid string) []string {
 	return result
 }
 
-// GetAuditLog returns the last N lines of the audit log, optionally filtered by a search string.
-func (a *ServerAdapter) GetAuditLog(filter string) []string {
-	auditPath := logger.LogPath + "/audit.log"
-	f, err := os.Open(auditPath)
+// GetAuditLog returns the 50 most recent audit log entries matching a query.
+func (a *ServerAdapter) GetAuditLog(q bot.AuditQuery) []string {
+	query := logger.AuditQuery{
+		Actor:  q.Moderator,
+		Target: q.Target,
+		Action: q.Action,
+		Text:   q.Filter,
+		Limit:  50,
+	}
+	if q.Period > 0 {
+		query.Since = time.Now().Add(-q.Period)
+	}
+	records, err := logger.QueryAudit(query)
 	if err != nil {
+		logger.LogErrorf("Failed to read audit log: %v", err)
 		return nil
 	}
-	defer f.Close()
+	lines := make([]string, len(records))
+	for i, r := range records {
+		lines[i] = r.String()
+	}
+	return lines
+}
 
-	var lines []string
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if filter == "" || strings.Contains(strings.ToLower(line), strings.ToLower(filter)) {
-			lines = append(lines, line)
-		}
+// GetRolePermissions returns the permissions of a role from roles.toml.
+func (a *ServerAdapter) GetRolePermissions(name string) (uint64, bool) {
+	role, err := getRole(name)
+	if err != nil {
+		return 0, false
 	}
+	return role.GetPermissions(), true
+}
 
-	// Return the last 50 matching lines.
-	const maxLines = 50
-	if len(lines) > maxLines {
-		lines = lines[len(lines)-maxLines:]
+// CommandPerms returns the permissions required by an in-game command, including overrides from commands.toml.
+func (a *ServerAdapter) CommandPerms(name string) (uint64, bool) {
+	cmd, ok := Commands[name]
+	if !ok {
+		return 0, false
 	}
-	return lines
+	return cmd.reqPerms, true
 }
 
 // GetServerName returns the server's name.
@@ -518,3 +666,51 @@ func (a *ServerAdapter) GetPlayerCount() int {
 func (a *ServerAdapter) GetMaxPlayers() int {
 	return config.MaxPlayers
 }
+
+// GetModStats summarises moderator activity over a period.
+func (a *ServerAdapter) GetModStats(moderator string, period time.Duration) ([]bot.ModStatsRecord, error) {
+	stats, err := modStats(moderator, period)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]bot.ModStatsRecord, len(stats))
+	for i, s := range stats {
+		result[i] = bot.ModStatsRecord{
+			Moderator: s.Moderator,
+			Online:    time.Duration(s.Online) * time.Second,
+			Logins:    s.Logins,
+			Bans:      s.Bans,
+			Kicks:     s.Kicks,
+			Mutes:     s.Mutes,
+			Modcalls:  s.Modcalls,
+			Actions:   s.Actions,
+			LastSeen:  s.LastSeen,
+		}
+	}
+	return result, nil
+}
+
+// SearchChat returns the 25 most recent archived messages matching a query.
+func (a *ServerAdapter) SearchChat(q bot.ChatSearchQuery) ([]string, error) {
+	if !chatArchiveEnabled() {
+		return nil, fmt.Errorf("the chat archive is disabled")
+	}
+	query := db.ChatQuery{
+		Text:  q.Text,
+		Area:  q.Area,
+		Limit: maxSearchResults,
+	}
+	if q.Period > 0 {
+		query.Since = time.Now().Add(-q.Period).UTC().Unix()
+	}
+	msgs, err := db.SearchChat(query)
+	if err != nil {
+		logger.LogErrorf("Failed to search the chat archive: %v", err)
+		return nil, fmt.Errorf("database error")
+	}
+	lines := make([]string, len(msgs))
+	for i, m := range msgs {
+		lines[i] = formatChatMessage(m)
+	}
+	return lines, nil
+}
@@ -0,0 +1,125 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"time"
+)
+
+// playerOnlyCommands are commands that act on the caller's own character, area or account,
+// or that take secrets such as passwords, so they can't be run from Discord.
+var playerOnlyCommands = map[string]bool{
+	"2fa":         true,
+	"charselect":  true,
+	"cm":          true,
+	"fullpossess": true,
+	"link":        true,
+	"login":       true,
+	"logout":      true,
+	"mkusr":       true,
+	"move":        true,
+	"pair":        true,
+	"passwd":      true,
+	"pos":         true,
+	"possess":     true,
+	"randomchar":  true,
+	"uncm":        true,
+	"unpair":      true,
+	"unpossess":   true,
+}
+
+// headlessConn is a connection that records what is written to it, used by clients that run commands from Discord.
+type headlessConn struct {
+	out bytes.Buffer
+}
+
+func (c *headlessConn) Read(_ []byte) (int, error)         { return 0, net.ErrClosed }
+func (c *headlessConn) Write(b []byte) (int, error)        { return c.out.Write(b) }
+func (c *headlessConn) Close() error                       { return nil }
+func (c *headlessConn) LocalAddr() net.Addr                { return &net.TCPAddr{} }
+func (c *headlessConn) RemoteAddr() net.Addr               { return &net.TCPAddr{} }
+func (c *headlessConn) SetDeadline(_ time.Time) error      { return nil }
+func (c *headlessConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (c *headlessConn) SetWriteDeadline(_ time.Time) error { return nil }
+
+// serverMessages returns the server messages sent over the connection, ignoring any other packets.
+func (c *headlessConn) serverMessages() []string {
+	var msgs []string
+	for _, p := range strings.Split(c.out.String(), "#%") {
+		fields := strings.Split(p, "#")
+		if len(fields) >= 3 && fields[0] == "CT" {
+			msgs = append(msgs, decode(fields[2]))
+		}
+	}
+	return msgs
+}
+
+// newHeadlessClient returns a client that isn't connected to the server, with a moderator's name and permissions.
+// It stands in the first area, which area commands act on.
+func newHeadlessClient(modName string, perms uint64) (*Client, *headlessConn) {
+	conn := &headlessConn{}
+	client := NewClient(conn, "Discord")
+	client.SetArea(areas[0])
+	client.SetOocName("[Discord] " + modName)
+	client.SetAuthenticated(true)
+	client.SetModName(modName)
+	client.SetPerms(perms)
+	return client, conn
+}
+
+// parseCommandLine splits an in-game command line, with or without its leading slash, into the command's name and arguments.
+func parseCommandLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "/") {
+		line = "/" + line
+	}
+	command := strings.ToLower(strings.TrimPrefix(commandRegex.FindString(line), "/"))
+	args := strings.Split(strings.Join(commandRegex.Split(line, 1), ""), " ")[1:]
+	return command, args
+}
+
+// runHeadlessCommand runs an in-game command line as a moderator on Discord, returning the server messages it sent.
+// Only the command's name is logged, as its arguments may hold secrets.
+func runHeadlessCommand(modName string, perms uint64, line string) []string {
+	command, args := parseCommandLine(line)
+	if command == "" {
+		return []string{"Invalid command."}
+	}
+	if playerOnlyCommands[command] {
+		return []string{"/" + command + " can only be used in game."}
+	}
+
+	client, conn := newHeadlessClient(modName, perms)
+	addToBuffer(client, "CMD", "Ran /"+command+" from Discord.", false)
+	ParseCommand(client, command, args)
+	return conn.serverMessages()
+}
+
+// RunCommand runs an in-game command for a Discord moderator. The command is attributed to the moderator
+// account linked to the Discord user if there is one, or to their Discord name.
+func (a *ServerAdapter) RunCommand(discordID string, name string, perms uint64, line string) []string {
+	if account := linkedAccount(discordID); account != "" {
+		name = account
+	}
+	if command, _ := parseCommandLine(line); command != "" {
+		writeDiscordAudit(name, "cmd", "", "", "Ran /"+command+" from Discord.")
+	}
+	return runHeadlessCommand(name, perms, line)
+}
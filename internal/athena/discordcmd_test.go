@@ -0,0 +1,93 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+)
+
+// TestRunHeadlessCommand tests that commands run from Discord check permissions and return their server messages
+func TestRunHeadlessCommand(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config = &settings.Config{}
+	config.Name = "Test#Server"
+	cleanup := setupTestAreas([]*area.Area{makeTestArea("Lobby")})
+	defer cleanup()
+	initCommands()
+
+	tests := []struct {
+		name  string
+		perms uint64
+		line  string
+		want  string
+	}{
+		{"runs", 0, "/about", "Running Athena version"},
+		{"without slash", 0, "about", "Running Athena version"},
+		{"usage", permissions.PermissionField["MUTE"], "/mute -h", "Usage: /mute"},
+		{"permissions", 0, "/mute 1", "You do not have permission to use that command."},
+		{"player only", permissions.PermissionField["ADMIN"], "/move 1", "/move can only be used in game."},
+		{"password", permissions.PermissionField["ADMIN"], "/mkusr bob hunter2 admin", "/mkusr can only be used in game."},
+		{"two-factor", permissions.PermissionField["ADMIN"], "/2fa enable", "/2fa can only be used in game."},
+		{"invalid", 0, "/notacommand", "Invalid command."},
+		{"empty", 0, "", "Invalid command."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := runHeadlessCommand("mod", tt.perms, tt.line)
+			if len(out) != 1 || !strings.HasPrefix(out[0], tt.want) {
+				t.Errorf("runHeadlessCommand(%q) = %q, want %q", tt.line, out, tt.want)
+			}
+		})
+	}
+
+	if buffer := strings.Join(areas[0].Buffer(), "\n"); strings.Contains(buffer, "hunter2") || strings.Contains(buffer, "-h") {
+		t.Errorf("area buffer has command arguments: %q", buffer)
+	}
+}
+
+// TestAdapterCommandPerms tests that the bot sees the permissions of in-game commands, including overrides from commands.toml
+func TestAdapterCommandPerms(t *testing.T) {
+	oldPath := settings.ConfigPath
+	defer func() {
+		settings.ConfigPath = oldPath
+		initCommands()
+	}()
+	settings.ConfigPath = t.TempDir()
+	if err := os.WriteFile(filepath.Join(settings.ConfigPath, "commands.toml"), []byte("[permissions]\nmute = [\"KICK\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	initCommands()
+
+	a := &ServerAdapter{}
+	if p, ok := a.CommandPerms("mute"); !ok || p != permissions.PermissionField["KICK"] {
+		t.Errorf("CommandPerms(\"mute\") = %v, %v, want KICK", p, ok)
+	}
+	if p, ok := a.CommandPerms("ban"); !ok || p != permissions.PermissionField["BAN"] {
+		t.Errorf("CommandPerms(\"ban\") = %v, %v, want BAN", p, ok)
+	}
+	if _, ok := a.CommandPerms("notacommand"); ok {
+		t.Errorf("CommandPerms(\"notacommand\") found a command")
+	}
+}
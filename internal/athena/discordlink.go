@@ -0,0 +1,309 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+)
+
+const (
+	linkCodeLength   = 8
+	linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // No 0/O or 1/I, which are easily confused.
+	linkCodeTTL      = 10 * time.Minute
+	discordLoginTTL  = 2 * time.Minute
+)
+
+// linkCode is a pending /link code, waiting to be redeemed on Discord.
+type linkCode struct {
+	kind    string
+	target  string
+	expires time.Time
+}
+
+// discordLogin is a login waiting to be approved by the account's linked Discord user.
+type discordLogin struct {
+	client    *Client
+	username  string
+	discordID string
+	expires   time.Time
+}
+
+var (
+	linkMu           sync.Mutex
+	linkCodes        = make(map[string]linkCode)
+	discordLogins    = make(map[int]discordLogin)
+	nextDiscordLogin int
+)
+
+// newLinkCode returns a one-time code that links a Discord user to a moderator account or IPID.
+// Any earlier code for the same target stops working.
+func newLinkCode(kind string, target string, now time.Time) (string, error) {
+	b := make([]byte, linkCodeLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(linkCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = linkCodeAlphabet[n.Int64()]
+	}
+	code := string(b)
+	linkMu.Lock()
+	defer linkMu.Unlock()
+	for c, l := range linkCodes {
+		if now.After(l.expires) || (l.kind == kind && l.target == target) {
+			delete(linkCodes, c)
+		}
+	}
+	linkCodes[code] = linkCode{kind: kind, target: target, expires: now.Add(linkCodeTTL)}
+	return code, nil
+}
+
+// redeemLinkCode links a Discord user with the target of a link code. Each code can only be used once.
+func redeemLinkCode(code string, discordID string, now time.Time) (db.DiscordLink, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	linkMu.Lock()
+	l, ok := linkCodes[code]
+	delete(linkCodes, code)
+	linkMu.Unlock()
+	if !ok || now.After(l.expires) {
+		return db.DiscordLink{}, fmt.Errorf("invalid or expired code")
+	}
+	link := db.DiscordLink{DiscordID: discordID, Kind: l.kind, Target: l.target, Time: now.UTC().Unix()}
+	if l.kind == db.LinkIPID {
+		for _, c := range getClientsByIpid(l.target) {
+			link.Alerts |= caseAlerts(c)
+		}
+	}
+	if err := db.AddDiscordLink(link); err != nil {
+		logger.LogErrorf("Failed to link Discord user %v: %v", discordID, err)
+		return db.DiscordLink{}, fmt.Errorf("database error")
+	}
+	return link, nil
+}
+
+// requestDiscordLogin asks the Discord user linked to an account to approve a login from a client.
+// Approval on Discord replaces the password, but not the two-factor code of accounts that have one.
+func requestDiscordLogin(client *Client, username string, code string) {
+	b := discordBot.Load()
+	var links []db.DiscordLink
+	if b != nil {
+		var err error
+		if links, err = db.GetLinkedDiscordUsers(db.LinkAccount, username); err != nil {
+			logger.LogErrorf("Failed to get Discord links of %v: %v", username, err)
+		}
+	}
+	if len(links) == 0 {
+		client.SendServerMessage("This account can't log in with Discord. Usage: /login <username> <password> [code]")
+		loginFailed(client, username, fmt.Sprintf("Failed login as %v: not linked to Discord.", username))
+		return
+	}
+	secret, err := db.GetTOTPSecret(username)
+	if err != nil {
+		logger.LogErrorf("Failed to get two-factor secret of %v: %v", username, err)
+		client.SendPacket("AUTH", "0")
+		return
+	}
+	if secret != "" {
+		if code == "" {
+			client.SendServerMessage("This account requires a two-factor code: /login <username> -d <code>")
+			loginFailed(client, username, fmt.Sprintf("Failed Discord login as %v: missing two-factor code.", username))
+			return
+		}
+		if !checkTOTP(username, secret, code) {
+			client.SendServerMessage("Invalid two-factor code.")
+			loginFailed(client, username, fmt.Sprintf("Failed Discord login as %v: invalid two-factor code.", username))
+			return
+		}
+	}
+	now := time.Now()
+	linkMu.Lock()
+	for id, l := range discordLogins {
+		if now.After(l.expires) {
+			delete(discordLogins, id)
+		} else if l.username == username {
+			linkMu.Unlock()
+			client.SendServerMessage("A Discord login for this account is already waiting to be approved.")
+			return
+		}
+	}
+	nextDiscordLogin++
+	id := nextDiscordLogin
+	discordLogins[id] = discordLogin{client: client, username: username, discordID: links[0].DiscordID, expires: now.Add(discordLoginTTL)}
+	linkMu.Unlock()
+
+	if err := b.RequestLogin(links[0].DiscordID, username, client.Ipid(), id); err != nil {
+		linkMu.Lock()
+		delete(discordLogins, id)
+		linkMu.Unlock()
+		logger.LogErrorf("Failed to send Discord login request for %v: %v", username, err)
+		client.SendServerMessage("Failed to send a login request to Discord.")
+		return
+	}
+	client.SendServerMessage(fmt.Sprintf("A login request has been sent to your Discord account. Approve it within %v.", discordLoginTTL))
+	addToBuffer(client, "AUTH", fmt.Sprintf("Requested a Discord login as %v.", username), true)
+}
+
+// answerDiscordLogin approves or denies a pending Discord login, returning the account name.
+func answerDiscordLogin(id int, discordID string, approve bool) (string, error) {
+	linkMu.Lock()
+	l, ok := discordLogins[id]
+	if ok && l.discordID == discordID {
+		delete(discordLogins, id)
+	}
+	linkMu.Unlock()
+	if !ok || l.discordID != discordID {
+		return "", fmt.Errorf("no such login request")
+	}
+	if time.Now().After(l.expires) {
+		l.client.SendServerMessage("Your Discord login request expired.")
+		return l.username, fmt.Errorf("the request expired")
+	}
+	// The UID may have been given to another player since the request was made.
+	if c, err := getClientByUid(l.client.Uid()); err != nil || c != l.client || l.client.Authenticated() {
+		return l.username, fmt.Errorf("the player is no longer waiting to log in")
+	}
+	if !approve {
+		loginFailed(l.client, l.username, fmt.Sprintf("Discord login as %v was denied.", l.username))
+		return l.username, nil
+	}
+	roleName, perms, err := db.GetUserRole(l.username)
+	if err != nil {
+		return l.username, fmt.Errorf("the account no longer exists")
+	}
+	completeLogin(l.client, l.username, roleName, perms, "Discord")
+	return l.username, nil
+}
+
+// linkedAccount returns the moderator account linked to a Discord user, or an empty string if there is none.
+func linkedAccount(discordID string) string {
+	links, err := db.GetDiscordLinks(discordID)
+	if err != nil {
+		logger.LogErrorf("Failed to get Discord links of %v: %v", discordID, err)
+	}
+	for _, l := range links {
+		if l.Kind == db.LinkAccount {
+			return l.Target
+		}
+	}
+	return ""
+}
+
+// caseAlerts returns the case roles a client has alerts enabled for, as a bitmask of role indexes.
+func caseAlerts(client *Client) int {
+	var alerts int
+	for i, b := range client.CasePrefs() {
+		if b {
+			alerts |= 1 << i
+		}
+	}
+	return alerts
+}
+
+// saveCaseAlerts saves a client's case alert settings to their Discord links,
+// so that they are only sent announcements for the same roles while they're offline.
+func saveCaseAlerts(client *Client) {
+	if err := db.SetDiscordLinkAlerts(db.LinkIPID, client.Ipid(), caseAlerts(client)); err != nil {
+		logger.LogErrorf("Failed to save case alerts of %v: %v", client.Ipid(), err)
+	}
+}
+
+// notifyLinkedPlayers sends a case announcement for roles, a bitmask of role indexes, by DM to the
+// Discord users linked to players who aren't online and have alerts enabled for any of those roles.
+// It returns immediately; the links are looked up and the messages sent in the background.
+func notifyLinkedPlayers(title string, message string, roles int) {
+	b := discordBot.Load()
+	if b == nil || roles == 0 {
+		return
+	}
+	go func() {
+		b.NotifyUsers(caseRecipients(roles), title, message)
+	}()
+}
+
+// caseRecipients returns the Discord users to send a case announcement for roles to.
+func caseRecipients(roles int) []string {
+	links, err := db.GetAllDiscordLinks(db.LinkIPID)
+	if err != nil {
+		logger.LogErrorf("Failed to get Discord links: %v", err)
+		return nil
+	}
+	var ids []string
+	for _, l := range links {
+		if l.Alerts&roles != 0 && len(getClientsByIpid(l.Target)) == 0 {
+			ids = append(ids, l.DiscordID)
+		}
+	}
+	return ids
+}
+
+// Handles /link
+func cmdLink(client *Client, args []string, usage string) {
+	kind, target, what := db.LinkIPID, client.Ipid(), "this player"
+	if client.Authenticated() && (len(args) == 0 || args[0] != "-p") {
+		kind, target, what = db.LinkAccount, client.ModName(), "your moderator account "+client.ModName()
+	} else if len(args) > 0 && args[0] != "-p" {
+		client.SendServerMessage("Invalid arguments.\n" + usage)
+		return
+	}
+	if discordBot.Load() == nil {
+		client.SendServerMessage("The Discord bot is not running.")
+		return
+	}
+	code, err := newLinkCode(kind, target, time.Now())
+	if err != nil {
+		logger.LogErrorf("Failed to generate link code: %v", err)
+		client.SendServerMessage("Failed to generate a link code.")
+		return
+	}
+	client.SendServerMessage(fmt.Sprintf("To link your Discord account to %v, use /link %v in the Discord server within %v. Don't share this code.", what, code, linkCodeTTL))
+}
+
+// LinkDiscord redeems a link code from the game for a Discord user.
+func (a *ServerAdapter) LinkDiscord(code string, discordID string) (string, error) {
+	link, err := redeemLinkCode(code, discordID, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if link.Kind == db.LinkAccount {
+		writeDiscordAudit(link.Target, "link", discordID, "", fmt.Sprintf("Linked moderator account %v to Discord user %v.", link.Target, discordID))
+		return "the moderator account **" + link.Target + "**", nil
+	}
+	return "your player identity, and you will receive case announcements by DM for the roles you have case alerts enabled for in game", nil
+}
+
+// UnlinkDiscord removes every link of a Discord user.
+func (a *ServerAdapter) UnlinkDiscord(discordID string) (int, error) {
+	n, err := db.RemoveDiscordLinks(discordID)
+	if err != nil {
+		logger.LogErrorf("Failed to unlink Discord user %v: %v", discordID, err)
+		return 0, fmt.Errorf("database error")
+	}
+	return int(n), nil
+}
+
+// AnswerDiscordLogin approves or denies a login request sent to a linked moderator.
+func (a *ServerAdapter) AnswerDiscordLogin(id int, discordID string, approve bool) (string, error) {
+	return answerDiscordLogin(id, discordID, approve)
+}
@@ -0,0 +1,207 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	discordbot "github.com/MangosArentLiterature/Athena/internal/discord/bot"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/packet"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+)
+
+// TestNewLinkCode tests that link codes are unambiguous and replace earlier codes for the same target
+func TestNewLinkCode(t *testing.T) {
+	now := time.Now()
+	first, err := newLinkCode(db.LinkIPID, "abc", now)
+	if err != nil {
+		t.Fatalf("newLinkCode() error: %v", err)
+	}
+	if len(first) != linkCodeLength {
+		t.Errorf("newLinkCode() = %q, want %v characters", first, linkCodeLength)
+	}
+	for _, c := range first {
+		if !strings.ContainsRune(linkCodeAlphabet, c) {
+			t.Errorf("newLinkCode() = %q, contains %q", first, c)
+		}
+	}
+	second, err := newLinkCode(db.LinkIPID, "abc", now)
+	if err != nil {
+		t.Fatalf("newLinkCode() error: %v", err)
+	}
+	linkMu.Lock()
+	_, firstOk := linkCodes[first]
+	_, secondOk := linkCodes[second]
+	linkMu.Unlock()
+	if firstOk || !secondOk {
+		t.Errorf("after a second code: first valid = %v, second valid = %v", firstOk, secondOk)
+	}
+}
+
+// TestRedeemLinkCode tests that link codes are stored once redeemed, and can't be reused or redeemed late
+func TestRedeemLinkCode(t *testing.T) {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	defer db.Close()
+	now := time.Now()
+
+	code, _ := newLinkCode(db.LinkAccount, "mod", now)
+	if _, err := redeemLinkCode(strings.ToLower(code), "1234", now); err != nil {
+		t.Fatalf("redeemLinkCode() error: %v", err)
+	}
+	if _, err := redeemLinkCode(code, "5678", now); err == nil {
+		t.Error("redeemLinkCode() with a used code succeeded")
+	}
+	links, err := db.GetLinkedDiscordUsers(db.LinkAccount, "mod")
+	if err != nil || len(links) != 1 || links[0].DiscordID != "1234" {
+		t.Errorf("GetLinkedDiscordUsers() = %v, %v, want Discord user 1234", links, err)
+	}
+
+	code, _ = newLinkCode(db.LinkIPID, "abc", now)
+	if _, err := redeemLinkCode(code, "1234", now.Add(linkCodeTTL+time.Second)); err == nil {
+		t.Error("redeemLinkCode() with an expired code succeeded")
+	}
+
+	n, err := db.RemoveDiscordLinks("1234")
+	if err != nil || n != 1 {
+		t.Errorf("RemoveDiscordLinks() = %v, %v, want 1", n, err)
+	}
+}
+
+// TestCaseRecipients tests that case announcements are only sent to offline players with alerts enabled for the roles
+func TestCaseRecipients(t *testing.T) {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	defer db.Close()
+	defer setupTestAreas([]*area.Area{makeTestArea("Lobby")})()
+	online := NewClient(&headlessConn{}, "online")
+	clients.AddClient(online)
+	defer clients.RemoveClient(online)
+	links := []db.DiscordLink{
+		{DiscordID: "1", Kind: db.LinkIPID, Target: "online", Alerts: 1},
+		{DiscordID: "2", Kind: db.LinkIPID, Target: "def", Alerts: 1},
+		{DiscordID: "3", Kind: db.LinkIPID, Target: "pro", Alerts: 2},
+		{DiscordID: "4", Kind: db.LinkIPID, Target: "setter"},
+	}
+	for _, l := range links {
+		if err := db.AddDiscordLink(l); err != nil {
+			t.Fatalf("AddDiscordLink() error: %v", err)
+		}
+	}
+
+	// Case alert settings sent in game are saved to the player's link.
+	setter := NewClient(&headlessConn{}, "setter")
+	pktSetCase(setter, &packet.Packet{Header: "SETCASE", Body: []string{"", "", "true", "false", "false", "false"}})
+	got := caseRecipients(1)
+	if strings.Join(got, ",") != "2,4" {
+		t.Errorf("caseRecipients(def) = %v, want [2 4]", got)
+	}
+	if got := caseRecipients(2 | 4); strings.Join(got, ",") != "3" {
+		t.Errorf("caseRecipients(pro, wit) = %v, want [3]", got)
+	}
+}
+
+// TestAnswerDiscordLoginReusedUID tests that an approval doesn't log in a different player who was given the requester's UID
+func TestAnswerDiscordLoginReusedUID(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config = &settings.Config{}
+	cleanup := setupTestAreas([]*area.Area{makeTestArea("Lobby")})
+	defer cleanup()
+
+	requester, _ := newHeadlessClient("", 0)
+	requester.SetAuthenticated(false)
+	requester.SetUid(7)
+	other, _ := newHeadlessClient("", 0)
+	other.SetAuthenticated(false)
+	other.SetUid(7)
+	clients.AddClient(other)
+	defer clients.RemoveClient(other)
+
+	linkMu.Lock()
+	nextDiscordLogin++
+	id := nextDiscordLogin
+	discordLogins[id] = discordLogin{client: requester, username: "mod", discordID: "1234", expires: time.Now().Add(time.Minute)}
+	linkMu.Unlock()
+
+	if _, err := answerDiscordLogin(id, "1234", true); err == nil {
+		t.Error("answerDiscordLogin() succeeded for a reused UID")
+	}
+	if requester.Authenticated() || other.Authenticated() {
+		t.Error("a client was logged in by an approval for a reused UID")
+	}
+}
+
+// TestDiscordLoginNeedsTwoFactor tests that a Discord login to an account with two-factor authentication needs a valid code
+func TestDiscordLoginNeedsTwoFactor(t *testing.T) {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	defer db.Close()
+	oldConfig, oldLogPath := config, logger.LogPath
+	defer func() { config, logger.LogPath = oldConfig, oldLogPath }()
+	config = &settings.Config{}
+	logger.LogPath = t.TempDir()
+	cleanup := setupTestAreas([]*area.Area{makeTestArea("Lobby")})
+	defer cleanup()
+	discordBot.Store(&discordbot.Bot{})
+	defer discordBot.Store(nil)
+	defer loginLimits.resetUser("mod2fa")
+
+	if err := db.CreateUser("mod2fa", []byte("password"), "", 1); err != nil {
+		t.Fatalf("CreateUser() error: %v", err)
+	}
+	if err := db.SetTOTPSecret("mod2fa", "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatalf("SetTOTPSecret() error: %v", err)
+	}
+	if err := db.AddDiscordLink(db.DiscordLink{DiscordID: "1234", Kind: db.LinkAccount, Target: "mod2fa"}); err != nil {
+		t.Fatalf("AddDiscordLink() error: %v", err)
+	}
+
+	for _, code := range []string{"", "abcdef"} {
+		client, conn := newHeadlessClient("", 0)
+		client.SetAuthenticated(false)
+		requestDiscordLogin(client, "mod2fa", code)
+		out := conn.serverMessages()
+		if len(out) == 0 || !strings.Contains(strings.ToLower(out[0]), "two-factor code") {
+			t.Errorf("requestDiscordLogin() with code %q sent %q, want a two-factor error", code, out)
+		}
+		linkMu.Lock()
+		for _, l := range discordLogins {
+			if l.username == "mod2fa" {
+				t.Errorf("requestDiscordLogin() with code %q created a login request", code)
+			}
+		}
+		linkMu.Unlock()
+	}
+
+	// Reading the audit log also flushes it before the log directory is removed.
+	if records, err := logger.QueryAudit(logger.AuditQuery{Action: "auth"}); err != nil || len(records) != 2 {
+		t.Errorf("audited %v failed logins (error %v), want 2", len(records), err)
+	}
+}
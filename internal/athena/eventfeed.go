@@ -0,0 +1,214 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	discordbot "github.com/MangosArentLiterature/Athena/internal/discord/bot"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/webhook"
+)
+
+// Kinds of event that can be posted to the event feed, as named in the event_feed config option.
+const (
+	eventJoin   = "join"
+	eventLeave  = "leave"
+	eventBan    = "ban"
+	eventKick   = "kick"
+	eventMute   = "mute"
+	eventPunish = "punish"
+	eventArea   = "area"
+	eventCase   = "case"
+)
+
+const (
+	eventQueueSize   = 256  // Events waiting to be read by the feed before new ones are dropped.
+	eventPendingSize = 1000 // Events waiting to be posted before new ones are dropped, if Discord falls behind.
+	eventBatchLength = 3500 // Characters of events posted in one message, within Discord's embed description limit.
+	// eventFlushPeriod is how often queued events are posted. Discord allows a channel about five messages
+	// every few seconds, so batching keeps the feed within its rate limits during bursts like a mass kick.
+	eventFlushPeriod = 5 * time.Second
+)
+
+var eventIcons = map[string]string{
+	eventJoin:   "📥",
+	eventLeave:  "📤",
+	eventBan:    "🔨",
+	eventKick:   "👢",
+	eventMute:   "🔇",
+	eventPunish: "🎭",
+	eventArea:   "🏛️",
+	eventCase:   "📣",
+}
+
+var (
+	eventMu    sync.RWMutex // Held for reading while queueing events, so the queue isn't closed during a send.
+	eventQueue chan string
+	eventDone  chan struct{}
+	eventKinds map[string]bool
+)
+
+// startEventFeed starts the goroutine that posts the given kinds of event to Discord.
+// Events are posted by the bot if a token is configured, or to the webhook otherwise.
+func startEventFeed(kinds []string) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	if eventQueue != nil || len(kinds) == 0 {
+		return
+	}
+	eventKinds = make(map[string]bool, len(kinds))
+	for _, k := range kinds {
+		k = strings.ToLower(k)
+		if _, ok := eventIcons[k]; !ok {
+			logger.LogWarningf("Unknown event_feed event: %v", k)
+			continue
+		}
+		eventKinds[k] = true
+	}
+	eventQueue = make(chan string, eventQueueSize)
+	eventDone = make(chan struct{})
+	go runEventFeed(eventQueue, eventDone)
+}
+
+// stopEventFeed posts any queued events and stops the event feed.
+func stopEventFeed() {
+	eventMu.Lock()
+	queue, done := eventQueue, eventDone
+	eventQueue = nil
+	eventMu.Unlock()
+	if queue == nil {
+		return
+	}
+	close(queue)
+	<-done
+}
+
+// runEventFeed posts queued events until the queue is closed, one message every eventFlushPeriod.
+func runEventFeed(queue chan string, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(eventFlushPeriod)
+	defer ticker.Stop()
+	var pending []string
+	var dropped int
+	for {
+		select {
+		case e, ok := <-queue:
+			if !ok {
+				for _, b := range batchEvents(finalEvents(pending, dropped), eventBatchLength) {
+					postEvents(b)
+				}
+				return
+			}
+			pending, dropped = addPendingEvent(pending, dropped, e)
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+			// Anything that doesn't fit in one message waits for the next tick.
+			b := batchEvents(pending, eventBatchLength)[0]
+			postEvents(b)
+			pending = pending[len(b):]
+		}
+	}
+}
+
+// addPendingEvent adds an event to those waiting to be posted, returning the new pending events and count of dropped events.
+// Once eventPendingSize events are waiting, new ones are dropped, and a note of how many were lost is added before the next one kept.
+func addPendingEvent(pending []string, dropped int, e string) ([]string, int) {
+	if len(pending) >= eventPendingSize {
+		return pending, dropped + 1
+	}
+	return append(finalEvents(pending, dropped), e), 0
+}
+
+// finalEvents returns the events waiting to be posted, with a note of how many were dropped if any were.
+func finalEvents(pending []string, dropped int) []string {
+	if dropped > 0 {
+		pending = append(pending, fmt.Sprintf("⚠️ %v events were dropped as the feed fell behind.", dropped))
+	}
+	return pending
+}
+
+// batchEvents splits events into batches no longer than max bytes once joined with newlines.
+// Events longer than max on their own are truncated.
+func batchEvents(events []string, max int) [][]string {
+	var batches [][]string
+	var cur []string
+	var n int
+	for _, e := range events {
+		if len(e) > max {
+			cut := max - len("…")
+			for cut > 0 && !utf8.RuneStart(e[cut]) {
+				cut--
+			}
+			e = e[:cut] + "…"
+		}
+		if len(cur) > 0 && n+len(e) > max {
+			batches = append(batches, cur)
+			cur, n = nil, 0
+		}
+		cur = append(cur, e)
+		n += len(e) + 1
+	}
+	if len(cur) > 0 {
+		batches = append(batches, cur)
+	}
+	return batches
+}
+
+// postEvents posts a batch of events to the bot's event channel, or to the webhook if there is no bot.
+func postEvents(events []string) {
+	var err error
+	if config.BotToken != "" {
+		b := discordBot.Load()
+		if b == nil {
+			return
+		}
+		err = b.PostEvents(events)
+	} else {
+		err = webhook.PostEvents(events)
+	}
+	if err != nil {
+		logger.LogErrorf("Failed to post %v events to Discord: %v", len(events), err)
+	}
+}
+
+// feedEvent queues an event for the event feed, if that kind of event is enabled.
+// Text from players in message should be escaped with discordbot.SanitizeForDiscord.
+// Events are dropped rather than blocking if Discord falls behind.
+func feedEvent(kind string, message string) {
+	eventMu.RLock()
+	defer eventMu.RUnlock()
+	if eventQueue == nil || !eventKinds[kind] {
+		return
+	}
+	e := fmt.Sprintf("%v <t:%v:T> %v", eventIcons[kind], time.Now().Unix(), message)
+	select {
+	case eventQueue <- e:
+	default:
+	}
+}
+
+// feedName formats a player-chosen name for the event feed.
+func feedName(s string) string {
+	return discordbot.SanitizeForDiscord(s)
+}
@@ -0,0 +1,99 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestBatchEvents tests that events are split into messages within the length limit
+func TestBatchEvents(t *testing.T) {
+	events := []string{"aaaa", "bbbb", "cccc", strings.Repeat("é", 10), "abcdefghijkl"}
+	batches := batchEvents(events, 10)
+	want := [][]string{{"aaaa", "bbbb"}, {"cccc"}, {"ééé…"}, {"abcdefg…"}}
+	if len(batches) != len(want) {
+		t.Fatalf("batchEvents() = %q, want %q", batches, want)
+	}
+	for i := range want {
+		if strings.Join(batches[i], "\n") != strings.Join(want[i], "\n") {
+			t.Errorf("batch %v = %q, want %q", i, batches[i], want[i])
+		}
+		if n := len(strings.Join(batches[i], "\n")); n > 10 {
+			t.Errorf("batch %v is %v bytes, want at most 10", i, n)
+		}
+	}
+}
+
+// TestAddPendingEvent tests that events waiting to be posted are capped, with a note of how many were dropped
+func TestAddPendingEvent(t *testing.T) {
+	var pending []string
+	var dropped int
+	for i := 0; i < eventPendingSize+3; i++ {
+		pending, dropped = addPendingEvent(pending, dropped, "event")
+	}
+	if len(pending) != eventPendingSize || dropped != 3 {
+		t.Fatalf("pending %v events with %v dropped, want %v with 3 dropped", len(pending), dropped, eventPendingSize)
+	}
+
+	pending = pending[1:] // One event was posted.
+	pending, dropped = addPendingEvent(pending, dropped, "new event")
+	if dropped != 0 || len(pending) != eventPendingSize+1 {
+		t.Fatalf("pending %v events with %v dropped after space freed, want %v with none dropped", len(pending), dropped, eventPendingSize+1)
+	}
+	if note := pending[len(pending)-2]; !strings.Contains(note, "3 events were dropped") {
+		t.Errorf("note = %q, want the number of dropped events", note)
+	}
+	if pending[len(pending)-1] != "new event" {
+		t.Errorf("last pending event = %q, want %q", pending[len(pending)-1], "new event")
+	}
+}
+
+// TestFinalEvents tests that events dropped since the last one kept are still noted when the feed stops
+func TestFinalEvents(t *testing.T) {
+	if got := finalEvents([]string{"event"}, 0); len(got) != 1 {
+		t.Errorf("finalEvents() with none dropped = %q, want only the event", got)
+	}
+	got := finalEvents([]string{"event"}, 5)
+	if len(got) != 2 || !strings.Contains(got[1], "5 events were dropped") {
+		t.Errorf("finalEvents() = %q, want a note of the dropped events", got)
+	}
+}
+
+// TestFeedEvent tests that only enabled kinds of event are queued
+func TestFeedEvent(t *testing.T) {
+	eventMu.Lock()
+	eventQueue = make(chan string, 2)
+	eventKinds = map[string]bool{eventBan: true}
+	eventMu.Unlock()
+	defer func() {
+		eventMu.Lock()
+		eventQueue, eventKinds = nil, nil
+		eventMu.Unlock()
+	}()
+
+	feedEvent(eventJoin, "UID 1 joined the server.")
+	feedEvent(eventBan, "mod banned abc: spam")
+	feedEvent(eventBan, "mod banned def: spam")
+	feedEvent(eventBan, "dropped, as the queue is full")
+	if n := len(eventQueue); n != 2 {
+		t.Fatalf("queued %v events, want 2", n)
+	}
+	if e := <-eventQueue; !strings.HasPrefix(e, "🔨 <t:") || !strings.HasSuffix(e, "> mod banned abc: spam") {
+		t.Errorf("feedEvent() queued %q", e)
+	}
+}
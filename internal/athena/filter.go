@@ -0,0 +1,188 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+	"github.com/MangosArentLiterature/Athena/internal/wordfilter"
+)
+
+var chatFilter *wordfilter.Filter
+
+// filterText runs text sent by a client through the chat filter and carries out the most severe matching action.
+// field names where the text came from, and is used in logs and moderator notices.
+// It returns the text to use in place of the original, and whether the message should still be sent.
+func filterText(client *Client, field string, text string) (string, bool) {
+	if chatFilter == nil {
+		return text, true
+	}
+	res := chatFilter.Check(text)
+	if len(res.Hits) == 0 {
+		return text, true
+	}
+	for _, h := range res.Hits {
+		addToBuffer(client, "FILTER", fmt.Sprintf("%v matched rule %v (%v): \"%v\"", field, h.ID, h.Action, h.Match), false)
+	}
+	sendModServerMessage(fmt.Sprintf("[FILTER] UID %v (%v) in %v: %v %v matched the filter (%v): \"%v\"",
+		client.Uid(), client.Ipid(), client.Area().Name(), client.OOCName(), field, res.Action, text))
+
+	switch res.Action {
+	case wordfilter.Notify, wordfilter.Censor:
+		return res.Text, true
+	case wordfilter.Block:
+		client.SendServerMessage("Your message was blocked by the server's filter.")
+	case wordfilter.Warn:
+		client.SendServerMessage("Warning: your message was blocked by the server's filter. Further violations may get you muted or kicked.")
+	case wordfilter.Mute:
+		m := ICMuted
+		if field == "OOC" || field == "OOC name" {
+			m = OOCMuted
+		}
+		client.AddMute(m, time.Now().UTC().Add(time.Duration(config.FilterMuteDuration)*time.Second))
+		client.SendServerMessage(fmt.Sprintf("You have been muted for %v seconds by the server's filter.", config.FilterMuteDuration))
+	case wordfilter.Kick:
+		client.SendPacket("KK", "Kicked by the server's filter.")
+		client.conn.Close()
+		logger.LogInfof("Kicked %v (%v) for filtered %v.", client.Ipid(), client.OOCName(), field)
+	}
+	return "", false
+}
+
+// checkFilter returns text as the chat filter would let it through, and whether it would, without carrying out any action.
+func checkFilter(text string) (string, bool) {
+	if chatFilter == nil {
+		return text, true
+	}
+	res := chatFilter.Check(text)
+	if len(res.Hits) == 0 {
+		return text, true
+	}
+	switch res.Action {
+	case wordfilter.Notify, wordfilter.Censor:
+		return res.Text, true
+	}
+	return "", false
+}
+
+// filterEncoded is filterText for AO2-encoded packet fields.
+// The field is only re-encoded if the filter changed it, so clean messages pass through untouched.
+func filterEncoded(client *Client, field string, s string) (string, bool) {
+	decoded := decode(s)
+	text, ok := filterText(client, field, decoded)
+	if !ok {
+		return "", false
+	}
+	if text != decoded {
+		return encode(text), true
+	}
+	return s, true
+}
+
+// Handles /filter
+func cmdFilter(client *Client, args []string, usage string) {
+	switch args[0] {
+	case "list":
+		rules := chatFilter.Rules()
+		if len(rules) == 0 {
+			client.SendServerMessage("The filter has no rules.")
+			return
+		}
+		var b strings.Builder
+		b.WriteString("\nFilter rules:")
+		for i, r := range rules {
+			kind := "word"
+			if r.Regex {
+				kind = "regex"
+			}
+			fmt.Fprintf(&b, "\n%v: [%v] %v -> %v", i+1, kind, r.Pattern, r.Action)
+		}
+		client.SendServerMessage(b.String())
+		return
+
+	case "add":
+		flags := flag.NewFlagSet("", 0)
+		flags.SetOutput(io.Discard)
+		regex := flags.Bool("r", false, "")
+		action := flags.String("a", "censor", "")
+		flags.Parse(args[1:])
+		if len(flags.Args()) == 0 {
+			client.SendServerMessage("Not enough arguments:\n" + usage)
+			return
+		}
+		r := wordfilter.Rule{Pattern: strings.Join(flags.Args(), " "), Regex: *regex, Action: *action}
+		if err := chatFilter.Add(r); err != nil {
+			client.SendServerMessage(fmt.Sprintf("Failed to add rule: %v.", err))
+			return
+		}
+		forgetCheckedNames()
+		if !saveFilter(client) {
+			return
+		}
+		client.SendServerMessage(fmt.Sprintf("Added filter rule %v.", len(chatFilter.Rules())))
+		addToBuffer(client, "CMD", fmt.Sprintf("Added filter rule \"%v\" (regex: %v, action: %v).", r.Pattern, r.Regex, *action), true)
+
+	case "remove":
+		if len(args) < 2 {
+			client.SendServerMessage("Not enough arguments:\n" + usage)
+			return
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			client.SendServerMessage("Invalid rule ID.")
+			return
+		}
+		rules := chatFilter.Rules()
+		if err := chatFilter.Remove(id); err != nil {
+			client.SendServerMessage(fmt.Sprintf("Failed to remove rule: %v.", err))
+			return
+		}
+		forgetCheckedNames()
+		if !saveFilter(client) {
+			return
+		}
+		client.SendServerMessage(fmt.Sprintf("Removed filter rule %v.", id))
+		addToBuffer(client, "CMD", fmt.Sprintf("Removed filter rule \"%v\".", rules[id-1].Pattern), true)
+
+	default:
+		client.SendServerMessage("Invalid subcommand:\n" + usage)
+	}
+}
+
+// forgetCheckedNames clears the filter results cached for each client's OOC name, so names are checked against the new rules.
+func forgetCheckedNames() {
+	for c := range clients.GetAllClients() {
+		c.SetCheckedOOCName("", "", false)
+	}
+}
+
+// saveFilter writes the current filter rules to disk, reporting any failure to the client.
+func saveFilter(client *Client) bool {
+	if err := settings.SaveFilter(chatFilter.Rules()); err != nil {
+		logger.LogErrorf("Failed to save filter: %v", err)
+		client.SendServerMessage("The rule was applied, but the filter file could not be saved.")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/packet"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+	"github.com/MangosArentLiterature/Athena/internal/wordfilter"
+)
+
+// TestAddMute tests that an automatic mute never lifts, narrows or shortens an existing one
+func TestAddMute(t *testing.T) {
+	now := time.Now().UTC()
+	soon, later := now.Add(time.Minute), now.Add(time.Hour)
+	tests := []struct {
+		name      string
+		muted     MuteState
+		until     time.Time
+		add       MuteState
+		wantMuted MuteState
+		wantUntil time.Time
+	}{
+		{"unmuted", Unmuted, time.Time{}, OOCMuted, OOCMuted, soon},
+		{"combines", ICMuted, later, OOCMuted, ICOOCMuted, later},
+		{"keeps wider", ICOOCMuted, later, ICMuted, ICOOCMuted, later},
+		{"covers music", MusicMuted, later, ICMuted, ICMuted, later},
+		{"extends", ICMuted, now.Add(time.Second), ICMuted, ICMuted, soon},
+		{"permanent", ICOOCMuted, time.Time{}, OOCMuted, ICOOCMuted, time.Time{}},
+	}
+	defer setupTestAreas([]*area.Area{makeTestArea("Lobby")})()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := newHeadlessClient("", 0)
+			client.SetMuted(tc.muted)
+			client.SetUnmuteTime(tc.until)
+			client.AddMute(tc.add, soon)
+			if m, u := client.Muted(), client.UnmuteTime(); m != tc.wantMuted || !u.Equal(tc.wantUntil) {
+				t.Errorf("AddMute() left %v until %v, want %v until %v", m, u, tc.wantMuted, tc.wantUntil)
+			}
+		})
+	}
+}
+
+// TestOOCNameFilter tests that a filtered OOC name blocks chat but not commands, and is checked again when the rules change
+func TestOOCNameFilter(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config = &settings.Config{}
+	config.Name = "Test Server"
+	config.MaxMsg = 256
+	defer setupTestAreas([]*area.Area{makeTestArea("Lobby")})()
+	initCommands()
+	f, err := wordfilter.New([]wordfilter.Rule{{Pattern: "badname", Action: "block"}})
+	if err != nil {
+		t.Fatalf("wordfilter.New() error: %v", err)
+	}
+	origFilter := chatFilter
+	chatFilter = f
+	defer func() { chatFilter = origFilter }()
+
+	client, conn := newHeadlessClient("", 0)
+	client.SetOocName("player")
+	clients.AddClient(client)
+	defer clients.RemoveClient(client)
+
+	pktOOC(client, &packet.Packet{Header: "CT", Body: []string{"badname", "/help"}})
+	out := strings.Join(conn.serverMessages(), "\n")
+	if strings.Contains(out, "blocked") || !strings.Contains(out, "/about") {
+		t.Errorf("/help with a filtered name sent %q, want the help", out)
+	}
+	if n := client.OOCName(); n != "player" {
+		t.Errorf("OOC name = %q after a command, want it unchanged", n)
+	}
+
+	conn.out.Reset()
+	pktOOC(client, &packet.Packet{Header: "CT", Body: []string{"badname", "hello"}})
+	if out := conn.serverMessages(); len(out) != 1 || !strings.Contains(out[0], "blocked by the server's filter") {
+		t.Errorf("chat with a filtered name sent %q, want it blocked", out)
+	}
+
+	if err := chatFilter.Remove(1); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	forgetCheckedNames()
+	conn.out.Reset()
+	pktOOC(client, &packet.Packet{Header: "CT", Body: []string{"badname", "hello"}})
+	if out := strings.Join(conn.serverMessages(), "\n"); strings.Contains(out, "blocked") || client.OOCName() != "badname" {
+		t.Errorf("chat after removing the rule sent %q with name %q, want the name taken", out, client.OOCName())
+	}
+}
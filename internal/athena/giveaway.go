@@ -22,6 +22,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
 )
 
 // ── Timing constants ─────────────────────────────────────────────────────────
@@ -80,6 +82,10 @@ func cmdGiveaway(client *Client, args []string, usage string) {
 	}
 	switch args[0] {
 	case "start":
+		if !permissions.HasPermission(client.Perms(), subcommandPerms["giveaway start"]) {
+			client.SendServerMessage("You do not have permission to start a giveaway.")
+			return
+		}
 		if len(args) < 2 {
 			client.SendServerMessage(usage)
 			return
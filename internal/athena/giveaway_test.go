@@ -17,8 +17,12 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>. */
 package athena
 
 import (
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
 )
 
 // resetGiveawayState resets global giveaway state between tests.
@@ -115,3 +119,26 @@ func TestGiveawayOnlyOneActive(t *testing.T) {
 		t.Error("expected start to be blocked while giveaway is active")
 	}
 }
+
+// TestGiveawayStartNeedsEvents verifies that starting a giveaway requires EVENTS.
+func TestGiveawayStartNeedsEvents(t *testing.T) {
+	resetGiveawayState()
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config = &settings.Config{}
+	initCommands()
+	cleanup := setupTestAreas([]*area.Area{makeTestArea("Lobby")})
+	defer cleanup()
+
+	client, conn := newHeadlessClient("mod", 0)
+	cmdGiveaway(client, []string{"start", "cookie"}, "")
+	if out := conn.serverMessages(); len(out) != 1 || !strings.Contains(out[0], "permission") {
+		t.Errorf("expected a permission error, got %q", out)
+	}
+	giveaway.mu.Lock()
+	started := giveaway.active
+	giveaway.mu.Unlock()
+	if started {
+		t.Error("expected no giveaway to start without EVENTS")
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
 )
 
 // ── Timing constants ─────────────────────────────────────────────────────────
@@ -124,6 +126,10 @@ func cmdHotPotato(client *Client, args []string, _ string) {
 		hotPotatoAccept(client)
 		return
 	}
+	if !permissions.HasPermission(client.Perms(), subcommandPerms["hotpotato start"]) {
+		client.SendServerMessage("You do not have permission to start a Hot Potato game.")
+		return
+	}
 	hotPotatoStart(client)
 }
 
@@ -17,8 +17,15 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>. */
 package athena
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
 )
 
 // resetHotPotatoState resets global hot potato state between tests.
@@ -141,3 +148,57 @@ func TestHotPotatoOnlyOneGame(t *testing.T) {
 		}
 	}
 }
+
+// TestHotPotatoStartNeedsEvents verifies that starting a game requires EVENTS.
+func TestHotPotatoStartNeedsEvents(t *testing.T) {
+	resetHotPotatoState()
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config = &settings.Config{}
+	initCommands()
+	cleanup := setupTestAreas([]*area.Area{makeTestArea("Lobby")})
+	defer cleanup()
+
+	client, conn := newHeadlessClient("mod", 0)
+	cmdHotPotato(client, nil, "")
+	if out := conn.serverMessages(); len(out) != 1 || !strings.Contains(out[0], "permission") {
+		t.Errorf("expected a permission error, got %q", out)
+	}
+	hotPotato.mu.Lock()
+	started := hotPotato.optInActive
+	hotPotato.mu.Unlock()
+	if started {
+		t.Error("expected no game to start without EVENTS")
+	}
+}
+
+// TestHotPotatoStartOverride tests that commands.toml can change who starts games without changing who joins them
+func TestHotPotatoStartOverride(t *testing.T) {
+	resetHotPotatoState()
+	defer resetHotPotatoState()
+	oldConfig, oldPath := config, settings.ConfigPath
+	defer func() { config, settings.ConfigPath = oldConfig, oldPath }()
+	config = &settings.Config{}
+	settings.ConfigPath = t.TempDir()
+	conf := "[permissions]\n\"hotpotato start\" = [\"NONE\"]\n"
+	if err := os.WriteFile(filepath.Join(settings.ConfigPath, "commands.toml"), []byte(conf), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	initCommands()
+	defer initCommands()
+	applyCommandOverrides()
+	cleanup := setupTestAreas([]*area.Area{makeTestArea("Lobby")})
+	defer cleanup()
+
+	if got := Commands["hotpotato"].reqPerms; got != permissions.PermissionField["NONE"] {
+		t.Errorf("/hotpotato requires %v, want it unchanged", got)
+	}
+	client, _ := newHeadlessClient("", 0)
+	cmdHotPotato(client, nil, "")
+	hotPotato.mu.Lock()
+	started := hotPotato.optInActive
+	hotPotato.mu.Unlock()
+	if !started {
+		t.Error("expected a game to start once starting needs no permission")
+	}
+}
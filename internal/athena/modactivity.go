@@ -0,0 +1,112 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/xhit/go-str2duration/v2"
+)
+
+// defaultStatsPeriod is how far back /modstats looks when no period is given.
+const defaultStatsPeriod = 7 * 24 * time.Hour
+
+// recordModActivity records an activity event for a logged in client.
+// Logouts and disconnects record the length of the session.
+func recordModActivity(client *Client, event string, detail string) {
+	now := time.Now()
+	var duration int64
+	if event == db.ActivityLogout || event == db.ActivityDisconnect {
+		duration = int64(now.Sub(client.AuthTime()).Seconds())
+	}
+	if err := db.AddModActivity(client.ModName(), event, detail, now.UTC().Unix(), duration); err != nil {
+		logger.LogErrorf("Failed to record moderator activity: %v", err)
+	}
+}
+
+// modStats summarises moderator activity over a period, including the current sessions of moderators who are logged in.
+func modStats(moderator string, period time.Duration) ([]db.ModStats, error) {
+	now := time.Now()
+	since := now.Add(-period)
+	stats, err := db.GetModStats(moderator, since.UTC().Unix())
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(stats))
+	for i, s := range stats {
+		index[s.Moderator] = i
+	}
+	for c := range clients.GetAllClients() {
+		if !c.Authenticated() || (moderator != "" && c.ModName() != moderator) {
+			continue
+		}
+		start := c.AuthTime()
+		if start.Before(since) {
+			start = since
+		}
+		i, ok := index[c.ModName()]
+		if !ok {
+			stats = append(stats, db.ModStats{Moderator: c.ModName()})
+			i = len(stats) - 1
+			index[c.ModName()] = i
+		}
+		stats[i].Online += int64(now.Sub(start).Seconds())
+		stats[i].LastSeen = now.UTC().Unix()
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Moderator < stats[j].Moderator })
+	return stats, nil
+}
+
+// Handles /modstats
+func cmdModStats(client *Client, args []string, usage string) {
+	var moderator string
+	period := defaultStatsPeriod
+	periodArg := "1w"
+	for _, arg := range args {
+		if d, err := str2duration.ParseDuration(arg); err == nil && d > 0 {
+			period, periodArg = d, arg
+		} else if moderator == "" {
+			moderator = arg
+		} else {
+			client.SendServerMessage("Invalid arguments.\n" + usage)
+			return
+		}
+	}
+	stats, err := modStats(moderator, period)
+	if err != nil {
+		logger.LogErrorf("Failed to get moderator activity: %v", err)
+		client.SendServerMessage("Failed to get moderator activity.")
+		return
+	}
+	if len(stats) == 0 {
+		client.SendServerMessage(fmt.Sprintf("No moderator activity in the last %v.", periodArg))
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nModerator activity in the last %v:", periodArg)
+	for _, s := range stats {
+		fmt.Fprintf(&b, "\n%v: %.1fh online, %v logins, %v bans, %v kicks, %v mutes, %v modcalls claimed, %v other actions | Last seen %v",
+			s.Moderator, float64(s.Online)/3600, s.Logins, s.Bans, s.Kicks, s.Mutes, s.Modcalls, s.Actions,
+			time.Unix(s.LastSeen, 0).UTC().Format("02 Jan 2006 15:04 MST"))
+	}
+	client.SendServerMessage(b.String())
+}
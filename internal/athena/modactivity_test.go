@@ -0,0 +1,56 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/db"
+)
+
+// TestModStatsWindow tests that activity is counted by type, and sessions that began before the period only count from its start
+func TestModStatsWindow(t *testing.T) {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	defer db.Close()
+	now := time.Now().UTC().Unix()
+	since := now - 3600
+
+	db.AddModActivity("mod", db.ActivityLogin, "password", now-7200, 0)
+	db.AddModActivity("mod", db.ActivityBan, "Banned abc from server for 1d: spam.", now-60, 0)
+	db.AddModActivity("mod", db.ActivityAction, "Banned words are now filtered.", now-50, 0)
+	db.AddModActivity("mod", db.ActivityLogout, "", now-30, 7170) // Logged in two hours ago.
+
+	stats, err := db.GetModStats("mod", since)
+	if err != nil {
+		t.Fatalf("GetModStats() error: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("GetModStats() = %+v, want one moderator", stats)
+	}
+	s := stats[0]
+	if s.Online != 3570 {
+		t.Errorf("Online = %v, want 3570", s.Online)
+	}
+	if s.Bans != 1 || s.Actions != 1 || s.Logins != 0 {
+		t.Errorf("Bans, Actions, Logins = %v, %v, %v, want 1, 1, 0", s.Bans, s.Actions, s.Logins)
+	}
+}
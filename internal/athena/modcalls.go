@@ -0,0 +1,174 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+)
+
+// openModcall records a modcall from a client as a new ticket, returning its ID, or 0 if it could not be stored.
+func openModcall(client *Client, reason string) int {
+	id, err := db.AddModcall(db.Modcall{
+		Time:      time.Now().UTC().Unix(),
+		Area:      client.Area().Name(),
+		CallerUid: client.Uid(),
+		Caller:    client.CurrentCharacter(),
+		Ipid:      client.Ipid(),
+		Reason:    reason,
+	})
+	if err != nil {
+		logger.LogErrorf("Failed to store modcall: %v", err)
+		return 0
+	}
+	return id
+}
+
+// postModcallToBot posts a modcall ticket to the Discord bot's modcall channel, reporting whether it was posted.
+func postModcallToBot(id int) bool {
+	b := discordBot.Load()
+	if b == nil || id == 0 {
+		return false
+	}
+	m, err := db.GetModcall(id)
+	if err != nil {
+		return false
+	}
+	if err := b.PostModcall(modcallRecord(m)); err != nil {
+		logger.LogErrorf("Failed to post modcall to Discord: %v", err)
+		return false
+	}
+	return true
+}
+
+// updateModcallAlert updates the buttons on a modcall's Discord alert after it is claimed or resolved.
+func updateModcallAlert(m db.Modcall) {
+	if b := discordBot.Load(); b != nil {
+		b.UpdateModcall(modcallRecord(m))
+	}
+}
+
+// claimModcall claims a modcall ticket for a moderator, telling other moderators and the caller, if they're still online.
+func claimModcall(id int, moderator string) (db.Modcall, error) {
+	ok, err := db.ClaimModcall(id, moderator, time.Now().UTC().Unix())
+	if err != nil {
+		return db.Modcall{}, err
+	}
+	m, err := db.GetModcall(id)
+	if err != nil {
+		return db.Modcall{}, fmt.Errorf("no modcall with ID %v", id)
+	}
+	if !ok {
+		if m.Status == db.ModcallResolved {
+			return m, fmt.Errorf("modcall %v is already resolved", id)
+		}
+		return m, fmt.Errorf("modcall %v was already claimed by %v", id, m.ClaimedBy)
+	}
+	updateModcallAlert(m)
+	sendModServerMessage(fmt.Sprintf("[MODCALL] %v claimed modcall #%v from %v in %v.", moderator, id, m.Caller, m.Area))
+	if c, err := getClientByUid(m.CallerUid); err == nil && c.Ipid() == m.Ipid {
+		c.SendServerMessage("A moderator is looking into your call.")
+	}
+	return m, nil
+}
+
+// resolveModcall closes a modcall ticket with a resolution note.
+func resolveModcall(id int, moderator string, note string) (db.Modcall, error) {
+	ok, err := db.ResolveModcall(id, moderator, note, time.Now().UTC().Unix())
+	if err != nil {
+		return db.Modcall{}, err
+	}
+	m, err := db.GetModcall(id)
+	if err != nil {
+		return db.Modcall{}, fmt.Errorf("no modcall with ID %v", id)
+	}
+	if !ok {
+		return m, fmt.Errorf("modcall %v is already resolved", id)
+	}
+	updateModcallAlert(m)
+	sendModServerMessage(fmt.Sprintf("[MODCALL] %v resolved modcall #%v: %v", moderator, id, note))
+	return m, nil
+}
+
+// formatModcall returns a single-line summary of a modcall ticket.
+func formatModcall(m db.Modcall) string {
+	s := fmt.Sprintf("#%v [%v] %v | %v in %v (%v): %v", m.Id, time.Unix(m.Time, 0).UTC().Format("15:04 MST"),
+		m.Status, m.Caller, m.Area, m.Ipid, m.Reason)
+	if m.ClaimedBy != "" {
+		s += " | claimed by " + m.ClaimedBy
+	}
+	return s
+}
+
+// Handles /modcalls
+func cmdModcalls(client *Client, _ []string, _ string) {
+	calls, err := db.GetOpenModcalls()
+	if err != nil {
+		client.SendServerMessage("Failed to get modcalls.")
+		return
+	}
+	if len(calls) == 0 {
+		client.SendServerMessage("There are no open modcalls.")
+		return
+	}
+	var b strings.Builder
+	b.WriteString("\nOpen modcalls:")
+	for _, m := range calls {
+		b.WriteString("\n" + formatModcall(m))
+	}
+	client.SendServerMessage(b.String())
+}
+
+// Handles /claim
+func cmdClaim(client *Client, args []string, _ string) {
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		client.SendServerMessage("Invalid ID.")
+		return
+	}
+	m, err := claimModcall(id, client.ModName())
+	if err != nil {
+		client.SendServerMessage(fmt.Sprintf("Failed to claim modcall: %v.", err))
+		return
+	}
+	client.SendServerMessage(fmt.Sprintf("Claimed modcall #%v.", id))
+	addAuditToBuffer(client, "CMD", db.ActivityModcall, fmt.Sprintf("Claimed modcall #%v from %v (%v).", id, m.Caller, m.Ipid), m.Ipid, "",
+		map[string]string{"modcall": strconv.Itoa(id)})
+}
+
+// Handles /resolve
+func cmdResolve(client *Client, args []string, _ string) {
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		client.SendServerMessage("Invalid ID.")
+		return
+	}
+	note := strings.Join(args[1:], " ")
+	m, err := resolveModcall(id, client.ModName(), note)
+	if err != nil {
+		client.SendServerMessage(fmt.Sprintf("Failed to resolve modcall: %v.", err))
+		return
+	}
+	client.SendServerMessage(fmt.Sprintf("Resolved modcall #%v.", id))
+	addAuditToBuffer(client, "CMD", db.ActivityAction, fmt.Sprintf("Resolved modcall #%v from %v (%v): %v", id, m.Caller, m.Ipid, note), m.Ipid, note,
+		map[string]string{"modcall": strconv.Itoa(id)})
+}
@@ -0,0 +1,134 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+)
+
+// setupModcallTest opens a temporary database and sets up an area with an online caller,
+// returning the caller's connection and a cleanup function.
+func setupModcallTest(t *testing.T) (*Client, *headlessConn, func()) {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	oldConfig := config
+	config = &settings.Config{}
+	cleanupAreas := setupTestAreas([]*area.Area{makeTestArea("Lobby")})
+	caller, conn := newHeadlessClient("", 0)
+	caller.SetUid(4)
+	clients.AddClient(caller)
+	return caller, conn, func() {
+		clients.RemoveClient(caller)
+		cleanupAreas()
+		config = oldConfig
+		db.Close()
+	}
+}
+
+// TestClaimModcall tests that a modcall can only be claimed once, and that the caller is told it was
+func TestClaimModcall(t *testing.T) {
+	caller, conn, cleanup := setupModcallTest(t)
+	defer cleanup()
+	id := openModcall(caller, "help")
+	if id == 0 {
+		t.Fatal("openModcall() failed")
+	}
+
+	m, err := claimModcall(id, "mod1")
+	if err != nil || m.Status != db.ModcallClaimed || m.ClaimedBy != "mod1" {
+		t.Fatalf("claimModcall() = %+v, %v, want it claimed by mod1", m, err)
+	}
+	if out := conn.serverMessages(); len(out) != 1 || out[0] != "A moderator is looking into your call." {
+		t.Errorf("caller was sent %q, want the claim notice", out)
+	}
+	if _, err := claimModcall(id, "mod2"); err == nil || !strings.Contains(err.Error(), "already claimed by mod1") {
+		t.Errorf("claiming a claimed modcall returned %v", err)
+	}
+	if _, err := claimModcall(id+1, "mod2"); err == nil {
+		t.Error("claiming a missing modcall succeeded")
+	}
+}
+
+// TestClaimModcallReusedUID tests that the claim notice isn't sent to a different player who has since taken the caller's UID
+func TestClaimModcallReusedUID(t *testing.T) {
+	caller, conn, cleanup := setupModcallTest(t)
+	defer cleanup()
+	id := openModcall(caller, "help")
+	clients.RemoveClient(caller)
+
+	otherConn := &headlessConn{}
+	other := NewClient(otherConn, "otheripid")
+	other.SetArea(areas[0])
+	other.SetUid(caller.Uid())
+	clients.AddClient(other)
+	defer clients.RemoveClient(other)
+
+	if _, err := claimModcall(id, "mod1"); err != nil {
+		t.Fatalf("claimModcall() error: %v", err)
+	}
+	if out := otherConn.serverMessages(); len(out) != 0 {
+		t.Errorf("player who reused the UID was sent %q", out)
+	}
+	if out := conn.serverMessages(); len(out) != 0 {
+		t.Errorf("caller who left was sent %q", out)
+	}
+}
+
+// TestResolveModcall tests that resolving claims an unclaimed modcall, and that a resolved modcall can't be claimed or resolved again
+func TestResolveModcall(t *testing.T) {
+	caller, _, cleanup := setupModcallTest(t)
+	defer cleanup()
+	id := openModcall(caller, "help")
+
+	m, err := resolveModcall(id, "mod1", "warned them")
+	if err != nil {
+		t.Fatalf("resolveModcall() error: %v", err)
+	}
+	if m.Status != db.ModcallResolved || m.ResolvedBy != "mod1" || m.ClaimedBy != "mod1" || m.ClaimedAt == 0 || m.Resolution != "warned them" {
+		t.Errorf("resolveModcall() = %+v, want it resolved and claimed by mod1", m)
+	}
+	if _, err := resolveModcall(id, "mod2", "again"); err == nil || !strings.Contains(err.Error(), "already resolved") {
+		t.Errorf("resolving a resolved modcall returned %v", err)
+	}
+	if _, err := claimModcall(id, "mod2"); err == nil || !strings.Contains(err.Error(), "already resolved") {
+		t.Errorf("claiming a resolved modcall returned %v", err)
+	}
+	if m, _ := db.GetModcall(id); m.ResolvedBy != "mod1" || m.Resolution != "warned them" {
+		t.Errorf("modcall after second resolve = %+v, want the first resolution kept", m)
+	}
+}
+
+// TestResolveClaimedModcall tests that resolving a claimed modcall keeps the moderator who claimed it
+func TestResolveClaimedModcall(t *testing.T) {
+	caller, _, cleanup := setupModcallTest(t)
+	defer cleanup()
+	id := openModcall(caller, "help")
+	if _, err := claimModcall(id, "mod1"); err != nil {
+		t.Fatalf("claimModcall() error: %v", err)
+	}
+	if m, err := resolveModcall(id, "mod2", "done"); err != nil || m.ClaimedBy != "mod1" || m.ResolvedBy != "mod2" {
+		t.Errorf("resolveModcall() = %+v, %v, want claimed by mod1 and resolved by mod2", m, err)
+	}
+}
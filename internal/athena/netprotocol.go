@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/MangosArentLiterature/Athena/internal/area"
 	"github.com/MangosArentLiterature/Athena/internal/db"
@@ -37,8 +38,9 @@ import (
 // Documentation for AO2's network protocol can be found here:
 // https://github.com/AttorneyOnline/docs/blob/master/docs/development/network.md
 
-// commandRegex matches valid command names (e.g., /join, /join-tournament), case-insensitively.
-var commandRegex = regexp.MustCompile(`(?i)^/[a-z]+(-[a-z]+)*`)
+// commandRegex matches valid command names (e.g., /join, /join-tournament, /2fa), case-insensitively.
+// Names may contain digits, but not only digits.
+var commandRegex = regexp.MustCompile(`(?i)^/[0-9]*[a-z][a-z0-9]*(-[a-z0-9]+)*`)
 
 type pktMapValue struct {
 	Args     int
@@ -131,6 +133,7 @@ func pktReqDone(client *Client, _ *packet.Packet) {
 		return
 	}
 	client.SetUid(uids.GetUid())
+	client.releasePending()
 	players.AddPlayer()
 	if config.Advertise {
 		updatePlayers <- players.GetPlayerCount()
@@ -148,6 +151,8 @@ func pktReqDone(client *Client, _ *packet.Packet) {
 		client.SendServerMessage(config.Motd)
 	}
 	logger.LogInfof("Client (IPID:%v UID:%v) joined the server", client.Ipid(), client.Uid())
+	feedEvent(eventJoin, fmt.Sprintf("UID %v joined the server (%v online).", client.Uid(), players.GetPlayerCount()))
+	sendWebhook(hookJoin, map[string]interface{}{"uid": client.Uid(), "ipid": client.Ipid(), "players": players.GetPlayerCount()})
 }
 
 // getRandomFreeChar returns a random free character ID in the client's area,
@@ -207,6 +212,17 @@ func pktIC(client *Client, p *packet.Packet) {
 	args = append(args[:19], args[17:]...)
 	args = append(args[:20], args[18:]...)
 
+	var ok bool
+	if args[4], ok = filterEncoded(client, "IC", args[4]); !ok {
+		return
+	}
+	if args[15], ok = filterEncoded(client, "showname", args[15]); !ok {
+		return
+	}
+	if !checkSpam(client, "IC", decode(args[4])) {
+		return
+	}
+
 	// Save the admin's own args before any fullpossess transformation so that
 	// state updates (showname, pairInfo, textColor) always reflect the admin's
 	// own character — not the target's — even during fullpossess.
@@ -584,6 +600,7 @@ func pktIC(client *Client, p *packet.Packet) {
 	}
 
 	writeToArea(client.Area(), "MS", args...)
+	recordTranscript(client.Area(), args)
 	addToBuffer(client, "IC", "\""+args[4]+"\"", false)
 }
 
@@ -700,16 +717,35 @@ func pktOOC(client *Client, p *packet.Packet) {
 	} else if strings.TrimSpace(p.Body[1]) == "" {
 		return
 	}
-	for c := range clients.GetAllClients() {
-		if c.OOCName() == p.Body[0] && c != client {
+	if oocNameTaken(client, username) {
+		client.SendServerMessage("That username is already taken.")
+		return
+	}
+	// The name is sent with every message, so it is only filtered when it changes.
+	// Otherwise a filtered name would repeat the filter's action and mod notice on each message.
+	// Commands only check the name without acting on it, so a filtered name can't stop commands like /login and /help.
+	isCommand := strings.HasPrefix(p.Body[1], "/")
+	filtered, ok, checked := client.CheckedOOCName(username)
+	switch {
+	case !checked && isCommand:
+		filtered, ok = checkFilter(username)
+	case !checked:
+		filtered, ok = filterText(client, "OOC name", username)
+		client.SetCheckedOOCName(username, filtered, ok)
+	case !ok && !isCommand:
+		client.SendServerMessage("Your username was blocked by the server's filter.")
+	}
+	if ok {
+		if filtered != username && oocNameTaken(client, filtered) {
 			client.SendServerMessage("That username is already taken.")
 			return
 		}
-	}
-	client.SetOocName(username)
-
-	if client.Uid() != -1 {
-		writeToAll("PU", strconv.Itoa(client.Uid()), "0", username)
+		client.SetOocName(filtered)
+		if client.Uid() != -1 {
+			writeToAll("PU", strconv.Itoa(client.Uid()), "0", filtered)
+		}
+	} else if !isCommand {
+		return
 	}
 
 	if strings.HasPrefix(p.Body[1], "/") {
@@ -723,8 +759,26 @@ func pktOOC(client *Client, p *packet.Packet) {
 		client.SendServerMessage("You are muted from speaking in OOC.")
 		return
 	}
-	writeToArea(client.Area(), "CT", encode(client.OOCName()), p.Body[1], "0")
-	addToBuffer(client, "OOC", "\""+p.Body[1]+"\"", false)
+	msg, ok := filterEncoded(client, "OOC", p.Body[1])
+	if !ok {
+		return
+	}
+	if !checkSpam(client, "OOC", decode(msg)) {
+		return
+	}
+	writeToArea(client.Area(), "CT", encode(client.OOCName()), msg, "0")
+	relayOOC(client, msg)
+	addToBuffer(client, "OOC", "\""+msg+"\"", false)
+}
+
+// oocNameTaken returns whether another client is using an OOC name, compared in the decoded form names are stored in.
+func oocNameTaken(client *Client, name string) bool {
+	for c := range clients.GetAllClients() {
+		if c.OOCName() == name && c != client {
+			return true
+		}
+	}
+	return false
 }
 
 // Handles PE#%
@@ -788,20 +842,37 @@ func pktModcall(client *Client, p *packet.Packet) {
 	if len(p.Body) >= 1 {
 		s = p.Body[0]
 	}
-	addToBuffer(client, "MOD", fmt.Sprintf("Called moderator for reason: %v", s), false)
+	id := openModcall(client, s)
+	addToBuffer(client, "MOD", fmt.Sprintf("Called moderator (ticket #%v) for reason: %v", id, s), false)
+	notes := modcallNotes(client.Ipid())
 	for c := range clients.GetAllClients() {
 		if c.Authenticated() && permissions.IsModerator(c.Perms()) {
-			c.SendPacket("ZZ", fmt.Sprintf("MODCALL\n----------\nArea: %v\nUser: [%v] %v\nIPID: %v\nReason: %v",
-				client.Area().Name(), client.Uid(), client.CurrentCharacter(), client.Ipid(), s))
+			c.SendPacket("ZZ", fmt.Sprintf("MODCALL #%v\n----------\nArea: %v\nUser: [%v] %v\nIPID: %v\nReason: %v%v\nClaim with /claim %v",
+				id, client.Area().Name(), client.Uid(), client.CurrentCharacter(), client.Ipid(), s, notes, id))
 		}
 	}
-	if enableDiscord {
-		err := webhook.PostModcall(client.CurrentCharacter(), client.Area().Name(), s)
-		if err != nil {
-			logger.LogError(err.Error())
+	// The bot's alert has moderation buttons, so the webhook is only used if the bot can't post it.
+	// Both are posted in the background, so a slow or rate limited Discord doesn't hold up the caller.
+	character, areaName := client.CurrentCharacter(), client.Area().Name()
+	go func() {
+		if !postModcallToBot(id) && enableDiscord {
+			err := webhook.PostModcall(character, areaName, fmt.Sprintf("[#%v] %v", id, s))
+			if err != nil {
+				logger.LogError(err.Error())
+			}
 		}
-	}
-	logger.WriteReport(client.Area().Name(), client.Area().Buffer())
+	}()
+	sendWebhook(hookModcall, map[string]interface{}{
+		"id":        id,
+		"uid":       client.Uid(),
+		"ipid":      client.Ipid(),
+		"character": client.CurrentCharacter(),
+		"area":      client.Area().Name(),
+		"reason":    s,
+	})
+	buffer := client.Area().Buffer()
+	sendWebhook(hookReport, map[string]interface{}{"modcall": id, "area": client.Area().Name(), "log": buffer})
+	logger.WriteReport(client.Area().Name(), buffer)
 }
 
 // Handles SETCASE#%
@@ -816,8 +887,12 @@ func pktSetCase(client *Client, p *packet.Packet) {
 		}
 		client.SetRoleAlert(i, b)
 	}
+	saveCaseAlerts(client)
 }
 
+// caseCooldown limits each area to one case alert a minute.
+var caseCooldown = newAreaCooldown(time.Minute)
+
 // Handles CASEA#%
 func pktCaseAnn(client *Client, p *packet.Packet) {
 	// Let future generations know I spent far too long trying to make this work.
@@ -827,6 +902,19 @@ func pktCaseAnn(client *Client, p *packet.Packet) {
 		client.SendServerMessage("You are not allowed to send case alerts in this area.")
 		return
 	}
+	if wait := caseCooldown.start(client.Area(), time.Now()); wait > 0 {
+		client.SendServerMessage(fmt.Sprintf("Please wait %v before sending another case alert in this area.", wait.Round(time.Second)))
+		return
+	}
+	var roles int
+	for i, r := range p.Body[1:] {
+		if i >= 4 {
+			break
+		}
+		if b, err := strconv.ParseBool(r); err == nil && b {
+			roles |= 1 << i
+		}
+	}
 	newPacket := fmt.Sprintf("CASEA#CASE ANNOUNCEMENT: %v in %v needs players for %v#%v#1#%%",
 		client.CurrentCharacter(), client.Area().Name(), p.Body[0], strings.Join(p.Body[1:], "#")) // Due to a bug, old client versions require this packet to have an extra arg.
 
@@ -848,6 +936,10 @@ func pktCaseAnn(client *Client, p *packet.Packet) {
 			}
 		}
 	}
+	feedEvent(eventCase, fmt.Sprintf("%v in %v needs players for %v.",
+		feedName(client.CurrentCharacter()), client.Area().Name(), feedName(decode(p.Body[0]))))
+	notifyLinkedPlayers("📣 Case Announcement", fmt.Sprintf("%v in %v needs players for %v.",
+		client.CurrentCharacter(), client.Area().Name(), decode(p.Body[0])), roles)
 }
 
 // decoder and encoder are package-level, pre-compiled replacers for the AO2 percent-encoding scheme.
@@ -0,0 +1,105 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+)
+
+// modcallNoteLimit is the number of recent notes included in a modcall.
+const modcallNoteLimit = 3
+
+// resolveNoteTarget returns the IPID referred to by a UID of an online client, or the argument itself as an IPID.
+func resolveNoteTarget(s string) string {
+	if uid, err := strconv.Atoi(s); err == nil {
+		if c, err := getClientByUid(uid); err == nil {
+			return c.Ipid()
+		}
+	}
+	return s
+}
+
+// formatNote returns a single-line representation of a note.
+func formatNote(n db.Note) string {
+	return fmt.Sprintf("[%v] %v: %v", time.Unix(n.Time, 0).UTC().Format("02 Jan 2006"), n.Author, n.Text)
+}
+
+// modcallNotes returns the note summary shown to moderators in a modcall, or an empty string if the IPID has no notes.
+func modcallNotes(ipid string) string {
+	notes, err := db.GetNotes(ipid)
+	if err != nil {
+		logger.LogErrorf("Failed to get notes for %v: %v", ipid, err)
+		return ""
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	s := fmt.Sprintf("\nNotes (%v):", len(notes))
+	if len(notes) > modcallNoteLimit {
+		notes = notes[len(notes)-modcallNoteLimit:]
+	}
+	for _, n := range notes {
+		s += "\n- " + formatNote(n)
+	}
+	return s
+}
+
+// Handles /note
+func cmdNote(client *Client, args []string, usage string) {
+	ipid := resolveNoteTarget(args[1])
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			client.SendServerMessage("Not enough arguments:\n" + usage)
+			return
+		}
+		text := strings.Join(args[2:], " ")
+		if _, err := db.AddNote(ipid, client.ModName(), time.Now().UTC().Unix(), text); err != nil {
+			logger.LogErrorf("Failed to add note: %v", err)
+			client.SendServerMessage("Failed to add note.")
+			return
+		}
+		client.SendServerMessage(fmt.Sprintf("Added note on %v.", ipid))
+		addAuditToBuffer(client, "CMD", db.ActivityAction, fmt.Sprintf("Added note on %v: %v", ipid, text), ipid, "", nil)
+
+	case "list":
+		notes, err := db.GetNotes(ipid)
+		if err != nil {
+			client.SendServerMessage("Failed to get notes.")
+			return
+		}
+		if len(notes) == 0 {
+			client.SendServerMessage(fmt.Sprintf("No notes on %v.", ipid))
+			return
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "\nNotes on %v:", ipid)
+		for _, n := range notes {
+			b.WriteString("\n" + formatNote(n))
+		}
+		client.SendServerMessage(b.String())
+
+	default:
+		client.SendServerMessage("Invalid subcommand:\n" + usage)
+	}
+}
@@ -0,0 +1,132 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+)
+
+// setupNoteTest opens a temporary database and log directory, and sets up an area for clients.
+// It returns a cleanup function that flushes the audit log and restores the originals.
+func setupNoteTest(t *testing.T) func() {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	oldConfig, oldLogPath := config, logger.LogPath
+	config = &settings.Config{}
+	logger.LogPath = t.TempDir()
+	cleanupAreas := setupTestAreas([]*area.Area{makeTestArea("Lobby")})
+	return func() {
+		// Reading the audit log also flushes it before the log directory is removed.
+		logger.QueryAudit(logger.AuditQuery{})
+		cleanupAreas()
+		config, logger.LogPath = oldConfig, oldLogPath
+		db.Close()
+	}
+}
+
+// TestResolveNoteTarget tests that online UIDs resolve to their IPID, and anything else is taken as an IPID
+func TestResolveNoteTarget(t *testing.T) {
+	defer setupTestAreas([]*area.Area{makeTestArea("Lobby")})()
+	player, _ := newHeadlessClient("", 0)
+	player.SetUid(7)
+	clients.AddClient(player)
+	defer clients.RemoveClient(player)
+
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"7", player.Ipid()},
+		{"8", "8"}, // No client has this UID.
+		{"abc123", "abc123"},
+	}
+	for _, tc := range tests {
+		if got := resolveNoteTarget(tc.arg); got != tc.want {
+			t.Errorf("resolveNoteTarget(%q) = %q, want %q", tc.arg, got, tc.want)
+		}
+	}
+}
+
+// TestNoteCommand tests that notes added by UID can be listed by IPID, attributed to the moderator
+func TestNoteCommand(t *testing.T) {
+	defer setupNoteTest(t)()
+	player, _ := newHeadlessClient("", 0)
+	player.SetUid(3)
+	clients.AddClient(player)
+	defer clients.RemoveClient(player)
+	mod, conn := newHeadlessClient("mod1", permissions.PermissionField["BAN_INFO"])
+
+	cmdNote(mod, []string{"list", player.Ipid()}, "")
+	cmdNote(mod, []string{"add", "3", "alt", "of", "a", "banned", "player"}, "")
+	cmdNote(mod, []string{"add", "3"}, "usage")
+	cmdNote(mod, []string{"list", player.Ipid()}, "")
+	out := conn.serverMessages()
+	if len(out) != 4 {
+		t.Fatalf("/note sent %q, want 4 messages", out)
+	}
+	if !strings.HasPrefix(out[0], "No notes on") {
+		t.Errorf("/note list with no notes sent %q", out[0])
+	}
+	if out[1] != fmt.Sprintf("Added note on %v.", player.Ipid()) {
+		t.Errorf("/note add sent %q", out[1])
+	}
+	if !strings.Contains(out[2], "usage") {
+		t.Errorf("/note add without text sent %q, want the usage", out[2])
+	}
+	if !strings.Contains(out[3], "mod1: alt of a banned player") {
+		t.Errorf("/note list sent %q, want the note", out[3])
+	}
+}
+
+// TestModcallNotes tests that modcalls show the number of notes and only the most recent ones
+func TestModcallNotes(t *testing.T) {
+	defer setupNoteTest(t)()
+	if s := modcallNotes("abc"); s != "" {
+		t.Errorf("modcallNotes() with no notes = %q, want empty", s)
+	}
+	now := time.Now().UTC().Unix()
+	for i := 1; i <= modcallNoteLimit+1; i++ {
+		if _, err := db.AddNote("abc", "mod", now+int64(i), "note "+strconv.Itoa(i)); err != nil {
+			t.Fatalf("AddNote() error: %v", err)
+		}
+	}
+	s := modcallNotes("abc")
+	if !strings.HasPrefix(s, fmt.Sprintf("\nNotes (%v):", modcallNoteLimit+1)) {
+		t.Errorf("modcallNotes() = %q, want the number of notes", s)
+	}
+	if strings.Contains(s, "note 1") || !strings.Contains(s, fmt.Sprintf("note %v", modcallNoteLimit+1)) {
+		t.Errorf("modcallNotes() = %q, want only the %v most recent notes", s, modcallNoteLimit)
+	}
+
+	notes := (&ServerAdapter{}).GetNotes("abc")
+	if len(notes) != modcallNoteLimit+1 || notes[0].Author != "mod" || notes[0].Text != "note 1" {
+		t.Errorf("GetNotes() = %+v, want every note, oldest first", notes)
+	}
+}
@@ -0,0 +1,222 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/xhit/go-str2duration/v2"
+)
+
+// collectBanTargets snapshots the IPID/HDID pairs a ban would apply to, so it can be carried out after the targets disconnect.
+func collectBanTargets(uids []string, ipids []string) []db.BanTarget {
+	var targets []db.BanTarget
+	seen := make(map[db.BanTarget]struct{})
+	add := func(t db.BanTarget) {
+		if _, ok := seen[t]; ok {
+			return
+		}
+		seen[t] = struct{}{}
+		targets = append(targets, t)
+	}
+	if len(uids) > 0 {
+		for _, c := range getUidList(uids) {
+			add(db.BanTarget{Ipid: c.Ipid(), Hdid: c.Hdid()})
+		}
+		return targets
+	}
+	for _, ipid := range ipids {
+		online := getClientsByIpid(ipid)
+		if len(online) == 0 {
+			add(db.BanTarget{Ipid: ipid})
+			continue
+		}
+		for _, c := range online {
+			add(db.BanTarget{Ipid: c.Ipid(), Hdid: c.Hdid()})
+		}
+	}
+	return targets
+}
+
+// banTargetIpids returns the unique IPIDs in a list of ban targets.
+func banTargetIpids(targets []db.BanTarget) []string {
+	var ipids []string
+	for _, t := range targets {
+		found := false
+		for _, s := range ipids {
+			if s == t.Ipid {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ipids = append(ipids, t.Ipid)
+		}
+	}
+	return ipids
+}
+
+// applyBanTargets bans each target and kicks any of their clients still online, returning the number of bans added.
+func applyBanTargets(targets []db.BanTarget, until int64, reason string, moderator string) int {
+	var untilS string
+	if until == -1 {
+		untilS = "∞"
+	} else {
+		untilS = time.Unix(until, 0).UTC().Format("02 Jan 2006 15:04 MST")
+	}
+	banTime := time.Now().UTC().Unix()
+	var count int
+	for _, t := range targets {
+		id, err := db.AddBan(t.Ipid, t.Hdid, banTime, until, reason, moderator)
+		if err != nil {
+			logger.LogErrorf("Failed to add ban for %v: %v", t.Ipid, err)
+			continue
+		}
+		count++
+		feedEvent(eventBan, fmt.Sprintf("%v banned a player until %v (ban ID %v): %v", feedName(moderator), untilS, id, feedName(reason)))
+		sendBanWebhook(moderator, []string{t.Ipid}, until, reason)
+		for _, c := range getClientsByIpid(t.Ipid) {
+			if t.Hdid != "" && c.Hdid() != t.Hdid {
+				continue
+			}
+			c.SendPacket("KB", fmt.Sprintf("%v\nUntil: %v\nID: %v", reason, untilS, id))
+			c.conn.Close()
+		}
+	}
+	sendPlayerArup()
+	return count
+}
+
+// addPendingBan records a permanent ban of the targets as a pending action that another moderator must approve,
+// and notifies online moderators. It returns the pending action's ID.
+func addPendingBan(targets []db.BanTarget, reason string, moderator string) (int, error) {
+	expiry, err := str2duration.ParseDuration(config.PendingExpiry)
+	if err != nil {
+		expiry = 24 * time.Hour
+	}
+	now := time.Now().UTC()
+	id, err := db.AddPendingAction(db.PendingAction{
+		Action:    "ban",
+		Targets:   targets,
+		Duration:  -1,
+		Reason:    reason,
+		Moderator: moderator,
+		Created:   now.Unix(),
+		Expires:   now.Add(expiry).Unix(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	sendModServerMessage(fmt.Sprintf("[PENDING] %v requested a permanent ban of %v for: %v. Approve with /approve %v.",
+		moderator, strings.Join(banTargetIpids(targets), ", "), reason, id))
+	return id, nil
+}
+
+// requestPermaBan records a permanent ban as a pending action that another moderator must approve.
+func requestPermaBan(client *Client, uids []string, ipids []string, reason string) {
+	targets := collectBanTargets(uids, ipids)
+	if len(targets) == 0 {
+		client.SendServerMessage("Failed to ban: No valid targets.")
+		return
+	}
+	id, err := addPendingBan(targets, reason, client.ModName())
+	if err != nil {
+		logger.LogErrorf("Failed to add pending action: %v", err)
+		client.SendServerMessage("Failed to create pending ban.")
+		return
+	}
+	report := strings.Join(banTargetIpids(targets), ", ")
+	client.SendServerMessage(fmt.Sprintf("You lack permission to permanently ban directly. Created pending action %v, which another moderator must approve with /approve %v.", id, id))
+	addAuditToBuffer(client, "CMD", db.ActivityAction, fmt.Sprintf("Requested permanent ban of %v (pending action %v): %v.", report, id, reason), report, reason,
+		map[string]string{"pending_action": strconv.Itoa(id)})
+}
+
+// Handles /approve
+func cmdApprove(client *Client, args []string, usage string) {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		client.SendServerMessage("Invalid ID.")
+		return
+	}
+	p, err := db.GetPendingAction(id)
+	if err != nil {
+		client.SendServerMessage("No pending action with that ID.")
+		return
+	}
+	if p.Status != db.PendingOpen {
+		client.SendServerMessage(fmt.Sprintf("Pending action %v is already %v.", id, p.Status))
+		return
+	}
+	if time.Now().UTC().Unix() >= p.Expires {
+		db.ResolvePendingAction(id, db.PendingExpired, "")
+		client.SendServerMessage(fmt.Sprintf("Pending action %v has expired.", id))
+		return
+	}
+	if p.Moderator == client.ModName() {
+		client.SendServerMessage("You cannot approve your own action.")
+		return
+	}
+
+	switch p.Action {
+	case "ban":
+		// Only the moderator whose update resolves the action applies it, so concurrent approvals can't ban twice.
+		ok, err := db.ResolvePendingAction(id, db.PendingApproved, client.ModName())
+		if err != nil {
+			client.SendServerMessage("Failed to approve action.")
+			return
+		} else if !ok {
+			client.SendServerMessage(fmt.Sprintf("Pending action %v has already been resolved.", id))
+			return
+		}
+		count := applyBanTargets(p.Targets, p.Duration, p.Reason, fmt.Sprintf("%v (approved by %v)", p.Moderator, client.ModName()))
+		report := strings.Join(banTargetIpids(p.Targets), ", ")
+		client.SendServerMessage(fmt.Sprintf("Approved pending action %v. Added %v ban(s).", id, count))
+		sendModServerMessage(fmt.Sprintf("[PENDING] %v approved %v's permanent ban of %v.", client.ModName(), p.Moderator, report))
+		addAuditToBuffer(client, "CMD", db.ActivityBan, fmt.Sprintf("Approved pending action %v: permanent ban of %v by %v for: %v.", id, report, p.Moderator, p.Reason), report, p.Reason,
+			map[string]string{"pending_action": strconv.Itoa(id), "requested_by": p.Moderator})
+	default:
+		client.SendServerMessage(fmt.Sprintf("Unknown action type %v.", p.Action))
+	}
+}
+
+// Handles /pending
+func cmdPending(client *Client, _ []string, _ string) {
+	if _, err := db.ExpirePendingActions(); err != nil {
+		logger.LogErrorf("Failed to expire pending actions: %v", err)
+	}
+	actions, err := db.GetPendingActions()
+	if err != nil {
+		client.SendServerMessage("Failed to get pending actions.")
+		return
+	}
+	if len(actions) == 0 {
+		client.SendServerMessage("No actions are awaiting approval.")
+		return
+	}
+	var b strings.Builder
+	b.WriteString("\nPending actions:")
+	for _, p := range actions {
+		fmt.Fprintf(&b, "\n%v: %v %v by %v for: %v (expires %v)", p.Id, p.Action, strings.Join(banTargetIpids(p.Targets), ", "),
+			p.Moderator, p.Reason, time.Unix(p.Expires, 0).UTC().Format("02 Jan 2006 15:04 MST"))
+	}
+	client.SendServerMessage(b.String())
+}
@@ -0,0 +1,126 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+)
+
+// TestCollectOfflineBanTargets tests that offline IPIDs are snapshotted without an HDID
+func TestCollectOfflineBanTargets(t *testing.T) {
+	targets := collectBanTargets(nil, []string{"offline1", "offline2", "offline1"})
+	if len(targets) != 2 {
+		t.Fatalf("Expected 2 unique targets, got %v", targets)
+	}
+	for _, tgt := range targets {
+		if tgt.Hdid != "" {
+			t.Errorf("Offline target %v should have no HDID", tgt.Ipid)
+		}
+	}
+}
+
+// TestBanTargetIpids tests that IPIDs are deduplicated across HDIDs
+func TestBanTargetIpids(t *testing.T) {
+	targets := []db.BanTarget{
+		{Ipid: "a", Hdid: "h1"},
+		{Ipid: "a", Hdid: "h2"},
+		{Ipid: "b", Hdid: ""},
+	}
+	got := banTargetIpids(targets)
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("banTargetIpids() = %v, want [a b]", got)
+	}
+}
+
+// TestResolvePendingActionOnce tests that only the first approval of a pending action resolves it
+func TestResolvePendingActionOnce(t *testing.T) {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	defer db.Close()
+	now := time.Now().UTC()
+	id, err := db.AddPendingAction(db.PendingAction{
+		Action:    "ban",
+		Targets:   []db.BanTarget{{Ipid: "a"}},
+		Duration:  -1,
+		Moderator: "mod1",
+		Created:   now.Unix(),
+		Expires:   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("AddPendingAction() error: %v", err)
+	}
+	if ok, err := db.ResolvePendingAction(id, db.PendingApproved, "mod2"); !ok || err != nil {
+		t.Fatalf("First ResolvePendingAction() = %v, %v, want true", ok, err)
+	}
+	if ok, err := db.ResolvePendingAction(id, db.PendingApproved, "mod3"); ok || err != nil {
+		t.Errorf("Second ResolvePendingAction() = %v, %v, want false", ok, err)
+	}
+	if p, _ := db.GetPendingAction(id); p.Reviewer != "mod2" {
+		t.Errorf("Reviewer = %q, want mod2", p.Reviewer)
+	}
+}
+
+// TestDiscordPendingBanSelfApproval tests that a ban requested on Discord is attributed to the linked account, which can't approve it in game
+func TestDiscordPendingBanSelfApproval(t *testing.T) {
+	db.DBPath = filepath.Join(t.TempDir(), "athena.db")
+	if err := db.Open(); err != nil {
+		t.Fatalf("db.Open() error: %v", err)
+	}
+	defer db.Close()
+	oldConfig, oldLogPath := config, logger.LogPath
+	defer func() { config, logger.LogPath = oldConfig, oldLogPath }()
+	config = &settings.Config{}
+	logger.LogPath = t.TempDir()
+	defer setupTestAreas([]*area.Area{makeTestArea("Lobby")})()
+
+	a := &ServerAdapter{}
+	if _, err := a.RequestPermaBan("999", "abc", "spam"); err == nil {
+		t.Error("RequestPermaBan() from an unlinked Discord user succeeded")
+	}
+	if err := db.AddDiscordLink(db.DiscordLink{DiscordID: "999", Kind: db.LinkAccount, Target: "mod1"}); err != nil {
+		t.Fatalf("AddDiscordLink() error: %v", err)
+	}
+	id, err := a.RequestPermaBan("999", "abc", "spam")
+	if err != nil {
+		t.Fatalf("RequestPermaBan() error: %v", err)
+	}
+	if p, _ := db.GetPendingAction(id); p.Moderator != "mod1" {
+		t.Errorf("pending action moderator = %q, want the linked account mod1", p.Moderator)
+	}
+
+	client, conn := newHeadlessClient("mod1", permissions.PermissionField["BAN"])
+	cmdApprove(client, []string{strconv.Itoa(id)}, "")
+	if out := conn.serverMessages(); len(out) != 1 || !strings.Contains(out[0], "cannot approve your own action") {
+		t.Errorf("/approve by the requester sent %q", out)
+	}
+	// Reading the audit log also flushes it before the log directory is removed.
+	if _, err := logger.QueryAudit(logger.AuditQuery{}); err != nil {
+		t.Errorf("QueryAudit() error: %v", err)
+	}
+}
@@ -97,6 +97,8 @@ func TestCommandRegexCaseInsensitive(t *testing.T) {
 		{"/pOs", "pos", true},
 		{"/join-tournament", "join-tournament", true},
 		{"/JOIN-TOURNAMENT", "join-tournament", true},
+		{"/2fa", "2fa", true},
+		{"/2FA enable", "2fa", true},
 		{"notacommand", "", false},
 		{"/123", "", false},
 	}
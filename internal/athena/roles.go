@@ -0,0 +1,209 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MangosArentLiterature/Athena/internal/db"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+)
+
+// resolveUserRole returns the role name and permissions a user logs in with.
+// Permissions come from the user's role, so role edits apply to existing users. Users whose role no longer exists
+// keep their stored permissions, and users created before roles were stored are matched to a role by their permissions.
+func resolveUserRole(username string, roleName string, stored uint64) (string, uint64) {
+	if roleName != "" {
+		if role, err := getRole(roleName); err == nil {
+			return role.Name, role.GetPermissions()
+		}
+		logger.LogWarningf("User %v has role %v, which no longer exists. Using their stored permissions.", username, roleName)
+		return roleName, stored
+	}
+	rolesMu.RLock()
+	defer rolesMu.RUnlock()
+	for _, role := range roles {
+		if role.GetPermissions() == stored {
+			if err := db.ChangeRole(username, role.Name, stored); err != nil {
+				logger.LogErrorf("Failed to record role of %v: %v", username, err)
+			}
+			return role.Name, stored
+		}
+	}
+	return "", stored
+}
+
+// parsePermissionList parses a comma-separated list of permission names.
+func parsePermissionList(s string) ([]string, error) {
+	var perms []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if _, ok := permissions.PermissionField[p]; !ok {
+			return nil, fmt.Errorf("unknown permission %v", p)
+		}
+		perms = append(perms, p)
+	}
+	if len(perms) == 0 {
+		return nil, fmt.Errorf("no permissions given")
+	}
+	return perms, nil
+}
+
+// splitPermissions are the permissions that commands needing MUTE, KICK or no permission were moved to.
+var splitPermissions = []string{"PUNISH", "EVENTS", "ANNOUNCE", "FORCE_PAIR"}
+
+// outdatedRoles returns the roles that grant MUTE but none of splitPermissions. These are likely from a roles.toml
+// written before the permissions were added, and their members have lost the commands that moved to them.
+func outdatedRoles(roles []permissions.Role) []string {
+	var names []string
+	for _, r := range roles {
+		perms := r.GetPermissions()
+		if !permissions.HasPermission(perms, permissions.PermissionField["MUTE"]) {
+			continue
+		}
+		outdated := true
+		for _, p := range splitPermissions {
+			if permissions.HasPermission(perms, permissions.PermissionField[p]) {
+				outdated = false
+				break
+			}
+		}
+		if outdated {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// applyRoleChange refreshes the permissions of everyone with the given role, both in the database and online.
+func applyRoleChange(name string, perms uint64) {
+	if err := db.UpdateRolePermissions(name, perms); err != nil {
+		logger.LogErrorf("Failed to update permissions of users with role %v: %v", name, err)
+	}
+	for c := range clients.GetAllClients() {
+		if c.Authenticated() && c.ModRole() == name {
+			c.SetPerms(perms)
+			c.SendServerMessage(fmt.Sprintf("Your role (%v) has been updated.", name))
+		}
+	}
+}
+
+// Handles /roles
+func cmdRoles(client *Client, _ []string, _ string) {
+	rolesMu.RLock()
+	defer rolesMu.RUnlock()
+	var b strings.Builder
+	b.WriteString("\nRoles:")
+	for _, r := range roles {
+		fmt.Fprintf(&b, "\n%v: %v", r.Name, strings.Join(r.Permissions, ", "))
+	}
+	names := make([]string, 0, len(permissions.PermissionField))
+	for name := range permissions.PermissionField {
+		if name != "NONE" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	b.WriteString("\nAvailable permissions: " + strings.Join(names, ", "))
+	client.SendServerMessage(b.String())
+}
+
+// Handles /editrole
+func cmdEditRole(client *Client, args []string, usage string) {
+	if args[0] == "-d" {
+		name := args[1]
+		rolesMu.Lock()
+		idx := -1
+		for i, r := range roles {
+			if r.Name == name {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			rolesMu.Unlock()
+			client.SendServerMessage("Invalid role.")
+			return
+		}
+		if len(roles) == 1 {
+			rolesMu.Unlock()
+			client.SendServerMessage("Cannot delete the last role.")
+			return
+		}
+		updated := append(append([]permissions.Role{}, roles[:idx]...), roles[idx+1:]...)
+		err := settings.SaveRoles(updated)
+		if err == nil {
+			roles = updated
+		}
+		rolesMu.Unlock()
+		if err != nil {
+			logger.LogErrorf("Failed to save roles: %v", err)
+			client.SendServerMessage("Failed to save roles.")
+			return
+		}
+		client.SendServerMessage(fmt.Sprintf("Deleted role %v.", name))
+		addToBuffer(client, "CMD", fmt.Sprintf("Deleted role %v.", name), true)
+		return
+	}
+
+	name := args[0]
+	perms, err := parsePermissionList(strings.Join(args[1:], ","))
+	if err != nil {
+		client.SendServerMessage(fmt.Sprintf("Invalid permissions: %v.\n%v", err, usage))
+		return
+	}
+	role := permissions.Role{Name: name, Permissions: perms}
+
+	rolesMu.Lock()
+	updated := append([]permissions.Role{}, roles...)
+	created := true
+	for i, r := range updated {
+		if r.Name == name {
+			updated[i] = role
+			created = false
+			break
+		}
+	}
+	if created {
+		updated = append(updated, role)
+	}
+	err = settings.SaveRoles(updated)
+	if err == nil {
+		roles = updated
+	}
+	rolesMu.Unlock()
+	if err != nil {
+		logger.LogErrorf("Failed to save roles: %v", err)
+		client.SendServerMessage("Failed to save roles.")
+		return
+	}
+
+	applyRoleChange(name, role.GetPermissions())
+	verb := "Updated"
+	if created {
+		verb = "Created"
+	}
+	client.SendServerMessage(fmt.Sprintf("%v role %v.", verb, name))
+	addToBuffer(client, "CMD", fmt.Sprintf("%v role %v with permissions: %v.", verb, name, strings.Join(perms, ", ")), true)
+}
@@ -0,0 +1,54 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"testing"
+
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+)
+
+// TestParsePermissionList tests parsing of /editrole permission lists
+func TestParsePermissionList(t *testing.T) {
+	perms, err := parsePermissionList("kick, BAN,,mute")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(perms) != 3 || perms[0] != "KICK" || perms[1] != "BAN" || perms[2] != "MUTE" {
+		t.Errorf("Unexpected permissions %v", perms)
+	}
+
+	if _, err := parsePermissionList("KICK,FLY"); err == nil {
+		t.Errorf("Expected error for unknown permission")
+	}
+	if _, err := parsePermissionList(","); err == nil {
+		t.Errorf("Expected error for empty permission list")
+	}
+}
+
+// TestOutdatedRoles tests that roles from before the punishment, event and announcement permissions are found
+func TestOutdatedRoles(t *testing.T) {
+	roles := []permissions.Role{
+		{Name: "old", Permissions: []string{"KICK", "MUTE"}},
+		{Name: "new", Permissions: []string{"MUTE", "PUNISH"}},
+		{Name: "cm", Permissions: []string{"CM"}},
+		{Name: "admin", Permissions: []string{"ADMIN"}},
+	}
+	if got := outdatedRoles(roles); len(got) != 1 || got[0] != "old" {
+		t.Errorf("outdatedRoles() = %v, want [old]", got)
+	}
+}
@@ -38,8 +38,10 @@ import (
 	"github.com/MangosArentLiterature/Athena/internal/playercount"
 	"github.com/MangosArentLiterature/Athena/internal/settings"
 	"github.com/MangosArentLiterature/Athena/internal/sliceutil"
+	"github.com/MangosArentLiterature/Athena/internal/transcript"
 	"github.com/MangosArentLiterature/Athena/internal/uidmanager"
 	"github.com/MangosArentLiterature/Athena/internal/webhook"
+	"github.com/MangosArentLiterature/Athena/internal/wordfilter"
 	"github.com/ecnepsnai/discord"
 	"github.com/xhit/go-str2duration/v2"
 	"nhooyr.io/websocket"
@@ -55,6 +57,7 @@ var (
 	areaIndexMap                           map[*area.Area]int // pre-computed index lookup for O(1) getAreaIndex
 	cachedAllowedOrigins                   []string           // pre-computed WS origin list
 	roles                                  []permissions.Role
+	rolesMu                                sync.RWMutex
 	uids                                   uidmanager.UidManager
 	players                                playercount.PlayerCount
 	enableDiscord                          bool
@@ -107,6 +110,20 @@ func InitServer(conf *settings.Config) error {
 	if err != nil {
 		return err
 	}
+	for _, name := range outdatedRoles(roles) {
+		logger.LogWarningf("Role %v grants MUTE but not %v. Punishment, event, announcement and force-pair commands now need these permissions; "+
+			"add them to the role in roles.toml if its members should keep using those commands.", name, strings.Join(splitPermissions, ", "))
+	}
+
+	filterRules, err := settings.LoadFilter()
+	if err != nil {
+		return fmt.Errorf("failed to read filter: %v", err)
+	}
+	chatFilter, err = wordfilter.New(filterRules)
+	if err != nil {
+		return err
+	}
+	spamGuard = newSpamGuard(conf)
 
 	backgrounds, err = settings.LoadFile("/backgrounds.txt")
 	if err != nil {
@@ -125,6 +142,10 @@ func InitServer(conf *settings.Config) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse default_ban_duration: %v", err.Error())
 	}
+	_, err = str2duration.ParseDuration(conf.PendingExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to parse pending_action_expiry: %v", err.Error())
+	}
 
 	// Discord webhook.
 	if config.WebhookURL != "" {
@@ -168,6 +189,14 @@ func InitServer(conf *settings.Config) error {
 		areaIndexMap[a] = i
 	}
 
+	// Each area keeps a history of IC messages for /transcript.
+	areaTranscripts = make(map[*area.Area]*transcript.Log, len(areas))
+	if conf.TranscriptLength > 0 {
+		for _, a := range areas {
+			areaTranscripts[a] = transcript.NewLog(conf.TranscriptLength)
+		}
+	}
+
 	// Pre-compute the list of allowed WebSocket origins.
 	cachedAllowedOrigins = getAllowedOrigins()
 	
@@ -182,6 +211,14 @@ func InitServer(conf *settings.Config) error {
 		}
 	}
 	
+	if conf.EnableChatArchive {
+		startChatArchive(time.Duration(conf.ChatArchiveDays) * 24 * time.Hour)
+	}
+	if (conf.BotToken != "" && conf.EventChannel != "") || (conf.BotToken == "" && conf.WebhookURL != "") {
+		startEventFeed(conf.EventFeed)
+	}
+	startWebhooks(conf.WebhooksConfig)
+
 	if config.Advertise {
 		advert := ms.Advertisement{
 			Port:    config.Port,
@@ -218,9 +255,20 @@ func StartDiscordBot() {
 		return
 	}
 	cfg := discordbot.Config{
-		Token:     config.BotToken,
-		GuildID:   config.GuildID,
-		ModRoleID: config.ModRoleID,
+		Token:            config.BotToken,
+		GuildID:          config.GuildID,
+		ModRoleID:        config.ModRoleID,
+		ModcallChannelID: config.ModcallChannel,
+		StatusChannelID:  config.StatusChannel,
+		EventChannelID:   config.EventChannel,
+		Roles:            config.DiscordRoles,
+		Bridge:           config.Bridge,
+		BridgeCooldown:   time.Duration(config.BridgeCooldown) * time.Second,
+	}
+	for roleID, name := range config.DiscordRoles {
+		if _, err := getRole(name); err != nil {
+			logger.LogWarningf("Discord role %v is mapped to role %v, which does not exist.", roleID, name)
+		}
 	}
 	b, err := discordbot.New(cfg, NewServerAdapter())
 	if err != nil {
@@ -231,6 +279,7 @@ func StartDiscordBot() {
 		logger.LogErrorf("Failed to start Discord bot: %v", err)
 		return
 	}
+	discordBot.Store(b)
 	logger.LogInfo("Discord bot started.")
 }
 
@@ -248,6 +297,7 @@ func ListenTCP() {
 		conn, err := listener.Accept()
 		if err != nil {
 			logger.LogError(err.Error())
+			continue
 		}
 		ipid := getIpid(conn.RemoteAddr().String())
 		if logger.DebugNetwork {
@@ -365,6 +415,9 @@ func addToBuffer(client *Client, action string, message string, audit bool) {
 	s := fmt.Sprintf("%v | %v | %v | %v | %v | %v",
 		now, action, client.CurrentCharacter(), client.Ipid(), client.OOCName(), message)
 	client.Area().UpdateBuffer(s)
+	if action == "IC" || action == "OOC" {
+		archiveMessage(client, action, message)
+	}
 
 	// Write to area-specific log file if area logging is enabled
 	if logger.EnableAreaLogging {
@@ -381,7 +434,10 @@ func addToBuffer(client *Client, action string, message string, audit bool) {
 	}
 
 	if audit {
-		logger.WriteAudit(s)
+		logger.WriteAudit(auditRecord(client, action, message))
+		if client.Authenticated() && action != "AUTH" {
+			recordModActivity(client, db.ActivityAction, message)
+		}
 	}
 }
 
@@ -430,6 +486,7 @@ func sendPlayerArup() {
 		plCounts = append(plCounts, strconv.Itoa(a.PlayerCount()))
 	}
 	writeToAll("ARUP", plCounts...)
+	updateDiscordStatus()
 }
 
 // sendCMArup sends a CM ARUP to all connected clients.
@@ -437,22 +494,29 @@ func sendCMArup() {
 	returnL := make([]string, 1, 1+len(areas))
 	returnL[0] = "2"
 	for _, a := range areas {
-		cmUIDs := a.CMs()
-		if len(cmUIDs) == 0 {
+		cms := areaCMs(a)
+		if len(cms) == 0 {
 			returnL = append(returnL, "FREE")
 			continue
 		}
-		cms := make([]string, 0, len(cmUIDs))
-		for _, u := range cmUIDs {
-			c, err := getClientByUid(u)
-			if err != nil {
-				continue
-			}
-			cms = append(cms, fmt.Sprintf("%v (%v)", c.CurrentCharacter(), u))
-		}
 		returnL = append(returnL, strings.Join(cms, ", "))
 	}
 	writeToAll("ARUP", returnL...)
+	updateDiscordStatus()
+}
+
+// areaCMs returns the characters and UIDs of an area's CMs.
+func areaCMs(a *area.Area) []string {
+	cmUIDs := a.CMs()
+	cms := make([]string, 0, len(cmUIDs))
+	for _, u := range cmUIDs {
+		c, err := getClientByUid(u)
+		if err != nil {
+			continue
+		}
+		cms = append(cms, fmt.Sprintf("%v (%v)", c.CurrentCharacter(), u))
+	}
+	return cms
 }
 
 // sendStatusArup sends a status ARUP to all connected clients.
@@ -463,6 +527,7 @@ func sendStatusArup() {
 		statuses = append(statuses, a.Status().String())
 	}
 	writeToAll("ARUP", statuses...)
+	updateDiscordStatus()
 }
 
 // sendLockArup sends a lock ARUP to all connected clients.
@@ -473,10 +538,20 @@ func sendLockArup() {
 		locks = append(locks, a.Lock().String())
 	}
 	writeToAll("ARUP", locks...)
+	updateDiscordStatus()
+}
+
+// updateDiscordStatus tells the Discord bot that the data shown in ARUPs changed, so it can update its status message.
+func updateDiscordStatus() {
+	if b := discordBot.Load(); b != nil {
+		b.UpdateStatus()
+	}
 }
 
 // getRole returns the role with the corresponding name, or an error if the role does not exist.
 func getRole(name string) (permissions.Role, error) {
+	rolesMu.RLock()
+	defer rolesMu.RUnlock()
 	for _, role := range roles {
 		if role.Name == name {
 			return role, nil
@@ -514,11 +589,27 @@ func sendGlobalServerMessage(message string) {
 	writeToAll("CT", encode(config.Name), encode(message), "1")
 }
 
+// sendModServerMessage sends a server OOC message to every logged-in moderator.
+func sendModServerMessage(message string) {
+	for c := range clients.GetAllClients() {
+		if c.Authenticated() && permissions.IsModerator(c.Perms()) {
+			c.SendServerMessage(message)
+		}
+	}
+}
+
 // CleanupServer closes all connections to the server, and closes the server's database.
 func CleanupServer() {
 	for client := range clients.GetAllClients() {
 		client.conn.Close()
 	}
+	stopChatArchive()
+	// The event feed is stopped first, as it posts its last events through the bot.
+	stopEventFeed()
+	if b := discordBot.Swap(nil); b != nil {
+		b.Stop()
+	}
+	stopWebhooks()
 	db.Close()
 }
 
@@ -0,0 +1,147 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/area"
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/transcript"
+	"github.com/MangosArentLiterature/Athena/internal/webhook"
+	"github.com/xhit/go-str2duration/v2"
+)
+
+// areaTranscripts holds the IC history of each area for /transcript. It is only written to by InitServer.
+var areaTranscripts map[*area.Area]*transcript.Log
+
+// transcriptCooldown limits each area to one saved transcript every two minutes.
+var transcriptCooldown = newAreaCooldown(2 * time.Minute)
+
+// recordTranscript adds an IC message, as sent to the area, to the area's transcript.
+func recordTranscript(a *area.Area, args []string) {
+	log := areaTranscripts[a]
+	if log == nil {
+		return
+	}
+	color, _ := strconv.Atoi(args[14])
+	e := transcript.Entry{
+		Time:      time.Now(),
+		Character: args[2],
+		Showname:  decode(args[15]),
+		Message:   decode(args[4]),
+		Color:     color,
+		Objection: transcript.Objection(decode(args[10])),
+	}
+	if evi, err := strconv.Atoi(args[11]); err == nil && evi > 0 {
+		if evidence := a.Evidence(); evi <= len(evidence) {
+			e.Evidence = decode(strings.Split(evidence[evi-1], "&")[0])
+		}
+	}
+	log.Add(e)
+}
+
+// parseTranscriptTime parses a /transcript time bound, either a time of day such as 18:30 (UTC), or how long ago, such as 2h.
+// Times of day later than now refer to the previous day.
+func parseTranscriptTime(s string, now time.Time) (time.Time, error) {
+	now = now.UTC()
+	if t, err := time.Parse("15:04", s); err == nil {
+		t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+		if t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t, nil
+	}
+	d, err := str2duration.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %v", s)
+	}
+	return now.Add(-d), nil
+}
+
+// Handles /transcript
+func cmdTranscript(client *Client, args []string, usage string) {
+	flags := flag.NewFlagSet("", 0)
+	flags.SetOutput(io.Discard)
+	formatName := flags.String("f", "html", "")
+	upload := flags.Bool("u", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 2 {
+		client.SendServerMessage("Invalid arguments.\n" + usage)
+		return
+	}
+	format, err := transcript.ParseFormat(*formatName)
+	if err != nil {
+		client.SendServerMessage("Invalid format. Use html or md.")
+		return
+	}
+	now := time.Now()
+	var bounds [2]time.Time
+	for i, arg := range flags.Args() {
+		bounds[i], err = parseTranscriptTime(arg, now)
+		if err != nil {
+			client.SendServerMessage(fmt.Sprintf("Invalid time %v. Use a time of day such as 18:30 (UTC), or how long ago, such as 2h.", arg))
+			return
+		}
+	}
+	log := areaTranscripts[client.Area()]
+	if log == nil {
+		client.SendServerMessage("Transcripts are disabled.")
+		return
+	}
+	entries := log.Entries(bounds[0], bounds[1])
+	if len(entries) == 0 {
+		client.SendServerMessage("There are no IC messages in that period.")
+		return
+	}
+	if wait := transcriptCooldown.start(client.Area(), now); wait > 0 {
+		client.SendServerMessage(fmt.Sprintf("Please wait %v before saving another transcript of this area.", wait.Round(time.Second)))
+		return
+	}
+
+	var buf bytes.Buffer
+	title := fmt.Sprintf("%v — %v", config.Name, client.Area().Name())
+	if err := transcript.Render(&buf, format, title, entries); err != nil {
+		logger.LogErrorf("Failed to render transcript: %v", err)
+		client.SendServerMessage("Failed to create the transcript.")
+		return
+	}
+	fname, err := logger.WriteTranscript(client.Area().Name(), format.Extension(), buf.Bytes())
+	if err != nil {
+		logger.LogErrorf("Failed to save transcript: %v", err)
+		client.SendServerMessage("Failed to save the transcript.")
+		return
+	}
+	msg := fmt.Sprintf("Saved a transcript of %v messages as %v.", len(entries), fname)
+	if *upload {
+		if !enableDiscord {
+			msg += " The Discord webhook is not configured, so it was not uploaded."
+		} else if err := webhook.PostReport(fname, buf.String()); err != nil {
+			logger.LogErrorf("Failed to upload transcript: %v", err)
+			msg += " Failed to upload it to Discord."
+		} else {
+			msg += " It has been uploaded to Discord."
+		}
+	}
+	client.SendServerMessage(msg)
+	addToBuffer(client, "CMD", fmt.Sprintf("Saved a transcript of %v messages.", len(entries)), false)
+}
@@ -0,0 +1,49 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseTranscriptTime tests parsing of /transcript times of day and durations
+func TestParseTranscriptTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		arg  string
+		want time.Time
+	}{
+		{"14:30", time.Date(2024, 3, 10, 14, 30, 0, 0, time.UTC)},
+		{"18:00", time.Date(2024, 3, 9, 18, 0, 0, 0, time.UTC)},
+		{"2h", time.Date(2024, 3, 10, 13, 0, 0, 0, time.UTC)},
+		{"1d", time.Date(2024, 3, 9, 15, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseTranscriptTime(tt.arg, now)
+		if err != nil {
+			t.Errorf("parseTranscriptTime(%q) error: %v", tt.arg, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTranscriptTime(%q) = %v, want %v", tt.arg, got, tt.want)
+		}
+	}
+	if _, err := parseTranscriptTime("yesterday", now); err == nil {
+		t.Error("expected an error for an invalid time")
+	}
+}
@@ -0,0 +1,98 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/logger"
+	"github.com/MangosArentLiterature/Athena/internal/settings"
+	"github.com/MangosArentLiterature/Athena/internal/webhook"
+)
+
+// Types of event sent to outgoing webhooks, as named in a target's events option.
+const (
+	hookJoin    = "join"
+	hookModcall = "modcall"
+	hookReport  = "report"
+	hookBan     = "ban"
+)
+
+var hookDispatcher atomic.Pointer[webhook.Dispatcher]
+
+// startWebhooks starts delivering events to the configured outgoing webhooks, if there are any.
+func startWebhooks(conf settings.WebhooksConfig) {
+	if len(conf.WebhookTargets) == 0 {
+		return
+	}
+	var targets []webhook.Target
+	for _, t := range conf.WebhookTargets {
+		if t.URL == "" {
+			logger.LogWarning("Skipping outgoing webhook with no URL.")
+			continue
+		}
+		targets = append(targets, webhook.Target{URL: t.URL, Secret: t.Secret, Events: hookEvents(t.URL, t.Events)})
+	}
+	if len(targets) == 0 {
+		return
+	}
+	deadLetter := filepath.Join(logger.LogPath, "webhook_dead_letter.jsonl")
+	hookDispatcher.Store(webhook.NewDispatcher(targets, conf.WebhookAttempts, time.Duration(conf.WebhookBackoff)*time.Second, deadLetter))
+	logger.LogInfof("Sending events to %v outgoing webhook(s).", len(targets))
+}
+
+// hookEvents returns a target's configured event types in lower case, warning about any that are unknown.
+func hookEvents(url string, events []string) []string {
+	var out []string
+	for _, e := range events {
+		e = strings.ToLower(e)
+		switch e {
+		case hookJoin, hookModcall, hookReport, hookBan:
+		default:
+			logger.LogWarningf("Outgoing webhook %v has unknown event %q.", url, e)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// stopWebhooks stops the outgoing webhooks. Events still queued are written to the dead-letter file.
+func stopWebhooks() {
+	if d := hookDispatcher.Swap(nil); d != nil {
+		d.Close()
+	}
+}
+
+// sendWebhook sends an event to the outgoing webhooks, if any are running.
+func sendWebhook(kind string, data map[string]interface{}) {
+	if d := hookDispatcher.Load(); d != nil {
+		d.Send(kind, data)
+	}
+}
+
+// sendBanWebhook sends a ban event. until is a Unix timestamp, or -1 for a permanent ban.
+func sendBanWebhook(moderator string, ipids []string, until int64, reason string) {
+	sendWebhook(hookBan, map[string]interface{}{
+		"moderator": moderator,
+		"ipids":     ipids,
+		"until":     until,
+		"reason":    reason,
+	})
+}
@@ -0,0 +1,33 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package athena
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestHookEvents tests that event types are matched regardless of case
+func TestHookEvents(t *testing.T) {
+	got := hookEvents("http://localhost", []string{"Join", "BAN", "modcall"})
+	if want := []string{hookJoin, hookBan, hookModcall}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hookEvents() = %q, want %q", got, want)
+	}
+	if got := hookEvents("http://localhost", nil); got != nil {
+		t.Errorf("hookEvents() with no events = %q, want nil so every event is sent", got)
+	}
+}
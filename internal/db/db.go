@@ -18,7 +18,9 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -35,6 +37,124 @@ type BanInfo struct {
 	Moderator string
 }
 
+// BanTarget is a single IPID/HDID pair to be banned. Hdid is empty for offline IPID bans.
+type BanTarget struct {
+	Ipid string `json:"ipid"`
+	Hdid string `json:"hdid"`
+}
+
+type PendingAction struct {
+	Id        int
+	Action    string
+	Targets   []BanTarget
+	Duration  int64
+	Reason    string
+	Moderator string
+	Created   int64
+	Expires   int64
+	Status    string
+	Reviewer  string
+}
+
+type Note struct {
+	Id     int
+	Ipid   string
+	Author string
+	Time   int64
+	Text   string
+}
+
+type Modcall struct {
+	Id         int
+	Time       int64
+	Area       string
+	CallerUid  int
+	Caller     string
+	Ipid       string
+	Reason     string
+	Status     string
+	ClaimedBy  string
+	ClaimedAt  int64
+	ResolvedBy string
+	ResolvedAt int64
+	Resolution string
+}
+
+// Modcall ticket statuses.
+const (
+	ModcallOpen     = "open"
+	ModcallClaimed  = "claimed"
+	ModcallResolved = "resolved"
+)
+
+// ModStats summarises a moderator's activity over a period.
+type ModStats struct {
+	Moderator string
+	Online    int64 // Seconds spent logged in.
+	Logins    int
+	Bans      int
+	Kicks     int
+	Mutes     int
+	Modcalls  int
+	Actions   int // Audited actions not counted above.
+	LastSeen  int64
+}
+
+// Moderator activity events.
+const (
+	ActivityLogin      = "login"
+	ActivityLogout     = "logout"
+	ActivityDisconnect = "disconnect"
+	ActivityBan        = "ban"
+	ActivityKick       = "kick"
+	ActivityMute       = "mute"
+	ActivityModcall    = "modcall"
+	ActivityAction     = "action"
+)
+
+// ChatMessage is an archived IC or OOC message.
+type ChatMessage struct {
+	Time      int64
+	Area      string
+	Kind      string // "IC" or "OOC".
+	Character string
+	Showname  string
+	OOCName   string
+	Ipid      string
+	Message   string
+}
+
+// ChatQuery filters a chat archive search. Empty fields match every message.
+type ChatQuery struct {
+	Text  string
+	Area  string
+	Since int64
+	Until int64
+	Limit int
+}
+
+// DiscordLink associates a Discord user with a moderator account or a player's IPID.
+type DiscordLink struct {
+	DiscordID string
+	Kind      string
+	Target    string
+	Time      int64
+	Alerts    int // The case roles a linked player is sent announcements for, as a bitmask of role indexes.
+}
+
+// Discord link kinds. A Discord user can have one link of each kind.
+const (
+	LinkAccount = "account"
+	LinkIPID    = "ipid"
+)
+
+// Pending action statuses.
+const (
+	PendingOpen     = "pending"
+	PendingApproved = "approved"
+	PendingExpired  = "expired"
+)
+
 type BanLookup int
 
 const (
@@ -48,7 +168,7 @@ var db *sql.DB
 
 // Database version.
 // This should be incremented whenever changes are made to the DB that require existing databases to upgrade.
-const ver = 1
+const ver = 4
 
 // Opens the server's database connection.
 func Open() error {
@@ -57,6 +177,43 @@ func Open() error {
 	if err != nil {
 		return err
 	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS BANS(ID INTEGER PRIMARY KEY, IPID TEXT, HDID TEXT, TIME INTEGER, DURATION INTEGER, REASON TEXT, MODERATOR TEXT)")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS USERS(USERNAME TEXT PRIMARY KEY, PASSWORD TEXT, PERMISSIONS TEXT)")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS NOTES(ID INTEGER PRIMARY KEY, IPID TEXT, AUTHOR TEXT, TIME INTEGER, NOTE TEXT)")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS MODCALLS(ID INTEGER PRIMARY KEY, TIME INTEGER, AREA TEXT, CALLER_UID INTEGER, CALLER TEXT, IPID TEXT, REASON TEXT, STATUS TEXT, CLAIMED_BY TEXT, CLAIMED_AT INTEGER, RESOLVED_BY TEXT, RESOLVED_AT INTEGER, RESOLUTION TEXT)")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS PENDING_ACTIONS(ID INTEGER PRIMARY KEY, ACTION TEXT, TARGETS TEXT, DURATION INTEGER, REASON TEXT, MODERATOR TEXT, CREATED INTEGER, EXPIRES INTEGER, STATUS TEXT, REVIEWER TEXT)")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS MOD_ACTIVITY(ID INTEGER PRIMARY KEY, TIME INTEGER, MODERATOR TEXT, EVENT TEXT, DETAIL TEXT, DURATION INTEGER)")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS MOD_ACTIVITY_TIME ON MOD_ACTIVITY(TIME)")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS DISCORD_LINKS(DISCORD_ID TEXT, KIND TEXT, TARGET TEXT, TIME INTEGER, PRIMARY KEY(DISCORD_ID, KIND))")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS CHAT_ARCHIVE USING fts5(TIME UNINDEXED, AREA, KIND UNINDEXED, CHARACTER, SHOWNAME, OOC_NAME, IPID, MESSAGE)")
+	if err != nil {
+		return err
+	}
+	// Tables are created with their original schema, and upgraded to the latest version below.
 	var v int
 	r := db.QueryRow("PRAGMA user_version")
 	r.Scan(&v)
@@ -66,14 +223,6 @@ func Open() error {
 			return err
 		}
 	}
-	_, err = db.Exec("CREATE TABLE IF NOT EXISTS BANS(ID INTEGER PRIMARY KEY, IPID TEXT, HDID TEXT, TIME INTEGER, DURATION INTEGER, REASON TEXT, MODERATOR TEXT)")
-	if err != nil {
-		return err
-	}
-	_, err = db.Exec("CREATE TABLE IF NOT EXISTS USERS(USERNAME TEXT PRIMARY KEY, PASSWORD TEXT, PERMISSIONS TEXT)")
-	if err != nil {
-		return err
-	}
 	return nil
 }
 
@@ -85,6 +234,39 @@ func upgradeDB(v int) error {
 		if err != nil {
 			return err
 		}
+		fallthrough
+	case 1:
+		// Users store the name of their role, so role changes apply to existing users.
+		_, err := db.Exec("ALTER TABLE USERS ADD COLUMN ROLE TEXT NOT NULL DEFAULT ''")
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec("PRAGMA user_version = " + "2")
+		if err != nil {
+			return err
+		}
+		fallthrough
+	case 2:
+		// Users can optionally have a TOTP secret for two-factor authentication.
+		_, err := db.Exec("ALTER TABLE USERS ADD COLUMN TOTP_SECRET TEXT NOT NULL DEFAULT ''")
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec("PRAGMA user_version = " + "3")
+		if err != nil {
+			return err
+		}
+		fallthrough
+	case 3:
+		// Linked players choose which case roles they are sent announcements for.
+		_, err := db.Exec("ALTER TABLE DISCORD_LINKS ADD COLUMN ALERTS INTEGER NOT NULL DEFAULT 0")
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec("PRAGMA user_version = " + "4")
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -99,47 +281,107 @@ func UserExists(username string) bool {
 	}
 }
 
-// CreateUser adds a new user to the server's database.
-func CreateUser(username string, password []byte, permissions uint64) error {
+// CreateUser adds a new user with the given role to the server's database.
+// The role's permissions are stored alongside it, and used if the role is later removed.
+func CreateUser(username string, password []byte, role string, permissions uint64) error {
 	hashed, err := bcrypt.GenerateFromPassword(password, 12)
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec("INSERT INTO USERS VALUES(?, ?, ?)", username, hashed, strconv.FormatUint(permissions, 10))
+	_, err = db.Exec("INSERT INTO USERS(USERNAME, PASSWORD, PERMISSIONS, ROLE) VALUES(?, ?, ?, ?)", username, hashed, strconv.FormatUint(permissions, 10), role)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// RemoveUser deletes a user from the server's database.
+// RemoveUser deletes a user from the server's database, along with any Discord account linked to it.
 func RemoveUser(username string) error {
 	_, err := db.Exec("DELETE FROM USERS WHERE USERNAME = ?", username)
 	if err != nil {
 		return err
 	}
+	_, err = db.Exec("DELETE FROM DISCORD_LINKS WHERE KIND = ? AND TARGET = ?", LinkAccount, username)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
-// AuthenticateUser returns whether or not the user's credentials match those in the database, and that user's permissions.
-func AuthenticateUser(username string, password []byte) (bool, uint64) {
-	var rpass, rperms string
-	result := db.QueryRow("SELECT PASSWORD, PERMISSIONS FROM USERS WHERE USERNAME = ?", username)
-	result.Scan(&rpass, &rperms)
+// GetUserRole returns a user's role and stored permissions.
+func GetUserRole(username string) (string, uint64, error) {
+	var rperms, role string
+	err := db.QueryRow("SELECT PERMISSIONS, ROLE FROM USERS WHERE USERNAME = ?", username).Scan(&rperms, &role)
+	if err != nil {
+		return "", 0, err
+	}
+	p, err := strconv.ParseUint(rperms, 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return role, p, nil
+}
+
+// AuthenticateUser returns whether or not the user's credentials match those in the database, and that user's role and stored permissions.
+func AuthenticateUser(username string, password []byte) (bool, string, uint64) {
+	var rpass, rperms, role string
+	result := db.QueryRow("SELECT PASSWORD, PERMISSIONS, ROLE FROM USERS WHERE USERNAME = ?", username)
+	result.Scan(&rpass, &rperms, &role)
 	err := bcrypt.CompareHashAndPassword([]byte(rpass), password)
 	if err != nil {
-		return false, 0
+		return false, "", 0
 	}
 	p, err := strconv.ParseUint(rperms, 10, 64)
 	if err != nil {
-		return false, 0
+		return false, "", 0
+	}
+	return true, role, p
+}
+
+// ChangeRole updates the role of a user in the database, along with its stored permissions.
+func ChangeRole(username string, role string, permissions uint64) error {
+	_, err := db.Exec("UPDATE USERS SET ROLE = ?, PERMISSIONS = ? WHERE USERNAME = ?", role, strconv.FormatUint(permissions, 10), username)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ChangePassword updates the password of a user in the database.
+func ChangePassword(username string, password []byte) error {
+	hashed, err := bcrypt.GenerateFromPassword(password, 12)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE USERS SET PASSWORD = ? WHERE USERNAME = ?", hashed, username)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetTOTPSecret returns the TOTP secret of a user, or an empty string if they don't use two-factor authentication.
+func GetTOTPSecret(username string) (string, error) {
+	var secret string
+	err := db.QueryRow("SELECT TOTP_SECRET FROM USERS WHERE USERNAME = ?", username).Scan(&secret)
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// SetTOTPSecret sets the TOTP secret of a user. An empty secret disables two-factor authentication.
+func SetTOTPSecret(username string, secret string) error {
+	_, err := db.Exec("UPDATE USERS SET TOTP_SECRET = ? WHERE USERNAME = ?", secret, username)
+	if err != nil {
+		return err
 	}
-	return true, p
+	return nil
 }
 
-// ChangePermissions updated the permissions of a user in the database.
-func ChangePermissions(username string, permissions uint64) error {
-	_, err := db.Exec("UPDATE USERS SET PERMISSIONS = ? WHERE USERNAME = ?", strconv.FormatUint(permissions, 10), username)
+// UpdateRolePermissions updates the stored permissions of every user with the given role.
+func UpdateRolePermissions(role string, permissions uint64) error {
+	_, err := db.Exec("UPDATE USERS SET PERMISSIONS = ? WHERE ROLE = ?", strconv.FormatUint(permissions, 10), role)
 	if err != nil {
 		return err
 	}
@@ -285,3 +527,362 @@ func GetAllBans() ([]BanInfo, error) {
 	}
 	return bans, nil
 }
+
+// AddPendingAction stores a new moderator action awaiting approval, returning its ID.
+func AddPendingAction(p PendingAction) (int, error) {
+	targets, err := json.Marshal(p.Targets)
+	if err != nil {
+		return 0, err
+	}
+	result, err := db.Exec("INSERT INTO PENDING_ACTIONS VALUES(NULL, ?, ?, ?, ?, ?, ?, ?, ?, '')",
+		p.Action, string(targets), p.Duration, p.Reason, p.Moderator, p.Created, p.Expires, PendingOpen)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// scanPendingAction reads a PENDING_ACTIONS row.
+func scanPendingAction(row interface{ Scan(...any) error }) (PendingAction, error) {
+	var p PendingAction
+	var targets string
+	err := row.Scan(&p.Id, &p.Action, &targets, &p.Duration, &p.Reason, &p.Moderator, &p.Created, &p.Expires, &p.Status, &p.Reviewer)
+	if err != nil {
+		return PendingAction{}, err
+	}
+	if err := json.Unmarshal([]byte(targets), &p.Targets); err != nil {
+		return PendingAction{}, err
+	}
+	return p, nil
+}
+
+// GetPendingAction returns the pending action with the given ID.
+func GetPendingAction(id int) (PendingAction, error) {
+	return scanPendingAction(db.QueryRow("SELECT * FROM PENDING_ACTIONS WHERE ID = ?", id))
+}
+
+// GetPendingActions returns all actions still awaiting approval, oldest first.
+func GetPendingActions() ([]PendingAction, error) {
+	result, err := db.Query("SELECT * FROM PENDING_ACTIONS WHERE STATUS = ? AND EXPIRES > ? ORDER BY CREATED ASC",
+		PendingOpen, time.Now().UTC().Unix())
+	if err != nil {
+		return []PendingAction{}, err
+	}
+	defer result.Close()
+	var actions []PendingAction
+	for result.Next() {
+		p, err := scanPendingAction(result)
+		if err != nil {
+			continue
+		}
+		actions = append(actions, p)
+	}
+	return actions, nil
+}
+
+// ResolvePendingAction sets the status of an open pending action and the moderator who reviewed it.
+// It returns false if the action does not exist or has already been resolved.
+func ResolvePendingAction(id int, status string, reviewer string) (bool, error) {
+	result, err := db.Exec("UPDATE PENDING_ACTIONS SET STATUS = ?, REVIEWER = ? WHERE ID = ? AND STATUS = ?",
+		status, reviewer, id, PendingOpen)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ExpirePendingActions marks every open action past its expiry time as expired, returning how many were expired.
+func ExpirePendingActions() (int64, error) {
+	result, err := db.Exec("UPDATE PENDING_ACTIONS SET STATUS = ? WHERE STATUS = ? AND EXPIRES <= ?",
+		PendingExpired, PendingOpen, time.Now().UTC().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// AddNote adds a moderator note on an IPID to the database.
+func AddNote(ipid string, author string, time int64, text string) (int, error) {
+	result, err := db.Exec("INSERT INTO NOTES VALUES(NULL, ?, ?, ?, ?)", ipid, author, time, text)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// GetNotes returns all moderator notes on an IPID, oldest first.
+func GetNotes(ipid string) ([]Note, error) {
+	result, err := db.Query("SELECT * FROM NOTES WHERE IPID = ? ORDER BY TIME ASC", ipid)
+	if err != nil {
+		return []Note{}, err
+	}
+	defer result.Close()
+	var notes []Note
+	for result.Next() {
+		var n Note
+		if err := result.Scan(&n.Id, &n.Ipid, &n.Author, &n.Time, &n.Text); err != nil {
+			continue
+		}
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
+
+// AddModcall opens a new modcall ticket, returning its ID.
+func AddModcall(m Modcall) (int, error) {
+	result, err := db.Exec("INSERT INTO MODCALLS VALUES(NULL, ?, ?, ?, ?, ?, ?, ?, '', 0, '', 0, '')",
+		m.Time, m.Area, m.CallerUid, m.Caller, m.Ipid, m.Reason, ModcallOpen)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// scanModcall reads a MODCALLS row.
+func scanModcall(row interface{ Scan(...any) error }) (Modcall, error) {
+	var m Modcall
+	err := row.Scan(&m.Id, &m.Time, &m.Area, &m.CallerUid, &m.Caller, &m.Ipid, &m.Reason, &m.Status,
+		&m.ClaimedBy, &m.ClaimedAt, &m.ResolvedBy, &m.ResolvedAt, &m.Resolution)
+	return m, err
+}
+
+// GetModcall returns the modcall ticket with the given ID.
+func GetModcall(id int) (Modcall, error) {
+	return scanModcall(db.QueryRow("SELECT * FROM MODCALLS WHERE ID = ?", id))
+}
+
+// GetOpenModcalls returns all unresolved modcall tickets, oldest first.
+func GetOpenModcalls() ([]Modcall, error) {
+	result, err := db.Query("SELECT * FROM MODCALLS WHERE STATUS != ? ORDER BY TIME ASC", ModcallResolved)
+	if err != nil {
+		return []Modcall{}, err
+	}
+	defer result.Close()
+	var calls []Modcall
+	for result.Next() {
+		m, err := scanModcall(result)
+		if err != nil {
+			continue
+		}
+		calls = append(calls, m)
+	}
+	return calls, nil
+}
+
+// ClaimModcall marks an open modcall ticket as claimed by a moderator.
+// It returns false if the ticket does not exist or is not open.
+func ClaimModcall(id int, moderator string, at int64) (bool, error) {
+	result, err := db.Exec("UPDATE MODCALLS SET STATUS = ?, CLAIMED_BY = ?, CLAIMED_AT = ? WHERE ID = ? AND STATUS = ?",
+		ModcallClaimed, moderator, at, id, ModcallOpen)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ResolveModcall closes an unresolved modcall ticket with a resolution note.
+// Unclaimed tickets are claimed by the resolving moderator. It returns false if the ticket does not exist or is already resolved.
+func ResolveModcall(id int, moderator string, note string, at int64) (bool, error) {
+	result, err := db.Exec(`UPDATE MODCALLS SET STATUS = ?, RESOLVED_BY = ?, RESOLVED_AT = ?, RESOLUTION = ?,
+		CLAIMED_BY = CASE WHEN CLAIMED_BY = '' THEN ? ELSE CLAIMED_BY END,
+		CLAIMED_AT = CASE WHEN CLAIMED_AT = 0 THEN ? ELSE CLAIMED_AT END
+		WHERE ID = ? AND STATUS != ?`,
+		ModcallResolved, moderator, at, note, moderator, at, id, ModcallResolved)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// AddModActivity records a moderator activity event. Duration is the length of the session, in seconds, for logouts and disconnects.
+func AddModActivity(moderator string, event string, detail string, time int64, duration int64) error {
+	_, err := db.Exec("INSERT INTO MOD_ACTIVITY VALUES(NULL, ?, ?, ?, ?, ?)", time, moderator, event, detail, duration)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetModStats summarises the activity of moderators since the given time.
+// If moderator is empty, every moderator with activity in that period is included.
+// Only the part of each session after since counts towards the time online.
+func GetModStats(moderator string, since int64) ([]ModStats, error) {
+	query := `SELECT MODERATOR,
+		TOTAL(CASE WHEN EVENT IN (?, ?) THEN MIN(DURATION, TIME - ?) ELSE 0 END),
+		TOTAL(EVENT = ?), TOTAL(EVENT = ?), TOTAL(EVENT = ?), TOTAL(EVENT = ?), TOTAL(EVENT = ?), TOTAL(EVENT = ?),
+		MAX(TIME)
+		FROM MOD_ACTIVITY WHERE TIME >= ?`
+	args := []interface{}{ActivityLogout, ActivityDisconnect, since,
+		ActivityLogin, ActivityBan, ActivityKick, ActivityMute, ActivityModcall, ActivityAction, since}
+	if moderator != "" {
+		query += " AND MODERATOR = ?"
+		args = append(args, moderator)
+	}
+	query += " GROUP BY MODERATOR ORDER BY MODERATOR"
+	result, err := db.Query(query, args...)
+	if err != nil {
+		return []ModStats{}, err
+	}
+	defer result.Close()
+	var stats []ModStats
+	for result.Next() {
+		var m ModStats
+		var online, logins, bans, kicks, mutes, modcalls, actions float64
+		if err := result.Scan(&m.Moderator, &online, &logins, &bans, &kicks, &mutes, &modcalls, &actions, &m.LastSeen); err != nil {
+			continue
+		}
+		m.Online = int64(online)
+		m.Logins, m.Bans, m.Kicks, m.Mutes, m.Modcalls, m.Actions = int(logins), int(bans), int(kicks), int(mutes), int(modcalls), int(actions)
+		stats = append(stats, m)
+	}
+	return stats, nil
+}
+
+// AddChatMessages adds messages to the chat archive.
+func AddChatMessages(msgs []ChatMessage) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT INTO CHAT_ARCHIVE VALUES(?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, m := range msgs {
+		_, err := stmt.Exec(m.Time, m.Area, m.Kind, m.Character, m.Showname, m.OOCName, m.Ipid, m.Message)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SearchChat returns the most recent archived messages matching a query, newest first.
+// Every word of the query text must appear in the message or one of its author's names.
+func SearchChat(q ChatQuery) ([]ChatMessage, error) {
+	query := "SELECT TIME, AREA, KIND, CHARACTER, SHOWNAME, OOC_NAME, IPID, MESSAGE FROM CHAT_ARCHIVE WHERE 1"
+	var args []interface{}
+	if match := ftsQuery(q.Text); match != "" {
+		query += " AND CHAT_ARCHIVE MATCH ?"
+		args = append(args, match)
+	}
+	if q.Area != "" {
+		query += " AND AREA = ? COLLATE NOCASE"
+		args = append(args, q.Area)
+	}
+	if q.Since > 0 {
+		query += " AND TIME >= ?"
+		args = append(args, q.Since)
+	}
+	if q.Until > 0 {
+		query += " AND TIME <= ?"
+		args = append(args, q.Until)
+	}
+	query += " ORDER BY ROWID DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+	result, err := db.Query(query, args...)
+	if err != nil {
+		return []ChatMessage{}, err
+	}
+	defer result.Close()
+	var msgs []ChatMessage
+	for result.Next() {
+		var m ChatMessage
+		if err := result.Scan(&m.Time, &m.Area, &m.Kind, &m.Character, &m.Showname, &m.OOCName, &m.Ipid, &m.Message); err != nil {
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// PruneChatArchive deletes archived messages older than the given time.
+func PruneChatArchive(before int64) error {
+	_, err := db.Exec("DELETE FROM CHAT_ARCHIVE WHERE TIME < ?", before)
+	return err
+}
+
+// ftsQuery turns free text into an FTS5 query that matches messages containing every word.
+// Words are quoted so characters with special meaning to FTS5 are searched for literally.
+func ftsQuery(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// AddDiscordLink links a Discord user to a moderator account or IPID, replacing any existing link of the same kind.
+func AddDiscordLink(l DiscordLink) error {
+	_, err := db.Exec("INSERT OR REPLACE INTO DISCORD_LINKS VALUES(?, ?, ?, ?, ?)", l.DiscordID, l.Kind, l.Target, l.Time, l.Alerts)
+	return err
+}
+
+// SetDiscordLinkAlerts sets the case roles sent to every Discord user linked to a moderator account or IPID.
+func SetDiscordLinkAlerts(kind string, target string, alerts int) error {
+	_, err := db.Exec("UPDATE DISCORD_LINKS SET ALERTS = ? WHERE KIND = ? AND TARGET = ?", alerts, kind, target)
+	return err
+}
+
+// GetDiscordLinks returns every link of a Discord user.
+func GetDiscordLinks(discordID string) ([]DiscordLink, error) {
+	return queryDiscordLinks("SELECT * FROM DISCORD_LINKS WHERE DISCORD_ID = ? ORDER BY KIND", discordID)
+}
+
+// GetLinkedDiscordUsers returns the links of every Discord user linked to a moderator account or IPID.
+func GetLinkedDiscordUsers(kind string, target string) ([]DiscordLink, error) {
+	return queryDiscordLinks("SELECT * FROM DISCORD_LINKS WHERE KIND = ? AND TARGET = ?", kind, target)
+}
+
+// GetAllDiscordLinks returns every link of the given kind.
+func GetAllDiscordLinks(kind string) ([]DiscordLink, error) {
+	return queryDiscordLinks("SELECT * FROM DISCORD_LINKS WHERE KIND = ?", kind)
+}
+
+// queryDiscordLinks returns the Discord links selected by a query.
+func queryDiscordLinks(query string, args ...any) ([]DiscordLink, error) {
+	result, err := db.Query(query, args...)
+	if err != nil {
+		return []DiscordLink{}, err
+	}
+	defer result.Close()
+	var links []DiscordLink
+	for result.Next() {
+		var l DiscordLink
+		if err := result.Scan(&l.DiscordID, &l.Kind, &l.Target, &l.Time, &l.Alerts); err != nil {
+			continue
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
+// RemoveDiscordLinks removes every link of a Discord user, returning how many there were.
+func RemoveDiscordLinks(discordID string) (int64, error) {
+	result, err := db.Exec("DELETE FROM DISCORD_LINKS WHERE DISCORD_ID = ?", discordID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
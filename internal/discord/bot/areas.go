@@ -23,8 +23,8 @@ import (
 )
 
 // handleForceMove handles the /forcemove command.
-func (b *Bot) handleForceMove(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleForceMove(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	opts := i.ApplicationCommandData().Options
@@ -45,8 +45,8 @@ func (b *Bot) handleForceMove(s *discordgo.Session, i *discordgo.InteractionCrea
 }
 
 // handleClearArea handles the /cleararea command.
-func (b *Bot) handleClearArea(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleClearArea(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	areaArg := i.ApplicationCommandData().Options[0].StringValue()
@@ -58,12 +58,12 @@ func (b *Bot) handleClearArea(s *discordgo.Session, i *discordgo.InteractionCrea
 }
 
 // handleLock handles the /lock command.
-func (b *Bot) handleLock(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleLock(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	areaArg := i.ApplicationCommandData().Options[0].StringValue()
-	if err := b.server.LockArea(areaArg); err != nil {
+	if err := b.server.LockArea(areaArg, interactionUser(i)); err != nil {
 		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to lock area: %v", err)))
 		return
 	}
@@ -71,12 +71,12 @@ func (b *Bot) handleLock(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 // handleUnlock handles the /unlock command.
-func (b *Bot) handleUnlock(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleUnlock(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	areaArg := i.ApplicationCommandData().Options[0].StringValue()
-	if err := b.server.UnlockArea(areaArg); err != nil {
+	if err := b.server.UnlockArea(areaArg, interactionUser(i)); err != nil {
 		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to unlock area: %v", err)))
 		return
 	}
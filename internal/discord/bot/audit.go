@@ -24,8 +24,8 @@ import (
 )
 
 // handleLogs handles the /logs command.
-func (b *Bot) handleLogs(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleLogs(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	playerArg := i.ApplicationCommandData().Options[0].StringValue()
@@ -54,14 +54,25 @@ func (b *Bot) handleLogs(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 // handleAuditLog handles the /auditlog command.
-func (b *Bot) handleAuditLog(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleAuditLog(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	opts := i.ApplicationCommandData().Options
-	filter := optionString(opts, "filter")
+	q := AuditQuery{
+		Filter:    optionString(opts, "filter"),
+		Moderator: optionString(opts, "moderator"),
+		Target:    optionString(opts, "target"),
+		Action:    optionString(opts, "action"),
+	}
+	period, err := parseDuration(optionString(opts, "period"))
+	if err != nil {
+		respondEmbed(s, i, errorEmbed(err.Error()))
+		return
+	}
+	q.Period = period
 
-	entries := b.server.GetAuditLog(filter)
+	entries := b.server.GetAuditLog(q)
 	if len(entries) == 0 {
 		respondEmbed(s, i, infoEmbed("📋 Audit Log", "No audit log entries found."))
 		return
@@ -72,8 +83,14 @@ func (b *Bot) handleAuditLog(s *discordgo.Session, i *discordgo.InteractionCreat
 		desc = desc[:4000] + "\n…(truncated)"
 	}
 	title := "📋 Audit Log"
-	if filter != "" {
-		title += fmt.Sprintf(" (filter: %s)", filter)
+	var filters []string
+	for _, f := range [][2]string{{"filter", q.Filter}, {"moderator", q.Moderator}, {"target", q.Target}, {"action", q.Action}, {"period", optionString(opts, "period")}} {
+		if f[1] != "" {
+			filters = append(filters, fmt.Sprintf("%s: %s", f[0], f[1]))
+		}
+	}
+	if len(filters) > 0 {
+		title += fmt.Sprintf(" (%s)", strings.Join(filters, ", "))
 	}
 	embed := &discordgo.MessageEmbed{
 		Title:       title,
@@ -82,3 +99,46 @@ func (b *Bot) handleAuditLog(s *discordgo.Session, i *discordgo.InteractionCreat
 	}
 	respondEmbed(s, i, embed)
 }
+
+// handleSearch handles the /search command.
+func (b *Bot) handleSearch(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	opts := i.ApplicationCommandData().Options
+	q := ChatSearchQuery{
+		Text: optionString(opts, "query"),
+		Area: optionString(opts, "area"),
+	}
+	period, err := parseDuration(optionString(opts, "period"))
+	if err != nil {
+		respondEmbed(s, i, errorEmbed(err.Error()))
+		return
+	}
+	q.Period = period
+
+	results, err := b.server.SearchChat(q)
+	if err != nil {
+		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to search the chat archive: %v", err)))
+		return
+	}
+	if len(results) == 0 {
+		respondEmbed(s, i, infoEmbed("🔎 Chat Search", fmt.Sprintf("No messages matching `%s`.", q.Text)))
+		return
+	}
+
+	desc := strings.Join(results, "\n")
+	if len(desc) > 4000 {
+		desc = desc[:4000] + "\n…(truncated)"
+	}
+	title := fmt.Sprintf("🔎 Chat Search — %s", q.Text)
+	if q.Area != "" {
+		title += fmt.Sprintf(" in %s", q.Area)
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: fmt.Sprintf("```\n%s\n```", desc),
+		Color:       colorBlue,
+	}
+	respondEmbed(s, i, embed)
+}
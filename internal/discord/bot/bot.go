@@ -19,17 +19,31 @@ package bot
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 // Bot holds the Discord bot state.
 type Bot struct {
-	session    *discordgo.Session
+	gateway    *discordgo.Session
+	session    Session
+	userID     string
 	guildID    string
 	modRoleID  string
 	server     ServerInterface
 	commands   []*discordgo.ApplicationCommand
+	bridge     *bridge
+
+	modcallChannelID string
+	alerts           modcallAlerts
+	roles            map[string]string
+	status           *statusBoard
+	eventChannelID   string
+
+	stopOnce sync.Once
 }
 
 // Config holds the configuration for the Discord bot.
@@ -37,6 +51,21 @@ type Config struct {
 	Token     string
 	GuildID   string
 	ModRoleID string
+	// Roles maps Discord role IDs to the names of the in-game roles whose permissions they grant.
+	// If empty, ModRoleID grants every permission.
+	Roles map[string]string
+	// ModcallChannelID is the channel modcalls are posted to, with buttons to act on them.
+	ModcallChannelID string
+
+	// StatusChannelID is the channel with a message kept up to date with the server's status.
+	StatusChannelID string
+	// EventChannelID is the channel the server's event feed is posted to.
+	EventChannelID string
+
+	// Bridge maps Discord channel IDs to the area whose OOC chat they share, or GlobalBridge for every area.
+	Bridge map[string]string
+	// BridgeCooldown is the minimum time between messages from one Discord user relayed in game.
+	BridgeCooldown time.Duration
 }
 
 // New creates and returns a new Bot instance.
@@ -51,50 +80,98 @@ func New(cfg Config, srv ServerInterface) (*Bot, error) {
 	}
 
 	b := &Bot{
+		gateway:   session,
 		session:   session,
 		guildID:   cfg.GuildID,
 		modRoleID: cfg.ModRoleID,
 		server:    srv,
+		bridge:    newBridge(cfg.Bridge, cfg.BridgeCooldown),
+
+		modcallChannelID: cfg.ModcallChannelID,
+		roles:            cfg.Roles,
+		status:           newStatusBoard(cfg.StatusChannelID),
+		eventChannelID:   cfg.EventChannelID,
+	}
+	if b.bridge != nil {
+		// Relaying chat requires the privileged message content intent, which must also be enabled in the developer portal.
+		session.Identify.Intents |= discordgo.IntentsGuildMessages | discordgo.IntentMessageContent
 	}
 	return b, nil
 }
 
 // Start opens the Discord session, registers slash commands, and begins listening for events.
 func (b *Bot) Start() error {
-	b.session.AddHandler(b.handleInteraction)
+	b.gateway.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		b.handleInteraction(s, i)
+	})
+	if b.bridge != nil {
+		b.gateway.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+			b.handleMessage(s, m)
+		})
+		go b.bridge.run(b.session)
+	}
 
-	if err := b.session.Open(); err != nil {
+	if err := b.gateway.Open(); err != nil {
 		return fmt.Errorf("failed to open discord session: %w", err)
 	}
+	b.userID = b.gateway.State.User.ID
 
 	if err := b.registerCommands(); err != nil {
-		_ = b.session.Close()
+		_ = b.gateway.Close()
 		return fmt.Errorf("failed to register discord commands: %w", err)
 	}
+	if b.status != nil {
+		go b.runStatus()
+	}
 
 	return nil
 }
 
-// Stop gracefully shuts down the Discord bot, removing registered commands.
+// Stop gracefully shuts down the Discord bot, removing registered commands and stopping the status board and chat bridge.
+// Only the first call has any effect.
 func (b *Bot) Stop() {
-	for _, cmd := range b.commands {
-		if err := b.session.ApplicationCommandDelete(b.session.State.User.ID, b.guildID, cmd.ID); err != nil {
-			// Best-effort cleanup; log but do not block shutdown.
-			_ = err
+	b.stopOnce.Do(func() {
+		if b.status != nil {
+			close(b.status.done)
 		}
-	}
-	_ = b.session.Close()
+		if b.bridge != nil {
+			b.bridge.close()
+		}
+		for _, cmd := range b.commands {
+			if err := b.session.ApplicationCommandDelete(b.userID, b.guildID, cmd.ID); err != nil {
+				// Best-effort cleanup; log but do not block shutdown.
+				_ = err
+			}
+		}
+		if b.gateway != nil {
+			_ = b.gateway.Close()
+		}
+	})
 }
 
 // handleInteraction dispatches incoming Discord interaction events to the appropriate handler.
-func (b *Bot) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if i.Type != discordgo.InteractionApplicationCommand {
+func (b *Bot) handleInteraction(s Session, i *discordgo.InteractionCreate) {
+	var customID string
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		data := i.ApplicationCommandData()
+		handler, ok := b.commandHandlers()[data.Name]
+		if !ok {
+			return
+		}
+		handler(s, i)
+		return
+	case discordgo.InteractionMessageComponent:
+		customID = i.MessageComponentData().CustomID
+	case discordgo.InteractionModalSubmit:
+		customID = i.ModalSubmitData().CustomID
+	default:
 		return
 	}
-	data := i.ApplicationCommandData()
-	handler, ok := b.commandHandlers()[data.Name]
+	prefix, arg, _ := strings.Cut(customID, ":")
+	handler, ok := b.componentHandlers()[prefix]
 	if !ok {
 		return
 	}
-	handler(s, i)
+	handler(s, i, arg)
 }
@@ -0,0 +1,193 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// bridgeQueueSize is the number of in-game messages waiting to be posted to Discord before new ones are dropped.
+	bridgeQueueSize = 256
+	// bridgeMaxLength is the longest Discord message relayed in game; longer messages are cut short.
+	bridgeMaxLength = 256
+)
+
+// GlobalBridge is the area name that maps a channel to every area.
+const GlobalBridge = "*"
+
+var (
+	customEmojiRegex = regexp.MustCompile(`<a?(:\w+:)\d+>`)
+	markdownEscaper  = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`, ">", `\>`)
+)
+
+// bridgeMessage is an in-game OOC message waiting to be posted to a Discord channel.
+type bridgeMessage struct {
+	channel string
+	content string
+}
+
+// bridge relays OOC chat between Discord channels and areas.
+type bridge struct {
+	channels map[string]string // Channel ID to area name, or GlobalBridge.
+	cooldown time.Duration     // Minimum time between relayed messages from one Discord user.
+	queue    chan bridgeMessage
+
+	mu     sync.Mutex
+	last   map[string]time.Time // Discord user ID to the time of their last relayed message.
+	closed bool                 // Set once the queue is closed, after which nothing more is relayed to Discord.
+}
+
+// newBridge returns a bridge for the given channels, or nil if there are none.
+func newBridge(channels map[string]string, cooldown time.Duration) *bridge {
+	if len(channels) == 0 {
+		return nil
+	}
+	return &bridge{
+		channels: channels,
+		cooldown: cooldown,
+		queue:    make(chan bridgeMessage, bridgeQueueSize),
+		last:     make(map[string]time.Time),
+	}
+}
+
+// allow reports whether a Discord user is off cooldown, and starts a new cooldown if so.
+func (br *bridge) allow(userID string, now time.Time) bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if now.Sub(br.last[userID]) < br.cooldown {
+		return false
+	}
+	br.last[userID] = now
+	return true
+}
+
+// close closes the queue, stopping run once the messages already queued are posted.
+func (br *bridge) close() {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if !br.closed {
+		br.closed = true
+		close(br.queue)
+	}
+}
+
+// run posts queued messages to Discord until the queue is closed.
+// Discord's rate limits are handled by the session, which waits rather than dropping messages.
+func (br *bridge) run(s Session) {
+	for m := range br.queue {
+		_, _ = s.ChannelMessageSendComplex(m.channel, &discordgo.MessageSend{
+			Content:         m.content,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		})
+	}
+}
+
+// sanitizeForGame turns a Discord message into plain text suitable for in-game OOC.
+func sanitizeForGame(s Session, m *discordgo.Message) string {
+	text := m.ContentWithMentionsReplaced()
+	// Roles and channels can only be named from the gateway's state cache.
+	if ds, ok := s.(*discordgo.Session); ok && ds.State != nil {
+		if t, err := m.ContentWithMoreMentionsReplaced(ds); err == nil {
+			text = t
+		}
+	}
+	text = customEmojiRegex.ReplaceAllString(text, "$1")
+	text = strings.Join(strings.Fields(text), " ")
+	if len(m.Attachments) > 0 {
+		text = strings.TrimSpace(text + " [attachment]")
+	}
+	if r := []rune(text); len(r) > bridgeMaxLength {
+		text = string(r[:bridgeMaxLength]) + "…"
+	}
+	return text
+}
+
+// SanitizeForDiscord escapes Markdown and defuses mentions in text from the game, so it is shown as sent.
+// Mentions never ping, as they are disabled when posting, but would otherwise still render as links.
+func SanitizeForDiscord(s string) string {
+	s = markdownEscaper.Replace(s)
+	s = strings.ReplaceAll(s, "@", "@\u200b")
+	return strings.ReplaceAll(s, "<#", "<\u200b#")
+}
+
+// RelayOOC posts an in-game OOC message to the Discord channels bridged with its area.
+// It never blocks; messages are dropped if Discord falls behind.
+func (b *Bot) RelayOOC(area string, name string, message string) {
+	if b.bridge == nil {
+		return
+	}
+	b.bridge.mu.Lock()
+	defer b.bridge.mu.Unlock()
+	if b.bridge.closed {
+		return
+	}
+	for channel, bridged := range b.bridge.channels {
+		var content string
+		switch {
+		case strings.EqualFold(bridged, area):
+			content = fmt.Sprintf("**%v**: %v", SanitizeForDiscord(name), SanitizeForDiscord(message))
+		case bridged == GlobalBridge:
+			content = fmt.Sprintf("[%v] **%v**: %v", SanitizeForDiscord(area), SanitizeForDiscord(name), SanitizeForDiscord(message))
+		default:
+			continue
+		}
+		select {
+		case b.bridge.queue <- bridgeMessage{channel: channel, content: content}:
+		default:
+		}
+	}
+}
+
+// handleMessage relays messages posted in bridged channels to their area in game.
+func (b *Bot) handleMessage(s Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot || m.WebhookID != "" {
+		return
+	}
+	area, ok := b.bridge.channels[m.ChannelID]
+	if !ok {
+		return
+	}
+	// Members timed out in Discord are muted on the bridge too.
+	if m.Member != nil && m.Member.CommunicationDisabledUntil != nil && m.Member.CommunicationDisabledUntil.After(time.Now()) {
+		return
+	}
+	text := sanitizeForGame(s, m.Message)
+	if text == "" {
+		return
+	}
+	if !b.bridge.allow(m.Author.ID, time.Now()) {
+		_ = s.MessageReactionAdd(m.ChannelID, m.ID, "⏳")
+		return
+	}
+	name := m.Author.Username
+	if m.Member != nil && m.Member.Nick != "" {
+		name = m.Member.Nick
+	}
+	if area == GlobalBridge {
+		area = ""
+	}
+	if err := b.server.BridgeMessage(area, name, text); err != nil {
+		_, _ = s.ChannelMessageSendReply(m.ChannelID, fmt.Sprintf("Your message was not relayed: %v.", err), m.Reference())
+	}
+}
@@ -0,0 +1,54 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// handleCmd handles the /cmd command, which runs an in-game command with the permissions of the member's roles.
+// The command line and its output can hold passwords and other secrets, so only the member sees the response.
+func (b *Bot) handleCmd(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	// With open access every member has every permission, which is too much to let anyone run any in-game command.
+	if b.openAccess() {
+		respondEmbedEphemeral(s, i, errorEmbed("/cmd can only be used once moderator roles are configured."))
+		return
+	}
+	line := optionString(i.ApplicationCommandData().Options, "command")
+	perms, _ := b.memberPerms(i.Member.Roles)
+
+	out := strings.Join(b.server.RunCommand(interactionUserID(i), interactionUser(i), perms, line), "\n")
+	if out == "" {
+		out = "(no output)"
+	}
+	out = strings.ReplaceAll(out, "```", "`\u200b``")
+	if len(out) > 4000 {
+		out = out[:4000] + "\n…(truncated)"
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       truncate(fmt.Sprintf("⌨️ %s", line), 256),
+		Description: fmt.Sprintf("```\n%s\n```", out),
+		Color:       colorBlue,
+	}
+	respondEmbedEphemeral(s, i, embed)
+}
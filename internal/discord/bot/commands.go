@@ -38,6 +38,18 @@ func applicationCommands() []*discordgo.ApplicationCommand {
 				},
 			},
 		},
+		// Account linking
+		{
+			Name:        "link",
+			Description: "Link your Discord account using a code from /link in game.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "code", Description: "The code shown by /link in game.", Required: true},
+			},
+		},
+		{
+			Name:        "unlink",
+			Description: "Unlink your Discord account from the server.",
+		},
 		// Player information
 		{
 			Name:        "players",
@@ -230,6 +242,13 @@ func applicationCommands() []*discordgo.ApplicationCommand {
 				{Type: discordgo.ApplicationCommandOptionString, Name: "message", Description: "Message to send.", Required: true},
 			},
 		},
+		{
+			Name:        "cmd",
+			Description: "Run an in-game command with the permissions of your roles.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "command", Description: "The command line, e.g. /getban -i abc123.", Required: true, MaxLength: 200},
+			},
+		},
 		// Area control
 		{
 			Name:        "forcemove",
@@ -273,12 +292,57 @@ func applicationCommands() []*discordgo.ApplicationCommand {
 			Description: "View the server audit log.",
 			Options: []*discordgo.ApplicationCommandOption{
 				{Type: discordgo.ApplicationCommandOptionString, Name: "filter", Description: "Optional filter string.", Required: false},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "moderator", Description: "Moderator name or IPID.", Required: false},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "target", Description: "Target, such as an IPID.", Required: false},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "action", Description: "Action, such as ban, kick or login.", Required: false},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "period", Description: "How far back to look, e.g. 1d.", Required: false},
+			},
+		},
+		{
+			Name:        "search",
+			Description: "Search archived IC and OOC messages.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "query", Description: "Words to search for.", Required: true, MaxLength: 100},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "area", Description: "Only search this area.", Required: false},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "period", Description: "How far back to look, e.g. 1d.", Required: false},
 			},
 		},
 		{
 			Name:        "banlist",
 			Description: "View the list of banned players.",
 		},
+		{
+			Name:        "pending",
+			Description: "View moderator actions awaiting approval.",
+		},
+		{
+			Name:        "modstats",
+			Description: "Summarise moderator activity.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "moderator", Description: "Moderator account name. Defaults to all moderators.", Required: false},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "period", Description: "How far back to look, e.g. 1d, 2w. Defaults to 1w.", Required: false},
+			},
+		},
+		// Modcall tickets
+		{
+			Name:        "modcalls",
+			Description: "View open modcall tickets.",
+		},
+		{
+			Name:        "claim",
+			Description: "Claim a modcall ticket.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "id", Description: "Modcall ID.", Required: true},
+			},
+		},
+		{
+			Name:        "resolve",
+			Description: "Resolve a modcall ticket.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "id", Description: "Modcall ID.", Required: true},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "note", Description: "What was done about it.", Required: true},
+			},
+		},
 	}
 }
 
@@ -287,7 +351,7 @@ func (b *Bot) registerCommands() error {
 	cmds := applicationCommands()
 	registered := make([]*discordgo.ApplicationCommand, 0, len(cmds))
 	for _, cmd := range cmds {
-		created, err := b.session.ApplicationCommandCreate(b.session.State.User.ID, b.guildID, cmd)
+		created, err := b.session.ApplicationCommandCreate(b.userID, b.guildID, cmd)
 		if err != nil {
 			return fmt.Errorf("failed to register command %q: %w", cmd.Name, err)
 		}
@@ -298,10 +362,13 @@ func (b *Bot) registerCommands() error {
 }
 
 // commandHandlers returns the mapping of command names to handler functions.
-func (b *Bot) commandHandlers() map[string]func(*discordgo.Session, *discordgo.InteractionCreate) {
-	return map[string]func(*discordgo.Session, *discordgo.InteractionCreate){
+func (b *Bot) commandHandlers() map[string]func(Session, *discordgo.InteractionCreate) {
+	return map[string]func(Session, *discordgo.InteractionCreate){
 		// Help
 		"help": b.handleHelp,
+		// Account linking
+		"link":   b.handleLink,
+		"unlink": b.handleUnlink,
 		// Player information
 		"players": b.handlePlayers,
 		"info":    b.handleInfo,
@@ -330,6 +397,7 @@ func (b *Bot) commandHandlers() map[string]func(*discordgo.Session, *discordgo.I
 		"pm":              b.handlePM,
 		"announce":        b.handleAnnounce,
 		"announce_player": b.handleAnnouncePlayer,
+		"cmd":             b.handleCmd,
 		// Area control
 		"forcemove": b.handleForceMove,
 		"cleararea": b.handleClearArea,
@@ -338,6 +406,30 @@ func (b *Bot) commandHandlers() map[string]func(*discordgo.Session, *discordgo.I
 		// Audit & Logs
 		"logs":     b.handleLogs,
 		"auditlog": b.handleAuditLog,
+		"search":   b.handleSearch,
 		"banlist":  b.handleBanList,
+		"pending":  b.handlePending,
+		"modstats": b.handleModStats,
+		// Modcall tickets
+		"modcalls": b.handleModcalls,
+		"claim":    b.handleClaim,
+		"resolve":  b.handleResolve,
+	}
+}
+
+// componentHandlers returns the mapping of message component and modal custom ID prefixes to handler functions.
+// Custom IDs take the form "<prefix>:<argument>".
+func (b *Bot) componentHandlers() map[string]func(Session, *discordgo.InteractionCreate, string) {
+	return map[string]func(Session, *discordgo.InteractionCreate, string){
+		"modcall_claim":         b.handleClaimButton,
+		"modcall_resolve":       b.handleResolveButton,
+		"modcall_resolve_modal": b.handleResolveModal,
+		"modcall_mute":          b.handleModcallMuteButton,
+		"modcall_kick":          b.handleModcallKickButton,
+		"modcall_ban":           b.handleModcallBanButton,
+		"modcall_ban_modal":     b.handleModcallBanModal,
+		"modcall_log":           b.handleModcallLogButton,
+		"login_approve":         b.handleLoginApprove,
+		"login_deny":            b.handleLoginDeny,
 	}
 }
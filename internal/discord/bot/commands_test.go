@@ -0,0 +1,408 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	testModRole  = "111"
+	testUserID   = "222"
+	testUsername = "edgeworth"
+)
+
+// newTestBot returns a bot connected to a fake session and server, where testModRole grants every permission.
+func newTestBot() (*Bot, *fakeSession, *fakeServer) {
+	s, srv := newFakeSession(), newFakeServer()
+	b := &Bot{
+		session: s,
+		userID:  "bot",
+		guildID: "guild",
+		server:  srv,
+		roles:   map[string]string{testModRole: "moderator"},
+	}
+	return b, s, srv
+}
+
+// testOptionValue returns a value for a command option that the fake server recognises.
+func testOptionValue(cmd string, o *discordgo.ApplicationCommandOption) interface{} {
+	if o.Type == discordgo.ApplicationCommandOptionInteger {
+		return float64(1) // Integer options are decoded from JSON as floats.
+	}
+	switch o.Name {
+	case "player":
+		return "1"
+	case "area":
+		return fakeArea.Name
+	case "duration", "period":
+		return "1h"
+	case "command":
+		if cmd == "help" {
+			return "ban"
+		}
+		return "/getban -b 1"
+	}
+	return "test " + o.Name
+}
+
+// testCommand returns a slash command interaction from a member with the given roles, filling in every option.
+func testCommand(name string, roles ...string) *discordgo.InteractionCreate {
+	data := discordgo.ApplicationCommandInteractionData{Name: name}
+	for _, c := range applicationCommands() {
+		if c.Name != name {
+			continue
+		}
+		for _, o := range c.Options {
+			data.Options = append(data.Options, &discordgo.ApplicationCommandInteractionDataOption{
+				Name: o.Name, Type: o.Type, Value: testOptionValue(name, o),
+			})
+		}
+	}
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:   discordgo.InteractionApplicationCommand,
+		Data:   data,
+		Member: &discordgo.Member{User: &discordgo.User{ID: testUserID, Username: testUsername}, Roles: roles},
+	}}
+}
+
+// isError reports whether an embed was made by errorEmbed.
+func isError(e *discordgo.MessageEmbed) bool {
+	return e != nil && e.Title == errorEmbed("").Title
+}
+
+// responseEmbed returns the first embed of an interaction response, or nil if it has none.
+func responseEmbed(r *discordgo.InteractionResponse) *discordgo.MessageEmbed {
+	if r == nil || r.Data == nil || len(r.Data.Embeds) == 0 {
+		return nil
+	}
+	return r.Data.Embeds[0]
+}
+
+// TestCommandsHaveHandlers tests that every registered slash command has a handler and help, and vice versa
+func TestCommandsHaveHandlers(t *testing.T) {
+	b, _, _ := newTestBot()
+	handlers := b.commandHandlers()
+	for _, c := range applicationCommands() {
+		if _, ok := handlers[c.Name]; !ok {
+			t.Errorf("/%v has no handler", c.Name)
+		}
+		if _, ok := commandHelp[c.Name]; !ok {
+			t.Errorf("/%v has no help", c.Name)
+		}
+	}
+	if len(handlers) != len(applicationCommands()) {
+		t.Errorf("%v handlers for %v commands", len(handlers), len(applicationCommands()))
+	}
+}
+
+// TestCommandHandlers tests that every command responds exactly once, without an error, when used by a moderator
+func TestCommandHandlers(t *testing.T) {
+	b, _, _ := newTestBot()
+	for name := range b.commandHandlers() {
+		t.Run(name, func(t *testing.T) {
+			b, s, _ := newTestBot()
+			b.commandHandlers()[name](s, testCommand(name, testModRole))
+			if n := len(s.responses); n != 1 {
+				t.Fatalf("/%v sent %v responses, want 1", name, n)
+			}
+			if e := responseEmbed(s.lastResponse()); isError(e) {
+				t.Errorf("/%v responded with an error: %v", name, e.Description)
+			}
+		})
+	}
+}
+
+// TestCommandPermissions tests that members without a mapped role can only use the commands open to everyone
+func TestCommandPermissions(t *testing.T) {
+	b, _, _ := newTestBot()
+	for name := range b.commandHandlers() {
+		t.Run(name, func(t *testing.T) {
+			b, s, srv := newTestBot()
+			b.commandHandlers()[name](s, testCommand(name, "333"))
+			r := s.lastResponse()
+			denied := r != nil && r.Data != nil && r.Data.Flags&discordgo.MessageFlagsEphemeral != 0 &&
+				responseEmbed(r) != nil && strings.Contains(responseEmbed(r).Description, "do not have permission")
+			if _, gated := b.requiredPerms(name); gated != denied {
+				t.Errorf("/%v: denied = %v, want %v", name, denied, gated)
+			}
+			if calls := srv.called(); denied && len(calls) > 0 {
+				t.Errorf("/%v acted without permission: %v", name, calls)
+			}
+		})
+	}
+}
+
+// TestCommandPermissionBits tests that commands are checked against the permissions of the member's roles
+func TestCommandPermissionBits(t *testing.T) {
+	b, s, srv := newTestBot()
+	srv.perms["muter"] = 1 << 10 // MUTE
+	b.roles["444"] = "muter"
+
+	b.handleMute(s, testCommand("mute", "444"))
+	b.handleBan(s, testCommand("ban", "444"))
+	calls := srv.called()
+	if len(calls) != 1 || !strings.HasPrefix(calls[0], "MutePlayer 1 1h0m0s") {
+		t.Errorf("called %q, want only MutePlayer", calls)
+	}
+	if e := responseEmbed(s.lastResponse()); e == nil || !strings.Contains(e.Description, "do not have permission") {
+		t.Errorf("/ban without BAN responded %+v", e)
+	}
+}
+
+// TestCommandPermissionOverrides tests that commands with an in-game equivalent follow its permissions
+func TestCommandPermissionOverrides(t *testing.T) {
+	b, s, srv := newTestBot()
+	srv.perms["muter"] = 1 << 10 // MUTE
+	b.roles["444"] = "muter"
+	srv.cmds["mute"] = 1 << 1 // KICK, as if overridden in commands.toml
+
+	b.handleMute(s, testCommand("mute", "444"))
+	if calls := srv.called(); len(calls) > 0 {
+		t.Errorf("/mute acted without the overridden permission: %v", calls)
+	}
+	if e := responseEmbed(s.lastResponse()); e == nil || !strings.Contains(e.Description, "do not have permission") {
+		t.Errorf("/mute without KICK responded %+v", e)
+	}
+}
+
+// TestPermaBanNeedsPermission tests that a permanent ban from a member without BAN_PERMA becomes a pending action
+func TestPermaBanNeedsPermission(t *testing.T) {
+	permaBan := func(role string) *discordgo.InteractionCreate {
+		i := testCommand("ban", role)
+		for _, o := range i.ApplicationCommandData().Options {
+			if o.Name == "duration" {
+				o.Value = ""
+			}
+		}
+		return i
+	}
+	b, s, srv := newTestBot()
+	srv.perms["banner"] = 1 << 2 // BAN
+	b.roles["444"] = "banner"
+
+	b.handleBan(s, permaBan("444"))
+	if calls := srv.called(); len(calls) != 1 || !strings.HasPrefix(calls[0], "RequestPermaBan "+testUserID+" abc123") {
+		t.Errorf("permanent /ban without BAN_PERMA called %q, want RequestPermaBan", calls)
+	}
+	b.handleBan(s, permaBan(testModRole))
+	if calls := srv.called(); len(calls) != 1 || !strings.HasPrefix(calls[0], "BanPlayer abc123 0s") {
+		t.Errorf("permanent /ban with BAN_PERMA called %q, want BanPlayer", calls)
+	}
+}
+
+// TestModcallPermaBanNeedsPermission tests that a permanent ban from a modcall's Ban button needs BAN_PERMA
+func TestModcallPermaBanNeedsPermission(t *testing.T) {
+	banModal := func(role string) *discordgo.InteractionCreate {
+		return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type: discordgo.InteractionModalSubmit,
+			Data: discordgo.ModalSubmitInteractionData{
+				CustomID: "modcall_ban_modal:1",
+				Components: []discordgo.MessageComponent{
+					&discordgo.ActionsRow{Components: []discordgo.MessageComponent{&discordgo.TextInput{CustomID: "duration"}}},
+					&discordgo.ActionsRow{Components: []discordgo.MessageComponent{&discordgo.TextInput{CustomID: "reason", Value: "spam"}}},
+				},
+			},
+			Member: &discordgo.Member{User: &discordgo.User{ID: testUserID, Username: testUsername}, Roles: []string{role}},
+		}}
+	}
+	b, s, srv := newTestBot()
+	srv.perms["banner"] = 1 << 2 // BAN
+	b.roles["444"] = "banner"
+
+	b.handleModcallBanModal(s, banModal("444"), "1")
+	if calls := srv.called(); len(calls) != 1 || calls[0] != "RequestPermaBan "+testUserID+" abc123 spam" {
+		t.Errorf("permanent ban without BAN_PERMA called %q, want RequestPermaBan", calls)
+	}
+	b.handleModcallBanModal(s, banModal(testModRole), "1")
+	if calls := srv.called(); len(calls) != 1 || calls[0] != "BanPlayer abc123 0s spam "+testUsername {
+		t.Errorf("permanent ban with BAN_PERMA called %q, want BanPlayer", calls)
+	}
+}
+
+// TestModcallAlertUpdates tests that a modcall alert's Claim button is disabled once the modcall is claimed
+func TestModcallAlertUpdates(t *testing.T) {
+	b, s, _ := newTestBot()
+	b.modcallChannelID = "modcalls"
+	m := fakeCall
+	if err := b.PostModcall(m); err != nil {
+		t.Fatalf("PostModcall() error: %v", err)
+	}
+	m.Status, m.ClaimedBy = "claimed", "mod1"
+	b.UpdateModcall(m)
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.msgEdits)
+		s.mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.msgEdits) != 1 {
+		t.Fatalf("UpdateModcall() made %v edits, want 1", len(s.msgEdits))
+	}
+	row := (*s.msgEdits[0].Components)[0].(discordgo.ActionsRow)
+	if claim := row.Components[0].(discordgo.Button); !claim.Disabled || claim.Label != "Claimed by mod1" {
+		t.Errorf("Claim button after a claim = %+v, want it disabled and naming the moderator", claim)
+	}
+
+	m.Status = "resolved"
+	b.UpdateModcall(m)
+	b.UpdateModcall(m)
+	if _, ok := b.alerts.messages[m.ID]; ok {
+		t.Error("alert is still tracked after the modcall was resolved")
+	}
+}
+
+// TestCmdResponse tests that /cmd replies only to the member, and that open access can't use it
+func TestCmdResponse(t *testing.T) {
+	b, s, srv := newTestBot()
+	b.handleCmd(s, testCommand("cmd", testModRole))
+	r := s.lastResponse()
+	if r == nil || r.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("/cmd response = %+v, want it ephemeral", r)
+	}
+	srv.called()
+
+	b.roles = nil
+	b.handleCmd(s, testCommand("cmd"))
+	if calls := srv.called(); len(calls) != 0 {
+		t.Errorf("/cmd with open access called %q, want it refused", calls)
+	}
+	if e := responseEmbed(s.lastResponse()); !isError(e) {
+		t.Errorf("/cmd with open access responded %+v, want an error", e)
+	}
+}
+
+// TestTruncate tests that long text is shortened to the limit with an ellipsis
+func TestTruncate(t *testing.T) {
+	if got := truncate("short", 256); got != "short" {
+		t.Errorf("truncate() = %q, want it unchanged", got)
+	}
+	if got := []rune(truncate(strings.Repeat("é", 300), 256)); len(got) != 256 || got[255] != '…' {
+		t.Errorf("truncate() returned %v characters ending in %q, want 256 ending in an ellipsis", len(got), got[len(got)-1])
+	}
+}
+
+// TestCommandActions tests that commands pass their options to the server
+func TestCommandActions(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"ban", "BanPlayer abc123 1h0m0s test reason " + testUsername},
+		{"kick", "KickPlayer 1 test reason " + testUsername},
+		{"unban", "UnbanByID 1"},
+		{"drunk", "ApplyPunishment 1 drunk 1h0m0s " + testUsername},
+		{"forcemove", "ForceMove 1 Basement"},
+		{"announce", "SendAnnouncement test message"},
+		{"link", "LinkDiscord test code " + testUserID},
+		{"cmd", "RunCommand " + testUserID + " " + testUsername + " 18446744073709551615 /getban -b 1"},
+		{"resolve", "ResolveModcall 1 " + testUsername + " test note"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, s, srv := newTestBot()
+			b.commandHandlers()[tt.name](s, testCommand(tt.name, testModRole))
+			if calls := srv.called(); len(calls) != 1 || calls[0] != tt.want {
+				t.Errorf("/%v called %q, want %q", tt.name, calls, tt.want)
+			}
+		})
+	}
+}
+
+// TestCommandServerError tests that errors from the server are reported to the moderator
+func TestCommandServerError(t *testing.T) {
+	b, s, srv := newTestBot()
+	srv.err = errTest
+	b.handleKick(s, testCommand("kick", testModRole))
+	if e := responseEmbed(s.lastResponse()); !isError(e) || !strings.Contains(e.Description, errTest.Error()) {
+		t.Errorf("/kick with a failing server responded %+v", e)
+	}
+}
+
+// TestRegisterCommands tests that every command is registered with Discord
+func TestRegisterCommands(t *testing.T) {
+	b, s, _ := newTestBot()
+	if err := b.registerCommands(); err != nil {
+		t.Fatalf("registerCommands() error: %v", err)
+	}
+	if len(s.commands) != len(applicationCommands()) || len(b.commands) != len(s.commands) {
+		t.Errorf("registered %v commands, want %v", len(s.commands), len(applicationCommands()))
+	}
+}
+
+// TestStatusStops tests that the status board stops when the bot does, even while waiting to edit the message
+func TestStatusStops(t *testing.T) {
+	b, _, _ := newTestBot()
+	b.status = newStatusBoard("status")
+	exited := make(chan struct{})
+	go func() {
+		b.runStatus()
+		close(exited)
+	}()
+	b.UpdateStatus()
+	b.Stop()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Error("runStatus() did not return after the bot stopped")
+	}
+	b.Stop() // Stopping again must not panic.
+}
+
+// TestStopClosesBridge tests that stopping the bot stops the chat bridge, and that OOC chat is no longer relayed afterwards
+func TestStopClosesBridge(t *testing.T) {
+	b, s, _ := newTestBot()
+	b.bridge = newBridge(map[string]string{"chan": GlobalBridge}, 0)
+	exited := make(chan struct{})
+	go func() {
+		b.bridge.run(s)
+		close(exited)
+	}()
+	b.Stop()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Error("bridge did not stop with the bot")
+	}
+	b.RelayOOC("Lobby", "name", "message") // Must not send on the closed queue.
+}
+
+// TestInfoShowsNotes tests that /info lists the moderator notes on the player
+func TestInfoShowsNotes(t *testing.T) {
+	b, s, _ := newTestBot()
+	b.handleInfo(s, testCommand("info", testModRole))
+	e := responseEmbed(s.lastResponse())
+	if e == nil {
+		t.Fatal("/info sent no embed")
+	}
+	for _, f := range e.Fields {
+		if strings.HasPrefix(f.Name, "📝 Notes (1)") && strings.Contains(f.Value, "**mod**: watch") {
+			return
+		}
+	}
+	t.Errorf("/info fields %+v, want the player's notes", e.Fields)
+}
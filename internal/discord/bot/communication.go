@@ -23,8 +23,8 @@ import (
 )
 
 // handlePM handles the /pm command.
-func (b *Bot) handlePM(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handlePM(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	opts := i.ApplicationCommandData().Options
@@ -45,8 +45,8 @@ func (b *Bot) handlePM(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 // handleAnnounce handles the /announce command.
-func (b *Bot) handleAnnounce(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleAnnounce(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	message := i.ApplicationCommandData().Options[0].StringValue()
@@ -58,8 +58,8 @@ func (b *Bot) handleAnnounce(s *discordgo.Session, i *discordgo.InteractionCreat
 }
 
 // handleAnnouncePlayer handles the /announce_player command.
-func (b *Bot) handleAnnouncePlayer(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleAnnouncePlayer(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	opts := i.ApplicationCommandData().Options
@@ -66,7 +66,7 @@ func infoEmbed(title, msg string) *discordgo.MessageEmbed {
 }
 
 // respondEmbed sends an embed as the interaction response.
-func respondEmbed(s *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) {
+func respondEmbed(s Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) {
 	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: embedResponse(embed),
@@ -74,7 +74,7 @@ func respondEmbed(s *discordgo.Session, i *discordgo.InteractionCreate, embed *d
 }
 
 // respondEmbedEphemeral sends an embed only visible to the invoking user.
-func respondEmbedEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) {
+func respondEmbedEphemeral(s Session, i *discordgo.InteractionCreate, embed *discordgo.MessageEmbed) {
 	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -83,3 +83,12 @@ func respondEmbedEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate,
 		},
 	})
 }
+
+// truncate shortens s to at most n characters, ending it with an ellipsis if anything was cut.
+// Discord rejects a whole message if any of its limits, such as 256 characters for embed titles, are exceeded.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,42 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// PostEvents posts a batch of server events to the event channel.
+// The server batches events itself, so each call is one message.
+func (b *Bot) PostEvents(events []string) error {
+	if b.eventChannelID == "" {
+		return fmt.Errorf("no event channel is configured")
+	}
+	_, err := b.session.ChannelMessageSendComplex(b.eventChannelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{{
+			Description: strings.Join(events, "\n"),
+			Color:       colorGray,
+			Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		}},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	return err
+}
@@ -0,0 +1,407 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/bwmarrin/discordgo"
+)
+
+// fakeMessage is a message sent through a fakeSession.
+type fakeMessage struct {
+	channelID string
+	data      *discordgo.MessageSend
+}
+
+// fakeSession is an in-memory Session that records what the bot sends to Discord.
+type fakeSession struct {
+	mu        sync.Mutex
+	responses []*discordgo.InteractionResponse
+	messages  []fakeMessage
+	edits     []*discordgo.MessageEmbed
+	msgEdits  []*discordgo.MessageEdit
+	reactions []string
+	commands  []*discordgo.ApplicationCommand
+	history   []*discordgo.Message
+	members   map[string]*discordgo.Member
+	nextID    int
+}
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{members: make(map[string]*discordgo.Member)}
+}
+
+func (f *fakeSession) id() string {
+	f.nextID++
+	return fmt.Sprint(f.nextID)
+}
+
+func (f *fakeSession) InteractionRespond(_ *discordgo.Interaction, resp *discordgo.InteractionResponse, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, resp)
+	return nil
+}
+
+func (f *fakeSession) ApplicationCommandCreate(_ string, _ string, cmd *discordgo.ApplicationCommand, _ ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	created := *cmd
+	created.ID = f.id()
+	f.commands = append(f.commands, &created)
+	return &created, nil
+}
+
+func (f *fakeSession) ApplicationCommandDelete(_ string, _ string, cmdID string, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for n, c := range f.commands {
+		if c.ID == cmdID {
+			f.commands = append(f.commands[:n], f.commands[n+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown command %v", cmdID)
+}
+
+func (f *fakeSession) ChannelMessages(_ string, limit int, _ string, _ string, _ string, _ ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.history) > limit {
+		return f.history[:limit], nil
+	}
+	return f.history, nil
+}
+
+func (f *fakeSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, fakeMessage{channelID: channelID, data: data})
+	return &discordgo.Message{ID: f.id(), ChannelID: channelID, Content: data.Content, Embeds: data.Embeds}, nil
+}
+
+func (f *fakeSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return f.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+func (f *fakeSession) ChannelMessageSendReply(channelID string, content string, reference *discordgo.MessageReference, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return f.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content, Reference: reference})
+}
+
+func (f *fakeSession) ChannelMessageEditEmbed(channelID string, messageID string, embed *discordgo.MessageEmbed, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.edits = append(f.edits, embed)
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Embeds: []*discordgo.MessageEmbed{embed}}, nil
+}
+
+func (f *fakeSession) ChannelMessageEditComplex(m *discordgo.MessageEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.msgEdits = append(f.msgEdits, m)
+	return &discordgo.Message{ID: m.ID, ChannelID: m.Channel}, nil
+}
+
+func (f *fakeSession) MessageReactionAdd(_ string, _ string, emojiID string, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reactions = append(f.reactions, emojiID)
+	return nil
+}
+
+func (f *fakeSession) GuildMember(_ string, userID string, _ ...discordgo.RequestOption) (*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if m, ok := f.members[userID]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown member %v", userID)
+}
+
+func (f *fakeSession) UserChannelCreate(recipientID string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: "dm-" + recipientID, Type: discordgo.ChannelTypeDM}, nil
+}
+
+// lastResponse returns the most recent interaction response, or nil if there were none.
+func (f *fakeSession) lastResponse() *discordgo.InteractionResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.responses) == 0 {
+		return nil
+	}
+	return f.responses[len(f.responses)-1]
+}
+
+// fakeServer is an in-memory ServerInterface with one player in one area, which records the actions taken on it.
+type fakeServer struct {
+	mu    sync.Mutex
+	calls []string
+	err   error // Returned by every action that can fail.
+	perms map[string]uint64
+	cmds  map[string]uint64 // Permissions of the in-game commands.
+}
+
+func newFakeServer() *fakeServer {
+	cmds := map[string]uint64{}
+	for name, perm := range map[string]string{
+		"mute": "MUTE", "unmute": "MUTE", "ban": "BAN", "unban": "BAN", "kick": "KICK",
+		"parrot": "PUNISH", "drunk": "PUNISH", "slowpoke": "PUNISH", "roulette": "PUNISH",
+		"spotlight": "PUNISH", "whisper": "PUNISH", "stutterstep": "PUNISH", "backward": "PUNISH",
+		"announce": "ANNOUNCE", "log": "LOG", "audit": "LOG", "search": "LOG", "getban": "BAN_INFO",
+		"pending": "BAN", "modstats": "ADMIN", "modcalls": "KICK", "claim": "KICK", "resolve": "KICK",
+	} {
+		cmds[name] = permissions.PermissionField[perm]
+	}
+	return &fakeServer{perms: map[string]uint64{"moderator": ^uint64(0)}, cmds: cmds}
+}
+
+func (f *fakeServer) record(format string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+}
+
+// called returns the actions recorded since the last call.
+func (f *fakeServer) called() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := f.calls
+	f.calls = nil
+	return calls
+}
+
+var (
+	fakePlayer = PlayerInfo{UID: 1, Character: "Phoenix", OOCName: "nick", Area: "Basement", IPID: "abc123"}
+	fakeArea   = AreaInfo{Index: 0, Name: "Basement", PlayerCount: 1, Status: "IDLE", Lock: "FREE", CMs: []string{"Phoenix (1)"}}
+	fakeCall   = ModcallRecord{ID: 1, Time: 0, Area: "Basement", CallerUID: 1, Caller: "Phoenix", IPID: "abc123", Reason: "help", Status: "open"}
+)
+
+func (f *fakeServer) GetPlayers() []PlayerInfo { return []PlayerInfo{fakePlayer} }
+
+func (f *fakeServer) FindPlayer(name string) *PlayerInfo {
+	if name == fmt.Sprint(fakePlayer.UID) || name == fakePlayer.OOCName || name == fakePlayer.Character {
+		p := fakePlayer
+		return &p
+	}
+	return nil
+}
+
+func (f *fakeServer) GetPlayerByUID(uid int) *PlayerInfo {
+	if uid == fakePlayer.UID {
+		p := fakePlayer
+		return &p
+	}
+	return nil
+}
+
+func (f *fakeServer) GetAreas() []AreaInfo { return []AreaInfo{fakeArea} }
+
+func (f *fakeServer) FindArea(name string) *AreaInfo {
+	if name == fakeArea.Name {
+		a := fakeArea
+		return &a
+	}
+	return nil
+}
+
+func (f *fakeServer) MutePlayer(uid int, duration time.Duration, reason string, moderator string) error {
+	f.record("MutePlayer %v %v %v %v", uid, duration, reason, moderator)
+	return f.err
+}
+
+func (f *fakeServer) UnmutePlayer(uid int) error {
+	f.record("UnmutePlayer %v", uid)
+	return f.err
+}
+
+func (f *fakeServer) KickPlayer(uid int, reason string, moderator string) error {
+	f.record("KickPlayer %v %v %v", uid, reason, moderator)
+	return f.err
+}
+
+func (f *fakeServer) BanPlayer(ipid string, duration time.Duration, reason string, moderator string) error {
+	f.record("BanPlayer %v %v %v %v", ipid, duration, reason, moderator)
+	return f.err
+}
+
+func (f *fakeServer) RequestPermaBan(discordID string, ipid string, reason string) (int, error) {
+	f.record("RequestPermaBan %v %v %v", discordID, ipid, reason)
+	return 1, f.err
+}
+
+func (f *fakeServer) GagPlayer(uid int) error {
+	f.record("GagPlayer %v", uid)
+	return f.err
+}
+
+func (f *fakeServer) UngagPlayer(uid int) error {
+	f.record("UngagPlayer %v", uid)
+	return f.err
+}
+
+func (f *fakeServer) WarnPlayer(uid int, reason string, moderator string) error {
+	f.record("WarnPlayer %v %v %v", uid, reason, moderator)
+	return f.err
+}
+
+func (f *fakeServer) GetWarnings(_ string) []WarnRecord {
+	return []WarnRecord{{Reason: "spam", Moderator: "mod", Time: 0}}
+}
+
+func (f *fakeServer) GetNotes(_ string) []NoteRecord {
+	return []NoteRecord{{ID: 1, Author: "mod", Text: "watch", Time: 0}}
+}
+
+func (f *fakeServer) GetBanList() []BanRecord {
+	return []BanRecord{{ID: 1, IPID: "def456", Reason: "spam", Duration: -1, Moderator: "mod", Time: 0}}
+}
+
+func (f *fakeServer) UnbanByID(id int) error {
+	f.record("UnbanByID %v", id)
+	return f.err
+}
+
+func (f *fakeServer) GetPendingActions() []PendingActionRecord {
+	return []PendingActionRecord{{ID: 1, Action: "ban", Targets: []string{"abc123"}, Reason: "spam", Moderator: "mod"}}
+}
+
+func (f *fakeServer) GetModcalls() []ModcallRecord { return []ModcallRecord{fakeCall} }
+
+func (f *fakeServer) GetModcall(id int) *ModcallRecord {
+	if id == fakeCall.ID {
+		m := fakeCall
+		return &m
+	}
+	return nil
+}
+
+func (f *fakeServer) ClaimModcall(id int, moderator string) error {
+	f.record("ClaimModcall %v %v", id, moderator)
+	return f.err
+}
+
+func (f *fakeServer) ResolveModcall(id int, moderator string, note string) error {
+	f.record("ResolveModcall %v %v %v", id, moderator, note)
+	return f.err
+}
+
+func (f *fakeServer) ApplyPunishment(uid int, punishmentName string, duration time.Duration, moderator string) error {
+	f.record("ApplyPunishment %v %v %v %v", uid, punishmentName, duration, moderator)
+	return f.err
+}
+
+func (f *fakeServer) RemovePunishment(uid int, punishmentName string) error {
+	f.record("RemovePunishment %v %v", uid, punishmentName)
+	return f.err
+}
+
+func (f *fakeServer) SendPrivateMessage(uid int, message string) error {
+	f.record("SendPrivateMessage %v %v", uid, message)
+	return f.err
+}
+
+func (f *fakeServer) SendAnnouncement(message string) error {
+	f.record("SendAnnouncement %v", message)
+	return f.err
+}
+
+func (f *fakeServer) SendAnnouncementToPlayer(uid int, message string) error {
+	f.record("SendAnnouncementToPlayer %v %v", uid, message)
+	return f.err
+}
+
+func (f *fakeServer) LinkDiscord(code string, discordID string) (string, error) {
+	f.record("LinkDiscord %v %v", code, discordID)
+	return "your player identity", f.err
+}
+
+func (f *fakeServer) UnlinkDiscord(discordID string) (int, error) {
+	f.record("UnlinkDiscord %v", discordID)
+	return 1, f.err
+}
+
+func (f *fakeServer) AnswerDiscordLogin(id int, discordID string, approve bool) (string, error) {
+	f.record("AnswerDiscordLogin %v %v %v", id, discordID, approve)
+	return "mod", f.err
+}
+
+func (f *fakeServer) BridgeMessage(area string, author string, message string) error {
+	f.record("BridgeMessage %v %v %v", area, author, message)
+	return f.err
+}
+
+func (f *fakeServer) RunCommand(discordID string, name string, perms uint64, line string) []string {
+	f.record("RunCommand %v %v %v %v", discordID, name, perms, line)
+	return []string{"Done."}
+}
+
+func (f *fakeServer) ForceMove(uid int, areaName string) error {
+	f.record("ForceMove %v %v", uid, areaName)
+	return f.err
+}
+
+func (f *fakeServer) ClearArea(areaName string) error {
+	f.record("ClearArea %v", areaName)
+	return f.err
+}
+
+func (f *fakeServer) LockArea(areaName string, moderator string) error {
+	f.record("LockArea %v %v", areaName, moderator)
+	return f.err
+}
+
+func (f *fakeServer) UnlockArea(areaName string, moderator string) error {
+	f.record("UnlockArea %v %v", areaName, moderator)
+	return f.err
+}
+
+func (f *fakeServer) GetPlayerLogs(_ string) []string { return []string{"joined"} }
+
+func (f *fakeServer) GetAuditLog(_ AuditQuery) []string { return []string{"mod banned abc123"} }
+
+func (f *fakeServer) GetModStats(moderator string, _ time.Duration) ([]ModStatsRecord, error) {
+	return []ModStatsRecord{{Moderator: "mod", Online: time.Hour, Logins: 1}}, f.err
+}
+
+func (f *fakeServer) SearchChat(_ ChatSearchQuery) ([]string, error) {
+	return []string{"[01 Jan 00:00] Basement | OOC | nick (abc123): hello"}, f.err
+}
+
+func (f *fakeServer) GetRolePermissions(name string) (uint64, bool) {
+	p, ok := f.perms[name]
+	return p, ok
+}
+
+func (f *fakeServer) CommandPerms(name string) (uint64, bool) {
+	p, ok := f.cmds[name]
+	return p, ok
+}
+
+func (f *fakeServer) GetServerName() string { return "Test Server" }
+
+func (f *fakeServer) GetPlayerCount() int { return 1 }
+
+func (f *fakeServer) GetMaxPlayers() int { return 100 }
+
+var _ ServerInterface = (*fakeServer)(nil)
+
+// errTest is returned by a fakeServer whose actions fail.
+var errTest = fmt.Errorf("test failure")
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -32,13 +33,15 @@ var commandHelp = map[string]struct {
 	related  []string
 }{
 	"help":            {"/help [command]", "Display all commands or detailed info for a specific command.", "None", "/help ban", []string{}},
+	"link":            {"/link <code>", "Link your Discord account to your moderator account or player using a code from /link in game. Linked moderators can log in with /login <username> (or /login <username> -d <code> with two-factor authentication) and approve it here; linked players get case announcements by DM for the roles they have case alerts enabled for in game.", "None", "/link K7QX2M9A", []string{"unlink"}},
+	"unlink":          {"/unlink", "Remove every link between your Discord account and the server.", "None", "/unlink", []string{"link"}},
 	"players":         {"/players", "List all currently connected players.", "Moderator", "/players", []string{"info", "find", "status"}},
 	"info":            {"/info <player>", "Get detailed information about a specific player (UID, character, area, IPID).", "Moderator", "/info 5", []string{"find", "players"}},
 	"find":            {"/find <player>", "Find which area a player is currently in.", "Moderator", "/find Phoenix", []string{"info", "players"}},
 	"status":          {"/status", "Get server status, player count, and area statistics.", "Moderator", "/status", []string{"players"}},
 	"mute":            {"/mute <player> [duration] [reason]", "Mute a player from IC and OOC chat.", "Moderator", "/mute 3 30m Spamming", []string{"unmute", "gag"}},
 	"unmute":          {"/unmute <player>", "Remove a mute from a player.", "Moderator", "/unmute 3", []string{"mute"}},
-	"ban":             {"/ban <player> [duration] <reason>", "Ban a player from the server.", "Moderator", "/ban 3 3d Rule violation", []string{"unban", "kick"}},
+	"ban":             {"/ban <player> [duration] <reason>", "Ban a player from the server. Without BAN_PERMA, a permanent ban needs a linked moderator account (/link) and waits for another moderator to /approve it in-game.", "Moderator", "/ban 3 3d Rule violation", []string{"unban", "kick"}},
 	"unban":           {"/unban <id>", "Unban a player by their ban ID.", "Moderator", "/unban 42", []string{"ban", "banlist"}},
 	"kick":            {"/kick <player> [reason]", "Kick a player from the server.", "Moderator", "/kick 3 Disconnecting", []string{"ban", "mute"}},
 	"gag":             {"/gag <player>", "Prevent a player from speaking in IC chat.", "Moderator", "/gag 3", []string{"ungag", "mute"}},
@@ -56,17 +59,24 @@ var commandHelp = map[string]struct {
 	"pm":              {"/pm <player> <message>", "Send a private server message to a player.", "Moderator", "/pm 3 Hello!", []string{"announce"}},
 	"announce":        {"/announce <message>", "Send a server-wide announcement to all players.", "Moderator", "/announce Welcome everyone!", []string{"pm", "announce_player"}},
 	"announce_player": {"/announce_player <player> <message>", "Send an announcement to a specific player.", "Moderator", "/announce_player 3 You're special!", []string{"announce", "pm"}},
+	"cmd":             {"/cmd <command>", "Run any in-game command and see its output. In-game permissions are checked as usual, using the roles mapped to your Discord roles. Area commands act on the first area. Only you see the output, and commands that take passwords or act on your own character can only be used in game.", "Moderator", "/cmd /getban -b 42", []string{"pm", "announce"}},
 	"forcemove":       {"/forcemove <player> <area>", "Force move a player to a specified area.", "Moderator", "/forcemove 3 Courtroom", []string{"cleararea"}},
 	"cleararea":       {"/cleararea <area>", "Force move all players out of an area.", "Moderator", "/cleararea Lobby", []string{"forcemove", "lock"}},
 	"lock":            {"/lock <area>", "Lock an area so only invited players can enter.", "Moderator", "/lock Courtroom", []string{"unlock"}},
 	"unlock":          {"/unlock <area>", "Unlock a previously locked area.", "Moderator", "/unlock Courtroom", []string{"lock"}},
 	"logs":            {"/logs <player>", "View recent activity logs for a player.", "Moderator", "/logs 3", []string{"auditlog"}},
-	"auditlog":        {"/auditlog [filter]", "View the server audit log with an optional filter.", "Moderator", "/auditlog ban", []string{"logs"}},
+	"auditlog":        {"/auditlog [filter] [moderator] [target] [action] [period]", "Search the server audit log by text, moderator, target, action and time.", "Moderator", "/auditlog action:ban period:1d", []string{"logs"}},
+	"search":          {"/search <query> [area] [period]", "Search archived IC and OOC messages, optionally in one area or time period.", "Moderator", "/search query:objection area:Courtroom period:1d", []string{"logs", "auditlog"}},
 	"banlist":         {"/banlist", "View the full list of currently banned players.", "Moderator", "/banlist", []string{"ban", "unban"}},
+	"modcalls":        {"/modcalls", "View open modcall tickets, with buttons to claim or resolve them.", "Moderator", "/modcalls", []string{"claim", "resolve"}},
+	"claim":           {"/claim <id>", "Claim a modcall ticket so other moderators know you are handling it.", "Moderator", "/claim 12", []string{"modcalls", "resolve"}},
+	"resolve":         {"/resolve <id> <note>", "Resolve a modcall ticket with a note on what was done.", "Moderator", "/resolve 12 Warned both players", []string{"modcalls", "claim"}},
+	"pending":         {"/pending", "View permanent bans and other actions waiting for a second moderator to /approve in-game.", "Moderator", "/pending", []string{"ban", "banlist"}},
+	"modstats":        {"/modstats [moderator] [period]", "Summarise time online, bans, kicks, mutes and modcalls claimed per moderator account.", "Moderator", "/modstats period:30d", []string{"auditlog"}},
 }
 
 // handleHelp handles the /help command.
-func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (b *Bot) handleHelp(s Session, i *discordgo.InteractionCreate) {
 	options := i.ApplicationCommandData().Options
 
 	// /help <command> – detailed help for a specific command
@@ -78,6 +88,10 @@ func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 			return
 		}
 
+		perms := info.perms
+		if p, ok := b.requiredPerms(cmd); ok && p != permissions.PermissionField["NONE"] {
+			perms = fmt.Sprintf("%s (`%s`)", perms, permissionNames(p))
+		}
 		embed := &discordgo.MessageEmbed{
 			Title:       fmt.Sprintf("📖 Command: /%s", cmd),
 			Description: info.desc,
@@ -85,7 +99,7 @@ func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 			Fields: []*discordgo.MessageEmbedField{
 				{Name: "Usage", Value: fmt.Sprintf("`%s`", info.usage), Inline: false},
 				{Name: "Example", Value: fmt.Sprintf("`%s`", info.example), Inline: false},
-				{Name: "Required Permissions", Value: info.perms, Inline: true},
+				{Name: "Required Permissions", Value: perms, Inline: true},
 			},
 		}
 		if len(info.related) > 0 {
@@ -105,9 +119,15 @@ func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	// /help – categorized overview of all commands
 	embed := &discordgo.MessageEmbed{
 		Title:       "📋 Nyathena Moderation Bot — Help",
-		Description: "Use `/help <command>` for detailed information about a specific command.\nAll commands require the **Moderator** role unless stated otherwise.",
+		Description: "Use `/help <command>` for detailed information about a specific command.\nAll commands require a **Moderator** role with the same permission as the in-game command unless stated otherwise.",
 		Color:       colorBlue,
 		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name: "🔗 Account Linking (anyone)",
+				Value: "`/link` — Link your account with a code from the game\n" +
+					"`/unlink` — Remove your links",
+				Inline: false,
+			},
 			{
 				Name: "📊 Player Information",
 				Value: "`/players` — List connected players\n" +
@@ -125,6 +145,12 @@ func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 					"`/warn` `/warnings` — Warnings system",
 				Inline: false,
 			},
+			{
+				Name: "🚨 Modcalls",
+				Value: "`/modcalls` — Open modcall tickets\n" +
+					"`/claim` `/resolve` — Claim/resolve a ticket",
+				Inline: false,
+			},
 			{
 				Name: "🎭 Custom Punishments",
 				Value: "`/parrot` `/drunk` `/slowpoke`\n" +
@@ -136,7 +162,8 @@ func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 				Name: "💬 Communication",
 				Value: "`/pm` — Private message a player\n" +
 					"`/announce` — Server-wide announcement\n" +
-					"`/announce_player` — Announcement to one player",
+					"`/announce_player` — Announcement to one player\n" +
+					"`/cmd` — Run an in-game command",
 				Inline: false,
 			},
 			{
@@ -150,7 +177,10 @@ func (b *Bot) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
 				Name: "📝 Audit & Logs",
 				Value: "`/logs` — Player activity logs\n" +
 					"`/auditlog` — Server audit log\n" +
-					"`/banlist` — List of banned players",
+					"`/search` — Search chat history\n" +
+					"`/banlist` — List of banned players\n" +
+					"`/pending` — Actions awaiting approval\n" +
+					"`/modstats` — Moderator activity",
 				Inline: false,
 			},
 		},
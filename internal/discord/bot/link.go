@@ -0,0 +1,155 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package bot
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// interactionUserID returns the ID of the Discord user who triggered an interaction, in a server or a DM.
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// hasMappedRole reports whether a Discord user currently has one of the roles that grant permissions in the bot's server.
+// Interactions in DMs carry no roles, so the member is looked up instead.
+func (b *Bot) hasMappedRole(userID string) bool {
+	member, err := b.session.GuildMember(b.guildID, userID)
+	if err != nil {
+		return false
+	}
+	_, ok := b.memberPerms(member.Roles)
+	return ok
+}
+
+// handleLink handles the /link command.
+func (b *Bot) handleLink(s Session, i *discordgo.InteractionCreate) {
+	code := optionString(i.ApplicationCommandData().Options, "code")
+	linked, err := b.server.LinkDiscord(code, interactionUserID(i))
+	if err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("Failed to link: %v", err)))
+		return
+	}
+	respondEmbedEphemeral(s, i, successEmbed("Account Linked", fmt.Sprintf("Your Discord account is now linked to %s.", linked)))
+}
+
+// handleUnlink handles the /unlink command.
+func (b *Bot) handleUnlink(s Session, i *discordgo.InteractionCreate) {
+	n, err := b.server.UnlinkDiscord(interactionUserID(i))
+	if err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("Failed to unlink: %v", err)))
+		return
+	}
+	if n == 0 {
+		respondEmbedEphemeral(s, i, infoEmbed("🔗 Unlink", "Your Discord account is not linked."))
+		return
+	}
+	respondEmbedEphemeral(s, i, successEmbed("Account Unlinked", "Your Discord account is no longer linked to the server."))
+}
+
+// sendDM sends an embed to a Discord user in a direct message.
+func (b *Bot) sendDM(userID string, msg *discordgo.MessageSend) error {
+	channel, err := b.session.UserChannelCreate(userID)
+	if err != nil {
+		return err
+	}
+	_, err = b.session.ChannelMessageSendComplex(channel.ID, msg)
+	return err
+}
+
+// RequestLogin asks a linked moderator in a DM to approve a login to their account from the game.
+func (b *Bot) RequestLogin(userID string, account string, ipid string, id int) error {
+	embed := &discordgo.MessageEmbed{
+		Title:       "🔐 Login Request",
+		Description: fmt.Sprintf("Someone is logging in to **%s** on %s from IPID `%s`.\nIf this wasn't you, deny the request and change your password.", account, b.server.GetServerName(), ipid),
+		Color:       colorGold,
+	}
+	return b.sendDM(userID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Approve", Style: discordgo.SuccessButton, CustomID: fmt.Sprintf("login_approve:%d", id)},
+			discordgo.Button{Label: "Deny", Style: discordgo.DangerButton, CustomID: fmt.Sprintf("login_deny:%d", id)},
+		}}},
+	})
+}
+
+// handleLoginApprove handles the Approve button on a login request.
+// Approval also requires the moderator role, so removing someone's role in Discord stops them logging in this way.
+func (b *Bot) handleLoginApprove(s Session, i *discordgo.InteractionCreate, arg string) {
+	if !b.hasMappedRole(interactionUserID(i)) {
+		respondEmbedEphemeral(s, i, errorEmbed("You no longer have a moderator role, so you can't log in with Discord."))
+		return
+	}
+	b.answerLogin(s, i, arg, true)
+}
+
+// handleLoginDeny handles the Deny button on a login request.
+func (b *Bot) handleLoginDeny(s Session, i *discordgo.InteractionCreate, arg string) {
+	b.answerLogin(s, i, arg, false)
+}
+
+// answerLogin approves or denies a login request, replacing its buttons with the outcome.
+func (b *Bot) answerLogin(s Session, i *discordgo.InteractionCreate, arg string, approve bool) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return
+	}
+	account, err := b.server.AnswerDiscordLogin(id, interactionUserID(i), approve)
+	var embed *discordgo.MessageEmbed
+	switch {
+	case err != nil:
+		embed = errorEmbed(fmt.Sprintf("This login request can no longer be answered: %v", err))
+	case approve:
+		embed = successEmbed("Login Approved", fmt.Sprintf("You are now logged in as **%s**.", account))
+	default:
+		embed = infoEmbed("🔐 Login Denied", fmt.Sprintf("The login to **%s** was denied.", account))
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
+
+// NotifyUsers sends a notification to Discord users in DMs. It returns immediately; messages are sent in the background.
+func (b *Bot) NotifyUsers(userIDs []string, title string, message string) {
+	if len(userIDs) == 0 {
+		return
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: message,
+		Color:       colorBlue,
+		Footer:      &discordgo.MessageEmbedFooter{Text: b.server.GetServerName() + " • Use /unlink to stop these messages."},
+	}
+	go func() {
+		for _, id := range userIDs {
+			_ = b.sendDM(id, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+		}
+	}()
+}
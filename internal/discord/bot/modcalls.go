@@ -0,0 +1,462 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxModcallButtons is the number of tickets that get buttons on a /modcalls response.
+// Discord allows 5 action rows per message, and each ticket uses one row.
+const maxModcallButtons = 5
+
+// modcallButtons returns an action row with Claim and Resolve buttons for a ticket.
+func modcallButtons(m ModcallRecord) discordgo.ActionsRow {
+	return discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.Button{
+			Label:    fmt.Sprintf("Claim #%d", m.ID),
+			Style:    discordgo.PrimaryButton,
+			CustomID: fmt.Sprintf("modcall_claim:%d", m.ID),
+			Disabled: m.Status != "open",
+		},
+		discordgo.Button{
+			Label:    fmt.Sprintf("Resolve #%d", m.ID),
+			Style:    discordgo.SuccessButton,
+			CustomID: fmt.Sprintf("modcall_resolve:%d", m.ID),
+		},
+	}}
+}
+
+// modcallMuteDuration is how long the Mute button on a modcall alert mutes the caller for.
+const modcallMuteDuration = 10 * time.Minute
+
+// modcallAlerts holds the alert messages posted for modcalls that aren't resolved yet,
+// so that their Claim buttons can be updated when the modcall is claimed or resolved.
+type modcallAlerts struct {
+	mu       sync.Mutex
+	messages map[int]string // Modcall IDs to message IDs.
+}
+
+// modcallAlertButtons returns the action row on a modcall alert posted to the modcall channel.
+// The Claim button is disabled, and says who claimed the modcall, once it is no longer open.
+func modcallAlertButtons(m ModcallRecord) discordgo.ActionsRow {
+	claim := discordgo.Button{Label: "Claim", Style: discordgo.PrimaryButton, CustomID: fmt.Sprintf("modcall_claim:%d", m.ID)}
+	switch m.Status {
+	case "claimed":
+		claim.Label, claim.Disabled = truncate("Claimed by "+m.ClaimedBy, 80), true
+	case "resolved":
+		claim.Label, claim.Disabled = "Resolved", true
+	}
+	return discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		claim,
+		discordgo.Button{Label: "Mute 10m", Style: discordgo.SecondaryButton, CustomID: fmt.Sprintf("modcall_mute:%d", m.ID)},
+		discordgo.Button{Label: "Kick", Style: discordgo.DangerButton, CustomID: fmt.Sprintf("modcall_kick:%d", m.ID)},
+		discordgo.Button{Label: "Ban", Style: discordgo.DangerButton, CustomID: fmt.Sprintf("modcall_ban:%d", m.ID)},
+		discordgo.Button{Label: "View log", Style: discordgo.SecondaryButton, CustomID: fmt.Sprintf("modcall_log:%d", m.ID)},
+	}}
+}
+
+// PostModcall posts a modcall alert with moderation buttons to the modcall channel.
+func (b *Bot) PostModcall(m ModcallRecord) error {
+	if b.modcallChannelID == "" {
+		return fmt.Errorf("no modcall channel is configured")
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🚨 Modcall #%d in %s", m.ID, m.Area),
+		Description: m.Reason,
+		Color:       colorOrange,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Caller", Value: fmt.Sprintf("%s [UID %d]", m.Caller, m.CallerUID), Inline: true},
+			{Name: "IPID", Value: fmt.Sprintf("`%s`", m.IPID), Inline: true},
+		},
+		Timestamp: time.Unix(m.Time, 0).UTC().Format(time.RFC3339),
+	}
+	if notes := b.server.GetNotes(m.IPID); len(notes) > 0 {
+		var lines []string
+		for _, n := range notes {
+			lines = append(lines, fmt.Sprintf("<t:%d:d> %s: %s", n.Time, n.Author, n.Text))
+		}
+		value := strings.Join(lines, "\n")
+		if len(value) > 1000 {
+			value = value[:1000] + "…"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: fmt.Sprintf("Notes (%d)", len(notes)), Value: value})
+	}
+	msg, err := b.session.ChannelMessageSendComplex(b.modcallChannelID, &discordgo.MessageSend{
+		Content:         b.modcallPing(),
+		Embeds:          []*discordgo.MessageEmbed{embed},
+		Components:      []discordgo.MessageComponent{modcallAlertButtons(m)},
+		AllowedMentions: &discordgo.MessageAllowedMentions{Roles: b.modcallPingRoles()},
+	})
+	if err != nil {
+		return err
+	}
+	b.alerts.mu.Lock()
+	if b.alerts.messages == nil {
+		b.alerts.messages = make(map[int]string)
+	}
+	b.alerts.messages[m.ID] = msg.ID
+	b.alerts.mu.Unlock()
+	return nil
+}
+
+// UpdateModcall updates the buttons on a modcall's alert after it is claimed or resolved.
+// It returns immediately; the alert is edited in the background.
+func (b *Bot) UpdateModcall(m ModcallRecord) {
+	b.alerts.mu.Lock()
+	msgID, ok := b.alerts.messages[m.ID]
+	if m.Status == "resolved" {
+		delete(b.alerts.messages, m.ID)
+	}
+	b.alerts.mu.Unlock()
+	if !ok {
+		return
+	}
+	components := []discordgo.MessageComponent{modcallAlertButtons(m)}
+	go func() {
+		edit := discordgo.NewMessageEdit(b.modcallChannelID, msgID)
+		edit.Components = &components
+		_, _ = b.session.ChannelMessageEditComplex(edit)
+	}()
+}
+
+// modcallPing returns the mention of the moderator role, if one is configured.
+func (b *Bot) modcallPing() string {
+	if b.modRoleID == "" {
+		return ""
+	}
+	return fmt.Sprintf("<@&%s>", b.modRoleID)
+}
+
+// modcallPingRoles returns the roles a modcall alert may ping.
+func (b *Bot) modcallPingRoles() []string {
+	if b.modRoleID == "" {
+		return nil
+	}
+	return []string{b.modRoleID}
+}
+
+// modcallCaller returns the modcall with the given custom ID argument and its caller, if they're still online.
+// The caller is matched by IPID as well as UID, as UIDs are reused after players disconnect.
+func (b *Bot) modcallCaller(arg string) (*ModcallRecord, *PlayerInfo, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid modcall ID")
+	}
+	m := b.server.GetModcall(id)
+	if m == nil {
+		return nil, nil, fmt.Errorf("no modcall with ID %d", id)
+	}
+	p := b.server.GetPlayerByUID(m.CallerUID)
+	if p == nil || p.IPID != m.IPID {
+		return m, nil, nil
+	}
+	return m, p, nil
+}
+
+// handleModcallMuteButton handles the Mute button on a modcall alert.
+func (b *Bot) handleModcallMuteButton(s Session, i *discordgo.InteractionCreate, arg string) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	m, p, err := b.modcallCaller(arg)
+	if err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(err.Error()))
+		return
+	}
+	if p == nil {
+		respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("%s is no longer online.", m.Caller)))
+		return
+	}
+	reason := fmt.Sprintf("Muted by %s for modcall #%d.", interactionUser(i), m.ID)
+	if err := b.server.MutePlayer(p.UID, modcallMuteDuration, reason, interactionUser(i)); err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("Failed to mute player: %v", err)))
+		return
+	}
+	respondEmbed(s, i, successEmbed("Player Muted", fmt.Sprintf("**%s** [UID %d] has been muted for 10m by %s (modcall **#%d**).", p.Character, p.UID, interactionUser(i), m.ID)))
+}
+
+// handleModcallKickButton handles the Kick button on a modcall alert.
+func (b *Bot) handleModcallKickButton(s Session, i *discordgo.InteractionCreate, arg string) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	m, p, err := b.modcallCaller(arg)
+	if err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(err.Error()))
+		return
+	}
+	if p == nil {
+		respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("%s is no longer online.", m.Caller)))
+		return
+	}
+	reason := fmt.Sprintf("Kicked by %s for modcall #%d.", interactionUser(i), m.ID)
+	if err := b.server.KickPlayer(p.UID, reason, interactionUser(i)); err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("Failed to kick player: %v", err)))
+		return
+	}
+	respondEmbed(s, i, successEmbed("Player Kicked", fmt.Sprintf("**%s** [UID %d] has been kicked by %s (modcall **#%d**).", p.Character, p.UID, interactionUser(i), m.ID)))
+}
+
+// handleModcallBanButton handles the Ban button on a modcall alert by asking for a duration and reason.
+// Bans are by IPID, so the caller can be banned after they leave.
+func (b *Bot) handleModcallBanButton(s Session, i *discordgo.InteractionCreate, arg string) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: "modcall_ban_modal:" + arg,
+			Title:    fmt.Sprintf("Ban Caller of Modcall #%s", arg),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:  "duration",
+						Label:     "Duration (e.g. 1d, 2w; blank for permanent)",
+						Style:     discordgo.TextInputShort,
+						Required:  false,
+						MaxLength: 20,
+					},
+				}},
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:  "reason",
+						Label:     "Reason",
+						Style:     discordgo.TextInputParagraph,
+						Required:  true,
+						MaxLength: 500,
+					},
+				}},
+			},
+		},
+	})
+}
+
+// handleModcallBanModal handles the duration and reason submitted from the Ban button.
+func (b *Bot) handleModcallBanModal(s Session, i *discordgo.InteractionCreate, arg string) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	m, _, err := b.modcallCaller(arg)
+	if err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(err.Error()))
+		return
+	}
+	durationStr := modalValue(i, "duration")
+	reason := modalValue(i, "reason")
+	dur, err := parseDuration(durationStr)
+	if err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(err.Error()))
+		return
+	}
+	// As in game, permanent bans need BAN_PERMA or another moderator's approval.
+	if dur <= 0 && !b.memberHas(i, "BAN_PERMA") {
+		id, err := b.server.RequestPermaBan(interactionUserID(i), m.IPID, reason)
+		if err != nil {
+			respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("Failed to create pending ban: %v", err)))
+			return
+		}
+		respondEmbed(s, i, infoEmbed("⏳ Ban Pending Approval", fmt.Sprintf("%s requested a permanent ban of **%s** (`%s`) for modcall **#%d**, which another moderator must approve in-game with /approve %d.\nReason: %s", interactionUser(i), m.Caller, m.IPID, m.ID, id, reason)))
+		return
+	}
+	if err := b.server.BanPlayer(m.IPID, dur, reason, interactionUser(i)); err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("Failed to ban player: %v", err)))
+		return
+	}
+	durDesc := "permanently"
+	if dur > 0 {
+		durDesc = "for " + durationStr
+	}
+	respondEmbed(s, i, successEmbed("Player Banned", fmt.Sprintf("**%s** (`%s`) has been banned %s by %s (modcall **#%d**).\nReason: %s", m.Caller, m.IPID, durDesc, interactionUser(i), m.ID, reason)))
+}
+
+// handleModcallLogButton handles the View log button on a modcall alert, showing the caller's recent activity to the moderator.
+func (b *Bot) handleModcallLogButton(s Session, i *discordgo.InteractionCreate, arg string) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	m, _, err := b.modcallCaller(arg)
+	if err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(err.Error()))
+		return
+	}
+	logs := b.server.GetPlayerLogs(m.IPID)
+	if len(logs) == 0 {
+		respondEmbedEphemeral(s, i, infoEmbed(fmt.Sprintf("📜 Logs — %s", m.Caller), "No log entries found."))
+		return
+	}
+	desc := strings.Join(logs, "\n")
+	if len(desc) > 4000 {
+		desc = "…(truncated)\n" + desc[len(desc)-4000:]
+	}
+	respondEmbedEphemeral(s, i, &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("📜 Logs — %s (modcall #%d)", m.Caller, m.ID),
+		Description: fmt.Sprintf("```\n%s\n```", desc),
+		Color:       colorPurple,
+	})
+}
+
+// modalValue returns the value of a text input in a submitted modal, or "" if there is none.
+func modalValue(i *discordgo.InteractionCreate, customID string) string {
+	for _, c := range i.ModalSubmitData().Components {
+		row, ok := c.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, rc := range row.Components {
+			if input, ok := rc.(*discordgo.TextInput); ok && input.CustomID == customID {
+				return strings.TrimSpace(input.Value)
+			}
+		}
+	}
+	return ""
+}
+
+// handleModcalls handles the /modcalls command.
+func (b *Bot) handleModcalls(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	calls := b.server.GetModcalls()
+	if len(calls) == 0 {
+		respondEmbed(s, i, infoEmbed("🚨 Modcalls", "There are no open modcalls."))
+		return
+	}
+
+	var lines []string
+	var rows []discordgo.MessageComponent
+	for _, m := range calls {
+		line := fmt.Sprintf("**#%d** <t:%d:R> — **%s** in %s (`%s`): %s", m.ID, m.Time, m.Caller, m.Area, m.IPID, m.Reason)
+		if m.ClaimedBy != "" {
+			line += fmt.Sprintf(" | Claimed by %s", m.ClaimedBy)
+		}
+		lines = append(lines, line)
+		if len(rows) < maxModcallButtons {
+			rows = append(rows, modcallButtons(m))
+		}
+	}
+
+	desc := strings.Join(lines, "\n")
+	if len(desc) > 4000 {
+		desc = desc[:4000] + "\n…(truncated)"
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🚨 Open Modcalls (%d)", len(calls)),
+		Description: desc,
+		Color:       colorOrange,
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: rows,
+		},
+	})
+}
+
+// handleClaim handles the /claim command.
+func (b *Bot) handleClaim(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	id := int(i.ApplicationCommandData().Options[0].IntValue())
+	if err := b.server.ClaimModcall(id, interactionUser(i)); err != nil {
+		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to claim modcall: %v", err)))
+		return
+	}
+	respondEmbed(s, i, successEmbed("Modcall Claimed", fmt.Sprintf("Modcall **#%d** claimed by %s.", id, interactionUser(i))))
+}
+
+// handleResolve handles the /resolve command.
+func (b *Bot) handleResolve(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	opts := i.ApplicationCommandData().Options
+	id := int(opts[0].IntValue())
+	note := optionString(opts, "note")
+	if err := b.server.ResolveModcall(id, interactionUser(i), note); err != nil {
+		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to resolve modcall: %v", err)))
+		return
+	}
+	respondEmbed(s, i, successEmbed("Modcall Resolved", fmt.Sprintf("Modcall **#%d** resolved by %s: %s", id, interactionUser(i), note)))
+}
+
+// handleClaimButton handles the Claim button on a /modcalls response.
+func (b *Bot) handleClaimButton(s Session, i *discordgo.InteractionCreate, arg string) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return
+	}
+	if err := b.server.ClaimModcall(id, interactionUser(i)); err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("Failed to claim modcall: %v", err)))
+		return
+	}
+	respondEmbed(s, i, successEmbed("Modcall Claimed", fmt.Sprintf("Modcall **#%d** claimed by %s.", id, interactionUser(i))))
+}
+
+// handleResolveButton handles the Resolve button on a /modcalls response by asking for a resolution note.
+func (b *Bot) handleResolveButton(s Session, i *discordgo.InteractionCreate, arg string) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: "modcall_resolve_modal:" + arg,
+			Title:    fmt.Sprintf("Resolve Modcall #%s", arg),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.TextInput{
+						CustomID:  "note",
+						Label:     "Resolution note",
+						Style:     discordgo.TextInputParagraph,
+						Required:  true,
+						MaxLength: 500,
+					},
+				}},
+			},
+		},
+	})
+}
+
+// handleResolveModal handles the resolution note submitted from the Resolve button.
+func (b *Bot) handleResolveModal(s Session, i *discordgo.InteractionCreate, arg string) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return
+	}
+	note := modalValue(i, "note")
+	if err := b.server.ResolveModcall(id, interactionUser(i), note); err != nil {
+		respondEmbedEphemeral(s, i, errorEmbed(fmt.Sprintf("Failed to resolve modcall: %v", err)))
+		return
+	}
+	respondEmbed(s, i, successEmbed("Modcall Resolved", fmt.Sprintf("Modcall **#%d** resolved by %s: %s", id, interactionUser(i), note)))
+}
@@ -54,8 +54,8 @@ func optionString(options []*discordgo.ApplicationCommandInteractionDataOption,
 }
 
 // handleMute handles the /mute command.
-func (b *Bot) handleMute(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleMute(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	opts := i.ApplicationCommandData().Options
@@ -78,7 +78,7 @@ func (b *Bot) handleMute(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		return
 	}
 
-	if err := b.server.MutePlayer(p.UID, dur, reason); err != nil {
+	if err := b.server.MutePlayer(p.UID, dur, reason, interactionUser(i)); err != nil {
 		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to mute player: %v", err)))
 		return
 	}
@@ -91,8 +91,8 @@ func (b *Bot) handleMute(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 // handleUnmute handles the /unmute command.
-func (b *Bot) handleUnmute(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleUnmute(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	playerArg := i.ApplicationCommandData().Options[0].StringValue()
@@ -109,8 +109,8 @@ func (b *Bot) handleUnmute(s *discordgo.Session, i *discordgo.InteractionCreate)
 }
 
 // handleBan handles the /ban command.
-func (b *Bot) handleBan(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleBan(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	opts := i.ApplicationCommandData().Options
@@ -138,6 +138,17 @@ func (b *Bot) handleBan(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		moderator = i.Member.User.Username
 	}
 
+	// As in game, permanent bans need BAN_PERMA or another moderator's approval.
+	if dur <= 0 && !b.memberHas(i, "BAN_PERMA") {
+		id, err := b.server.RequestPermaBan(interactionUserID(i), p.IPID, reason)
+		if err != nil {
+			respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to create pending ban: %v", err)))
+			return
+		}
+		respondEmbed(s, i, infoEmbed("⏳ Ban Pending Approval", fmt.Sprintf("You lack permission to permanently ban directly. Created pending action **%d** to ban **%s** [UID %d], which another moderator must approve in-game with /approve %d.\nReason: %s", id, p.Character, p.UID, id, reason)))
+		return
+	}
+
 	if err := b.server.BanPlayer(p.IPID, dur, reason, moderator); err != nil {
 		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to ban player: %v", err)))
 		return
@@ -151,8 +162,8 @@ func (b *Bot) handleBan(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 // handleUnban handles the /unban command.
-func (b *Bot) handleUnban(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleUnban(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	id := int(i.ApplicationCommandData().Options[0].IntValue())
@@ -164,8 +175,8 @@ func (b *Bot) handleUnban(s *discordgo.Session, i *discordgo.InteractionCreate)
 }
 
 // handleKick handles the /kick command.
-func (b *Bot) handleKick(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleKick(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	opts := i.ApplicationCommandData().Options
@@ -181,7 +192,7 @@ func (b *Bot) handleKick(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		return
 	}
 
-	if err := b.server.KickPlayer(p.UID, reason); err != nil {
+	if err := b.server.KickPlayer(p.UID, reason, interactionUser(i)); err != nil {
 		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to kick player: %v", err)))
 		return
 	}
@@ -189,8 +200,8 @@ func (b *Bot) handleKick(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 // handleGag handles the /gag command.
-func (b *Bot) handleGag(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleGag(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	playerArg := i.ApplicationCommandData().Options[0].StringValue()
@@ -207,8 +218,8 @@ func (b *Bot) handleGag(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 // handleUngag handles the /ungag command.
-func (b *Bot) handleUngag(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleUngag(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	playerArg := i.ApplicationCommandData().Options[0].StringValue()
@@ -225,8 +236,8 @@ func (b *Bot) handleUngag(s *discordgo.Session, i *discordgo.InteractionCreate)
 }
 
 // handleWarn handles the /warn command.
-func (b *Bot) handleWarn(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleWarn(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	opts := i.ApplicationCommandData().Options
@@ -252,8 +263,8 @@ func (b *Bot) handleWarn(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 // handleWarnings handles the /warnings command.
-func (b *Bot) handleWarnings(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleWarnings(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	playerArg := i.ApplicationCommandData().Options[0].StringValue()
@@ -282,8 +293,8 @@ func (b *Bot) handleWarnings(s *discordgo.Session, i *discordgo.InteractionCreat
 }
 
 // handleBanList handles the /banlist command.
-func (b *Bot) handleBanList(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleBanList(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	bans := b.server.GetBanList()
@@ -315,3 +326,75 @@ func (b *Bot) handleBanList(s *discordgo.Session, i *discordgo.InteractionCreate
 	}
 	respondEmbed(s, i, embed)
 }
+
+// handlePending handles the /pending command.
+func (b *Bot) handlePending(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	actions := b.server.GetPendingActions()
+	if len(actions) == 0 {
+		respondEmbed(s, i, infoEmbed("⏳ Pending Actions", "No actions are awaiting approval."))
+		return
+	}
+
+	var lines []string
+	for _, a := range actions {
+		lines = append(lines, fmt.Sprintf("**ID %d** — %s `%s` | Reason: %s | By: %s | Expires <t:%d:R>",
+			a.ID, a.Action, strings.Join(a.Targets, "`, `"), a.Reason, a.Moderator, a.Expires))
+	}
+
+	desc := strings.Join(lines, "\n")
+	if len(desc) > 4000 {
+		desc = desc[:4000] + "\n…(truncated)"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("⏳ Pending Actions (%d)", len(actions)),
+		Description: desc,
+		Color:       colorOrange,
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Approve in-game with /approve <id>"},
+	}
+	respondEmbed(s, i, embed)
+}
+
+// handleModStats handles the /modstats command.
+func (b *Bot) handleModStats(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
+		return
+	}
+	opts := i.ApplicationCommandData().Options
+	periodArg := optionString(opts, "period")
+	if periodArg == "" {
+		periodArg = "1w"
+	}
+	period, err := parseDuration(periodArg)
+	if err != nil || period <= 0 {
+		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Invalid period %q: use values like 1d, 2w, 30d.", periodArg)))
+		return
+	}
+	stats, err := b.server.GetModStats(optionString(opts, "moderator"), period)
+	if err != nil {
+		respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to get moderator activity: %v", err)))
+		return
+	}
+	if len(stats) == 0 {
+		respondEmbed(s, i, infoEmbed("📊 Moderator Activity", fmt.Sprintf("No moderator activity in the last %s.", periodArg)))
+		return
+	}
+
+	var lines []string
+	for _, m := range stats {
+		lines = append(lines, fmt.Sprintf("**%s** — %.1fh online | %d logins | %d bans | %d kicks | %d mutes | %d modcalls claimed | %d other actions | Last seen <t:%d:R>",
+			m.Moderator, m.Online.Hours(), m.Logins, m.Bans, m.Kicks, m.Mutes, m.Modcalls, m.Actions, m.LastSeen))
+	}
+	desc := strings.Join(lines, "\n")
+	if len(desc) > 4000 {
+		desc = desc[:4000] + "\n…(truncated)"
+	}
+	respondEmbed(s, i, &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("📊 Moderator Activity (last %s)", periodArg),
+		Description: desc,
+		Color:       colorBlue,
+	})
+}
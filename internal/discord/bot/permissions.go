@@ -16,31 +16,194 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>. */
 
 package bot
 
-import "github.com/bwmarrin/discordgo"
+import (
+	"sort"
+	"strings"
 
-// isModerator returns true if the invoking Discord member has the configured moderator role.
-// If no mod role ID is configured, all interactions are allowed (open access).
-func (b *Bot) isModerator(i *discordgo.InteractionCreate) bool {
-	if b.modRoleID == "" {
-		return true
+	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/bwmarrin/discordgo"
+)
+
+// gameCommands maps slash commands and component custom ID prefixes to the in-game commands they stand in for.
+// They require the same permissions as the in-game command, including any override from commands.toml.
+var gameCommands = map[string]string{
+	// Moderation
+	"mute":   "mute",
+	"unmute": "unmute",
+	"ban":    "ban",
+	"unban":  "unban",
+	"kick":   "kick",
+	// Custom punishments
+	"parrot":      "parrot",
+	"drunk":       "drunk",
+	"slowpoke":    "slowpoke",
+	"roulette":    "roulette",
+	"spotlight":   "spotlight",
+	"whisper":     "whisper",
+	"stutterstep": "stutterstep",
+	"backward":    "backward",
+	// Communication
+	"announce": "announce",
+	// Audit & Logs
+	"logs":     "log",
+	"auditlog": "audit",
+	"search":   "search",
+	"banlist":  "getban",
+	"pending":  "pending",
+	"modstats": "modstats",
+	// Modcall tickets
+	"modcalls":              "modcalls",
+	"claim":                 "claim",
+	"resolve":               "resolve",
+	"modcall_claim":         "claim",
+	"modcall_resolve":       "resolve",
+	"modcall_resolve_modal": "resolve",
+	"modcall_mute":          "mute",
+	"modcall_kick":          "kick",
+	"modcall_ban":           "ban",
+	"modcall_ban_modal":     "ban",
+	"modcall_log":           "log",
+}
+
+// commandPerms holds the permissions needed for slash commands with no in-game equivalent,
+// or whose in-game equivalent is scoped to the user's area, where the closest server-wide permission is used instead.
+var commandPerms = map[string]string{
+	// Player information
+	"players": "BAN_INFO",
+	"info":    "BAN_INFO",
+	"find":    "BAN_INFO",
+	"status":  "NONE",
+	// Moderation
+	"gag":      "MUTE",
+	"ungag":    "MUTE",
+	"warn":     "KICK",
+	"warnings": "BAN_INFO",
+	// Communication
+	"pm":              "MOD_SPEAK",
+	"announce_player": "ANNOUNCE",
+	"cmd":             "NONE", // Each in-game command checks its own permissions.
+	// Area control
+	"forcemove": "MOVE_USERS",
+	"cleararea": "MOVE_USERS",
+	"lock":      "MODIFY_AREA",
+	"unlock":    "MODIFY_AREA",
+}
+
+// requiredPerms returns the permissions needed for a slash command or component custom ID prefix,
+// and whether it is known. Unknown names require ADMIN.
+func (b *Bot) requiredPerms(name string) (uint64, bool) {
+	if cmd, ok := gameCommands[name]; ok {
+		if p, exists := b.server.CommandPerms(cmd); exists {
+			return p, true
+		}
+	}
+	if p, ok := commandPerms[name]; ok {
+		return permissions.PermissionField[p], true
+	}
+	return permissions.PermissionField["ADMIN"], false
+}
+
+// permissionNames returns the names of the permissions set in perms, separated by commas.
+func permissionNames(perms uint64) string {
+	if perms == permissions.PermissionField["ADMIN"] {
+		return "ADMIN"
 	}
+	var names []string
+	for name, bit := range permissions.PermissionField {
+		if bit != 0 && name != "ADMIN" && perms&bit == bit {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// interactionName returns the slash command name or component custom ID prefix of an interaction.
+func interactionName(i *discordgo.InteractionCreate) string {
+	var customID string
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		return i.ApplicationCommandData().Name
+	case discordgo.InteractionMessageComponent:
+		customID = i.MessageComponentData().CustomID
+	case discordgo.InteractionModalSubmit:
+		customID = i.ModalSubmitData().CustomID
+	}
+	prefix, _, _ := strings.Cut(customID, ":")
+	return prefix
+}
+
+// openAccess reports whether neither roles nor a moderator role are configured, so every member has every permission.
+func (b *Bot) openAccess() bool {
+	return len(b.roles) == 0 && b.modRoleID == ""
+}
+
+// memberPerms returns the in-game permissions granted by a member's Discord roles.
+// If no roles are mapped, the moderator role grants every permission, and if that is not set either,
+// every user does (open access). ok is false if the member has none of the mapped roles.
+func (b *Bot) memberPerms(memberRoles []string) (perms uint64, ok bool) {
+	if len(b.roles) == 0 {
+		if b.openAccess() {
+			return permissions.PermissionField["ADMIN"], true
+		}
+		for _, roleID := range memberRoles {
+			if roleID == b.modRoleID {
+				return permissions.PermissionField["ADMIN"], true
+			}
+		}
+		return 0, false
+	}
+	for _, roleID := range memberRoles {
+		name, mapped := b.roles[roleID]
+		if !mapped {
+			continue
+		}
+		if p, exists := b.server.GetRolePermissions(name); exists {
+			perms |= p
+			ok = true
+		}
+	}
+	return perms, ok
+}
+
+// hasPermission returns true if the invoking Discord member may run the command or use the component
+// that triggered the interaction. Members need one of the mapped roles, and the roles' permissions
+// must include those the command requires.
+func (b *Bot) hasPermission(i *discordgo.InteractionCreate) bool {
 	if i.Member == nil {
 		return false
 	}
-	for _, roleID := range i.Member.Roles {
-		if roleID == b.modRoleID {
-			return true
-		}
+	perms, ok := b.memberPerms(i.Member.Roles)
+	if !ok {
+		return false
 	}
-	return false
+	required, _ := b.requiredPerms(interactionName(i))
+	return permissions.HasPermission(perms, required)
 }
 
-// requireMod checks whether the invoking user is a moderator and sends an error response if not.
+// memberHas returns true if the invoking Discord member's roles grant the named permission.
+func (b *Bot) memberHas(i *discordgo.InteractionCreate, name string) bool {
+	if i.Member == nil {
+		return false
+	}
+	perms, ok := b.memberPerms(i.Member.Roles)
+	return ok && permissions.HasPermission(perms, permissions.PermissionField[name])
+}
+
+// requirePerms checks whether the invoking user may run the command and sends an error response if not.
 // Returns true if the user is authorized, false otherwise.
-func (b *Bot) requireMod(s *discordgo.Session, i *discordgo.InteractionCreate) bool {
-	if !b.isModerator(i) {
+func (b *Bot) requirePerms(s Session, i *discordgo.InteractionCreate) bool {
+	if !b.hasPermission(i) {
 		respondEmbedEphemeral(s, i, errorEmbed("You do not have permission to use this command."))
 		return false
 	}
 	return true
 }
+
+// interactionUser returns the name of the Discord user who triggered an interaction, used to attribute moderator actions.
+func interactionUser(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.Username
+	}
+	return "Discord"
+}
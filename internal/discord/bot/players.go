@@ -24,8 +24,8 @@ import (
 )
 
 // handlePlayers handles the /players command.
-func (b *Bot) handlePlayers(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handlePlayers(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	players := b.server.GetPlayers()
@@ -47,8 +47,8 @@ func (b *Bot) handlePlayers(s *discordgo.Session, i *discordgo.InteractionCreate
 }
 
 // handleInfo handles the /info command.
-func (b *Bot) handleInfo(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleInfo(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	playerArg := i.ApplicationCommandData().Options[0].StringValue()
@@ -69,12 +69,31 @@ func (b *Bot) handleInfo(s *discordgo.Session, i *discordgo.InteractionCreate) {
 			{Name: "IPID", Value: p.IPID, Inline: true},
 		},
 	}
+	if notes := b.server.GetNotes(p.IPID); len(notes) > 0 {
+		var lines []string
+		for _, n := range notes {
+			lines = append(lines, fmt.Sprintf("<t:%d:d> **%s**: %s", n.Time, n.Author, n.Text))
+		}
+		// Embed field values are limited to 1024 characters, so keep the most recent notes.
+		value := strings.Join(lines, "\n")
+		for len(value) > 1000 && len(lines) > 1 {
+			lines = lines[1:]
+			value = "…\n" + strings.Join(lines, "\n")
+		}
+		if len(value) > 1000 {
+			value = value[:1000] + "…"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("📝 Notes (%d)", len(notes)),
+			Value: value,
+		})
+	}
 	respondEmbed(s, i, embed)
 }
 
 // handleFind handles the /find command.
-func (b *Bot) handleFind(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleFind(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	playerArg := i.ApplicationCommandData().Options[0].StringValue()
@@ -90,8 +109,8 @@ func (b *Bot) handleFind(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 // handleStatus handles the /status command.
-func (b *Bot) handleStatus(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if !b.requireMod(s, i) {
+func (b *Bot) handleStatus(s Session, i *discordgo.InteractionCreate) {
+	if !b.requirePerms(s, i) {
 		return
 	}
 	areas := b.server.GetAreas()
@@ -23,9 +23,9 @@ import (
 )
 
 // handlePunishment returns a handler for applying a named punishment to a player.
-func (b *Bot) handlePunishment(name string) func(*discordgo.Session, *discordgo.InteractionCreate) {
-	return func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if !b.requireMod(s, i) {
+func (b *Bot) handlePunishment(name string) func(Session, *discordgo.InteractionCreate) {
+	return func(s Session, i *discordgo.InteractionCreate) {
+		if !b.requirePerms(s, i) {
 			return
 		}
 		opts := i.ApplicationCommandData().Options
@@ -44,7 +44,7 @@ func (b *Bot) handlePunishment(name string) func(*discordgo.Session, *discordgo.
 			return
 		}
 
-		if err := b.server.ApplyPunishment(p.UID, name, dur); err != nil {
+		if err := b.server.ApplyPunishment(p.UID, name, dur, interactionUser(i)); err != nil {
 			respondEmbed(s, i, errorEmbed(fmt.Sprintf("Failed to apply punishment: %v", err)))
 			return
 		}
@@ -34,6 +34,7 @@ type AreaInfo struct {
 	PlayerCount int
 	Status      string
 	Lock        string
+	CMs         []string
 }
 
 // BanRecord holds information about a ban entry.
@@ -54,6 +55,67 @@ type WarnRecord struct {
 	Time      int64
 }
 
+// NoteRecord holds information about a moderator note on a player.
+type NoteRecord struct {
+	ID     int
+	Author string
+	Text   string
+	Time   int64
+}
+
+// PendingActionRecord holds information about a moderator action awaiting approval.
+type PendingActionRecord struct {
+	ID        int
+	Action    string
+	Targets   []string
+	Reason    string
+	Moderator string
+	Created   int64
+	Expires   int64
+}
+
+// ModcallRecord holds information about a modcall ticket.
+type ModcallRecord struct {
+	ID        int
+	Time      int64
+	Area      string
+	CallerUID int
+	Caller    string
+	IPID      string
+	Reason    string
+	Status    string
+	ClaimedBy string
+}
+
+// ModStatsRecord summarises a moderator's activity over a period.
+type ModStatsRecord struct {
+	Moderator string
+	Online    time.Duration
+	Logins    int
+	Bans      int
+	Kicks     int
+	Mutes     int
+	Modcalls  int
+	Actions   int
+	LastSeen  int64
+}
+
+// AuditQuery filters audit log entries. Empty fields match every entry.
+type AuditQuery struct {
+	Filter    string
+	Moderator string
+	Target    string
+	Action    string
+	Period    time.Duration
+}
+
+// ChatSearchQuery filters a chat archive search. Empty fields match every message.
+type ChatSearchQuery struct {
+	Text   string
+	Area   string
+	Period time.Duration
+}
+
 // ServerInterface defines the operations the Discord bot can perform on the AO2 server.
 // This interface decouples the bot package from the athena package.
 type ServerInterface interface {
@@ -67,19 +129,30 @@ type ServerInterface interface {
 	FindArea(name string) *AreaInfo
 
 	// Moderation actions
-	MutePlayer(uid int, duration time.Duration, reason string) error
+	MutePlayer(uid int, duration time.Duration, reason string, moderator string) error
 	UnmutePlayer(uid int) error
-	KickPlayer(uid int, reason string) error
+	KickPlayer(uid int, reason string, moderator string) error
 	BanPlayer(ipid string, duration time.Duration, reason string, moderator string) error
+	// RequestPermaBan records a permanent ban as a pending action that another moderator must approve, returning its ID.
+	// The request is attributed to the moderator account linked to the Discord user, so they can't approve it themselves in game.
+	RequestPermaBan(discordID string, ipid string, reason string) (int, error)
 	GagPlayer(uid int) error
 	UngagPlayer(uid int) error
 	WarnPlayer(uid int, reason string, moderator string) error
 	GetWarnings(ipid string) []WarnRecord
+	GetNotes(ipid string) []NoteRecord
 	GetBanList() []BanRecord
 	UnbanByID(id int) error
+	GetPendingActions() []PendingActionRecord
+
+	// Modcall tickets
+	GetModcalls() []ModcallRecord
+	GetModcall(id int) *ModcallRecord
+	ClaimModcall(id int, moderator string) error
+	ResolveModcall(id int, moderator string, note string) error
 
 	// Punishment actions
-	ApplyPunishment(uid int, punishmentName string, duration time.Duration) error
+	ApplyPunishment(uid int, punishmentName string, duration time.Duration, moderator string) error
 	RemovePunishment(uid int, punishmentName string) error
 
 	// Communication
@@ -87,15 +160,40 @@ type ServerInterface interface {
 	SendAnnouncement(message string) error
 	SendAnnouncementToPlayer(uid int, message string) error
 
+	// Account linking
+	// LinkDiscord redeems a link code from the game for a Discord user, returning a description of what was linked.
+	LinkDiscord(code string, discordID string) (string, error)
+	// UnlinkDiscord removes every link of a Discord user, returning how many there were.
+	UnlinkDiscord(discordID string) (int, error)
+	// AnswerDiscordLogin approves or denies a login request sent by RequestLogin, returning the account name.
+	AnswerDiscordLogin(id int, discordID string, approve bool) (string, error)
+
+	// Chat bridge
+	// BridgeMessage shows a message from Discord in an area's OOC chat, or every area's if area is empty.
+	BridgeMessage(area string, author string, message string) error
+
+	// In-game commands
+	// RunCommand runs an in-game command line with the given permissions on behalf of a Discord user,
+	// returning the server messages it sent.
+	RunCommand(discordID string, name string, perms uint64, line string) []string
+
 	// Area control
 	ForceMove(uid int, areaName string) error
 	ClearArea(areaName string) error
-	LockArea(areaName string) error
-	UnlockArea(areaName string) error
+	LockArea(areaName string, moderator string) error
+	UnlockArea(areaName string, moderator string) error
 
 	// Audit & Logs
 	GetPlayerLogs(ipid string) []string
-	GetAuditLog(filter string) []string
+	GetAuditLog(q AuditQuery) []string
+	GetModStats(moderator string, period time.Duration) ([]ModStatsRecord, error)
+	SearchChat(q ChatSearchQuery) ([]string, error)
+
+	// Permissions
+	// GetRolePermissions returns the permissions of an in-game role, and whether the role exists.
+	GetRolePermissions(name string) (uint64, bool)
+	// CommandPerms returns the permissions required by an in-game command, and whether the command exists.
+	CommandPerms(name string) (uint64, bool)
 
 	// Server stats
 	GetServerName() string
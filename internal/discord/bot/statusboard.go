@@ -0,0 +1,142 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// statusDebounce is how long the status message waits after a change before it is edited,
+	// so a burst of changes, such as players joining an area together, becomes one edit.
+	statusDebounce = 10 * time.Second
+	statusTitle    = "📡 Server Status"
+	// maxStatusFields is the most fields an embed can have.
+	maxStatusFields = 25
+)
+
+// statusBoard keeps a single message in a channel up to date with the server's status.
+type statusBoard struct {
+	channelID string
+	messageID string
+	changed   chan struct{}
+	done      chan struct{} // Closed when the bot stops.
+}
+
+// newStatusBoard returns a status board for a channel, or nil if no channel is configured.
+func newStatusBoard(channelID string) *statusBoard {
+	if channelID == "" {
+		return nil
+	}
+	return &statusBoard{channelID: channelID, changed: make(chan struct{}, 1), done: make(chan struct{})}
+}
+
+// UpdateStatus marks the status message as out of date. It never blocks, and changes
+// within statusDebounce of each other are shown in one edit.
+func (b *Bot) UpdateStatus() {
+	if b.status == nil {
+		return
+	}
+	select {
+	case b.status.changed <- struct{}{}:
+	default:
+	}
+}
+
+// runStatus edits the status message after each change, waiting statusDebounce between edits, until the bot stops.
+func (b *Bot) runStatus() {
+	b.status.messageID = b.findStatusMessage()
+	b.postStatus()
+	for {
+		select {
+		case <-b.status.changed:
+		case <-b.status.done:
+			return
+		}
+		select {
+		case <-time.After(statusDebounce):
+		case <-b.status.done:
+			return
+		}
+		select {
+		case <-b.status.changed:
+		default:
+		}
+		b.postStatus()
+	}
+}
+
+// findStatusMessage returns the ID of the status message the bot posted before it was restarted, if it is still recent.
+func (b *Bot) findStatusMessage() string {
+	msgs, err := b.session.ChannelMessages(b.status.channelID, 50, "", "", "")
+	if err != nil {
+		return ""
+	}
+	for _, m := range msgs {
+		if m.Author != nil && m.Author.ID == b.userID && len(m.Embeds) > 0 &&
+			strings.HasPrefix(m.Embeds[0].Title, statusTitle) {
+			return m.ID
+		}
+	}
+	return ""
+}
+
+// postStatus edits the status message, or posts it again if it was deleted.
+func (b *Bot) postStatus() {
+	embed := b.statusEmbed()
+	if b.status.messageID != "" {
+		if _, err := b.session.ChannelMessageEditEmbed(b.status.channelID, b.status.messageID, embed); err == nil {
+			return
+		}
+	}
+	if m, err := b.session.ChannelMessageSendEmbed(b.status.channelID, embed); err == nil {
+		b.status.messageID = m.ID
+	}
+}
+
+// statusEmbed shows the player count and each area's players, status, lock and CMs.
+func (b *Bot) statusEmbed() *discordgo.MessageEmbed {
+	count := b.server.GetPlayerCount()
+	color := colorGray
+	if count > 0 {
+		color = colorGreen
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s — %s", statusTitle, b.server.GetServerName()),
+		Description: fmt.Sprintf("**Players:** %d / %d", count, b.server.GetMaxPlayers()),
+		Color:       color,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Last updated"},
+	}
+	areas := b.server.GetAreas()
+	for n, a := range areas {
+		if n == maxStatusFields {
+			embed.Description += fmt.Sprintf("\n…and %d more areas", len(areas)-n)
+			break
+		}
+		value := fmt.Sprintf("👥 %d · %s · %s", a.PlayerCount, a.Status, a.Lock)
+		if len(a.CMs) > 0 {
+			value += "\nCM: " + SanitizeForDiscord(strings.Join(a.CMs, ", "))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: a.Name, Value: value, Inline: true})
+	}
+	return embed
+}
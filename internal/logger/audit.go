@@ -0,0 +1,235 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuditFile is the name of the audit log within LogPath. Each line is a JSON-encoded AuditRecord.
+const AuditFile = "audit.jsonl"
+
+// legacyAuditFile is the plain-text audit log written by older versions. It is no longer written to, but is still searched.
+const legacyAuditFile = "audit.log"
+
+// AuditRecord is a single entry in the audit log.
+type AuditRecord struct {
+	Time      time.Time         `json:"time"`
+	Actor     string            `json:"actor"`
+	ActorIPID string            `json:"actor_ipid,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	Action    string            `json:"action"`
+	Area      string            `json:"area,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// String returns a single-line, human-readable form of the record.
+func (r AuditRecord) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v | %v | %v", r.Time.UTC().Format("2006-01-02 15:04:05"), r.Action, r.Actor)
+	if r.ActorIPID != "" {
+		fmt.Fprintf(&b, " (%v)", r.ActorIPID)
+	}
+	if r.Area != "" {
+		fmt.Fprintf(&b, " | %v", r.Area)
+	}
+	if r.Target != "" {
+		fmt.Fprintf(&b, " | Target: %v", r.Target)
+	}
+	if r.Reason != "" {
+		fmt.Fprintf(&b, " | Reason: %v", r.Reason)
+	}
+	if r.Message != "" {
+		fmt.Fprintf(&b, " | %v", r.Message)
+	}
+	if len(r.Metadata) > 0 {
+		keys := make([]string, 0, len(r.Metadata))
+		for k := range r.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, " | %v=%v", k, r.Metadata[k])
+		}
+	}
+	return b.String()
+}
+
+// AuditQuery filters audit records. Empty fields match every record.
+type AuditQuery struct {
+	Actor  string    // Matches the actor's name or IPID, case-insensitively.
+	Target string    // Matches records whose target contains this, case-insensitively.
+	Action string    // Matches the action, case-insensitively.
+	Text   string    // Matches records containing this anywhere, case-insensitively.
+	Since  time.Time // Matches records at or after this time.
+	Until  time.Time // Matches records before this time.
+	Limit  int       // Maximum number of records to return, keeping the most recent. 0 means no limit.
+}
+
+// Match reports whether a record matches the query.
+func (q AuditQuery) Match(r AuditRecord) bool {
+	if q.Actor != "" && !strings.EqualFold(r.Actor, q.Actor) && !strings.EqualFold(r.ActorIPID, q.Actor) {
+		return false
+	}
+	if q.Target != "" && !strings.Contains(strings.ToLower(r.Target), strings.ToLower(q.Target)) {
+		return false
+	}
+	if q.Action != "" && !strings.EqualFold(r.Action, q.Action) {
+		return false
+	}
+	if !q.Since.IsZero() && r.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !r.Time.Before(q.Until) {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(r.String()), strings.ToLower(q.Text)) {
+		return false
+	}
+	return true
+}
+
+// WriteAudit writes a record to the server's audit log.
+func WriteAudit(r AuditRecord) {
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		LogError(err.Error())
+		return
+	}
+	writeFile(filepath.Join(LogPath, AuditFile), 0755, true, asyncLogError, append(line, '\n'))
+}
+
+// QueryAudit returns the audit records matching a query, oldest first.
+// Rotated audit logs and the legacy plain-text audit log are searched as well as the current one.
+// Lines that cannot be parsed are skipped.
+func QueryAudit(q AuditQuery) ([]AuditRecord, error) {
+	flushFile(filepath.Join(LogPath, AuditFile))
+	var records []AuditRecord
+	paths := append([]string{filepath.Join(LogPath, legacyAuditFile)}, rotatedFiles(AuditFile)...)
+	for _, path := range append(paths, filepath.Join(LogPath, AuditFile)) {
+		if !q.Since.IsZero() {
+			// Every record in a file is at least as old as its last write.
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(q.Since) {
+				continue
+			}
+		}
+		var err error
+		records, err = scanAuditFile(path, q, records)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[len(records)-q.Limit:]
+	}
+	return records, nil
+}
+
+// scanAuditFile appends the records in an audit log file that match a query. Gzipped files are decompressed.
+func scanAuditFile(path string, q AuditQuery, records []AuditRecord) ([]AuditRecord, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && path != filepath.Join(LogPath, AuditFile) && !strings.HasSuffix(path, ".gz") {
+		// The file may have been compressed since it was listed.
+		path += ".gz"
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return records, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return records, fmt.Errorf("%v: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	legacy := filepath.Base(path) == legacyAuditFile
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec AuditRecord
+		if legacy {
+			var ok bool
+			if rec, ok = parseLegacyAudit(scanner.Text()); !ok {
+				continue
+			}
+		} else if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if !q.Match(rec) {
+			continue
+		}
+		records = append(records, rec)
+		if q.Limit > 0 && len(records) > 2*q.Limit {
+			records = append(records[:0], records[len(records)-q.Limit:]...)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// parseLegacyAudit reads a line of the legacy audit log. Lines are either area buffer entries,
+// "[2006/01/02] 15:04:05 | action | character | IPID | OOC name | message", or actions from Discord,
+// "[2006/01/02] 15:04:05 | action | IPID:ipid | reason | By: moderator".
+func parseLegacyAudit(line string) (AuditRecord, bool) {
+	date, rest, ok := strings.Cut(strings.TrimPrefix(line, "["), "] ")
+	if !ok || !strings.HasPrefix(line, "[") {
+		return AuditRecord{}, false
+	}
+	fields := strings.Split(rest, " | ")
+	t, err := time.Parse("2006/01/02 15:04:05", date+" "+fields[0])
+	if err != nil || len(fields) < 3 {
+		return AuditRecord{}, false
+	}
+	r := AuditRecord{Time: t, Action: strings.ToLower(fields[1])}
+	last := len(fields) - 1
+	switch {
+	case strings.HasPrefix(fields[2], "IPID:") && last >= 3 && strings.HasPrefix(fields[last], "By: "):
+		r.Target = strings.TrimPrefix(fields[2], "IPID:")
+		r.Reason = strings.Join(fields[3:last], " | ")
+		r.Actor = strings.TrimPrefix(fields[last], "By: ")
+		r.Metadata = map[string]string{"source": "discord"}
+	case len(fields) >= 6:
+		r.ActorIPID = fields[3]
+		r.Actor = fields[4]
+		r.Message = strings.Join(fields[5:], " | ")
+		r.Metadata = map[string]string{"character": fields[2]}
+	default:
+		r.Message = strings.Join(fields[2:], " | ")
+	}
+	return r, true
+}
@@ -0,0 +1,119 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueryAudit(t *testing.T) {
+	LogPath = t.TempDir()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	records := []AuditRecord{
+		{Time: base, Actor: "alice", ActorIPID: "ip1", Action: "login"},
+		{Time: base.Add(time.Minute), Actor: "alice", ActorIPID: "ip1", Action: "ban", Target: "bad1, bad2", Reason: "spam"},
+		{Time: base.Add(2 * time.Minute), Actor: "bob", ActorIPID: "ip2", Action: "kick", Target: "bad1", Reason: "rude"},
+		{Time: base.Add(3 * time.Minute), Actor: "bob", ActorIPID: "ip2", Action: "ban", Target: "bad3", Reason: "raid",
+			Metadata: map[string]string{"source": "discord"}},
+	}
+	for _, r := range records {
+		WriteAudit(r)
+	}
+
+	// Lines from before the log was structured are skipped.
+	Flush()
+	f, err := os.OpenFile(filepath.Join(LogPath, AuditFile), os.O_APPEND|os.O_WRONLY, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("[2024/01/01] 12:00:00 | CMD | Phoenix | ip1 | alice | old line\n")
+	f.Close()
+
+	tests := []struct {
+		name string
+		q    AuditQuery
+		want int
+	}{
+		{"all", AuditQuery{}, 4},
+		{"actor", AuditQuery{Actor: "Alice"}, 2},
+		{"actor ipid", AuditQuery{Actor: "ip2"}, 2},
+		{"target", AuditQuery{Target: "bad1"}, 2},
+		{"action", AuditQuery{Action: "BAN"}, 2},
+		{"since", AuditQuery{Since: base.Add(2 * time.Minute)}, 2},
+		{"until", AuditQuery{Until: base.Add(time.Minute)}, 1},
+		{"text", AuditQuery{Text: "discord"}, 1},
+		{"combined", AuditQuery{Actor: "bob", Action: "ban"}, 1},
+		{"limit", AuditQuery{Limit: 3}, 3},
+	}
+	for _, tt := range tests {
+		got, err := QueryAudit(tt.q)
+		if err != nil {
+			t.Fatalf("%v: QueryAudit() error: %v", tt.name, err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("%v: got %d records, want %d", tt.name, len(got), tt.want)
+		}
+	}
+
+	got, _ := QueryAudit(AuditQuery{Limit: 1})
+	if len(got) != 1 || got[0].Target != "bad3" || got[0].Metadata["source"] != "discord" {
+		t.Errorf("Limit should keep the most recent record, got %+v", got)
+	}
+}
+
+func TestQueryAuditMissingFile(t *testing.T) {
+	LogPath = t.TempDir()
+	got, err := QueryAudit(AuditQuery{})
+	if err != nil || len(got) != 0 {
+		t.Errorf("QueryAudit() on a missing log = %v, %v; want no records and no error", got, err)
+	}
+}
+
+func TestQueryLegacyAudit(t *testing.T) {
+	LogPath = t.TempDir()
+	legacy := "[2023/06/01] 10:00:00 | CMD | Phoenix | ip1 | alice | Banned bad1 from server for 1d: spam | again.\n" +
+		"[2023/06/02] 11:30:00 | BAN | IPID:bad2 | raiding | By: bob\n" +
+		"not an audit line\n"
+	if err := os.WriteFile(filepath.Join(LogPath, legacyAuditFile), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	WriteAudit(AuditRecord{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Actor: "alice", Action: "kick"})
+
+	got, err := QueryAudit(AuditQuery{})
+	if err != nil {
+		t.Fatalf("QueryAudit() error: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d records, want 3: %+v", len(got), got)
+	}
+	if r := got[0]; r.Actor != "alice" || r.ActorIPID != "ip1" || r.Action != "cmd" || r.Message != "Banned bad1 from server for 1d: spam | again." ||
+		!r.Time.Equal(time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("area buffer line parsed as %+v", r)
+	}
+	if r := got[1]; r.Actor != "bob" || r.Target != "bad2" || r.Reason != "raiding" || r.Action != "ban" {
+		t.Errorf("Discord line parsed as %+v", r)
+	}
+	if got[2].Action != "kick" {
+		t.Errorf("structured record should come last, got %+v", got[2])
+	}
+	if got, _ := QueryAudit(AuditQuery{Actor: "bob", Action: "ban"}); len(got) != 1 {
+		t.Errorf("legacy records should match queries, got %+v", got)
+	}
+}
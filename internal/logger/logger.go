@@ -46,28 +46,26 @@ var (
 		Fatal:   "FATAL",
 	}
 	LogPath           string
-	LogStdOut         bool
-	LogFile           bool
 	CurrentLevel      LogLevel
 	outputLock        sync.Mutex
 	fileLock          sync.Mutex
 	DebugNetwork      bool
 	EnableAreaLogging bool
-	areaLogLocks      sync.Map // Map of area names to their respective locks
 )
 
-// log writes a message to standard output and/or the log file if the level matches the server's set log level.
+// log sends a message to every sink whose level it meets.
 func log(level LogLevel, s string) {
-	if level < CurrentLevel {
-		return
-	}
-	if LogStdOut {
-		outputLock.Lock()
-		fmt.Printf("%v: %v: %v\n", time.Now().UTC().Format(time.StampMilli), levelToString[level], s)
-		outputLock.Unlock()
+	e := Entry{Time: time.Now().UTC(), Level: level, Message: s}
+	sinksMu.RLock()
+	for _, sink := range sinks {
+		if sink.accepts(level) {
+			sink.Write(e)
+		}
 	}
-	if LogFile {
-		WriteLog(fmt.Sprintf("%v: %v: %v\n", time.Now().UTC().Format(time.StampMilli), levelToString[level], s))
+	sinksMu.RUnlock()
+	if level == Fatal {
+		// The server is likely about to exit.
+		Flush()
 	}
 }
 
@@ -139,40 +137,15 @@ func WriteReport(name string, buffer []string) {
 	}
 }
 
-// WriteAudit writes a line to the server's audit log.
-func WriteAudit(s string) {
-	fileLock.Lock()
-	defer fileLock.Unlock()
-	f, err := os.OpenFile(LogPath+"/audit.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0755)
-	if err != nil {
-		LogError(err.Error())
-		return
-	}
-	defer f.Close()
-	_, err = f.WriteString(fmt.Sprintf("[%v] %v\n", time.Now().UTC().Format("2006/01/02"), s))
-	if err != nil {
-		LogError(err.Error())
-		return
-	}
+// WriteTranscript saves an area transcript to the log directory, returning the name of the file.
+func WriteTranscript(areaName string, ext string, contents []byte) (string, error) {
+	fname := fmt.Sprintf("transcript-%v-%v.%v", time.Now().UTC().Format("2006-01-02T150405Z"), sanitizeAreaName(areaName), ext)
+	return fname, os.WriteFile(filepath.Join(LogPath, fname), contents, 0644)
 }
 
 // WriteLog writes a line to the server's log file.
 func WriteLog(s string) {
-	fileLock.Lock()
-	defer fileLock.Unlock()
-	f, err := os.OpenFile(LogPath+"/server.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0755)
-	if err != nil {
-		LogFile = false //prevents infinite recursion if can't open log file
-		LogError(err.Error())
-		return
-	}
-	defer f.Close()
-	_, err = f.WriteString(s)
-	if err != nil {
-		LogFile = false
-		LogError(err.Error())
-		return
-	}
+	writeFile(filepath.Join(LogPath, "server.log"), 0755, true, stdoutError, []byte(s))
 }
 
 // sanitizeAreaName converts an area name to a safe folder name
@@ -206,12 +179,6 @@ func CreateAreaLogDirectory(areaName string) error {
 	return nil
 }
 
-// getAreaLock returns or creates a mutex for the specified area
-func getAreaLock(areaName string) *sync.Mutex {
-	lock, _ := areaLogLocks.LoadOrStore(areaName, &sync.Mutex{})
-	return lock.(*sync.Mutex)
-}
-
 // WriteAreaLog writes a log entry to an area's daily log file
 func WriteAreaLog(areaName, logEntry string) {
 	if !EnableAreaLogging {
@@ -219,26 +186,7 @@ func WriteAreaLog(areaName, logEntry string) {
 	}
 
 	safeAreaName := sanitizeAreaName(areaName)
-	lock := getAreaLock(safeAreaName)
-	lock.Lock()
-	defer lock.Unlock()
-
-	// Generate daily log file name
 	today := time.Now().Format("2006-01-02")
 	filename := filepath.Join(LogPath, safeAreaName, fmt.Sprintf("%s-%s.txt", safeAreaName, today))
-
-	// Open or create the file with append mode
-	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		LogErrorf("Failed to open area log file %s: %v", filename, err)
-		return
-	}
-	defer f.Close()
-
-	// Write the log entry with a newline
-	_, err = f.WriteString(logEntry + "\n")
-	if err != nil {
-		LogErrorf("Failed to write to area log file %s: %v", filename, err)
-		return
-	}
+	writeFile(filename, 0644, false, asyncLogError, []byte(logEntry+"\n"))
 }
@@ -0,0 +1,206 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotatedTimeFormat = "2006-01-02T150405Z"
+
+var (
+	MaxLogSize     int64         // Size in bytes at which server.log and the audit log are rotated. 0 disables size-based rotation.
+	RotateInterval time.Duration // Interval at which server.log and the audit log are rotated. 0 disables time-based rotation.
+	CompressLogs   bool          // Whether rotated logs and past area logs are compressed with gzip.
+	LogRetention   time.Duration // How long rotated logs and past area logs are kept. 0 keeps them forever.
+
+	compressLock sync.Mutex // Held while compressing or removing old logs.
+
+	// Rotated server and audit logs, e.g. server-2006-01-02T150405Z.log.gz.
+	rotatedLogRegex = regexp.MustCompile(`^(server|audit)-\d{4}-\d{2}-\d{2}T\d{6}Z(-\d+)?\.(log|jsonl)(\.gz)?$`)
+	// Daily area logs, e.g. Lobby-2006-01-02.txt.
+	areaLogRegex = regexp.MustCompile(`-\d{4}-\d{2}-\d{2}\.txt(\.gz)?$`)
+)
+
+// rotatedName returns the name a log file is rotated to. If that name is taken, a counter is added.
+func rotatedName(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	name := fmt.Sprintf("%v-%v", stem, now.UTC().Format(rotatedTimeFormat))
+	candidate := name + ext
+	for i := 1; fileExists(candidate) || fileExists(candidate+".gz"); i++ {
+		candidate = fmt.Sprintf("%v-%d%v", name, i, ext)
+	}
+	return candidate
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// needsRotation reports whether a log file of the given size and modification time should be rotated before writing n more bytes to it.
+func needsRotation(size int64, modTime time.Time, n int, now time.Time) bool {
+	if size == 0 {
+		return false
+	}
+	if MaxLogSize > 0 && size+int64(n) > MaxLogSize {
+		return true
+	}
+	if RotateInterval > 0 && !now.Truncate(RotateInterval).Equal(modTime.Truncate(RotateInterval)) {
+		return true
+	}
+	return false
+}
+
+// rotateFile moves a closed log file aside, compressing it in the background if enabled.
+func rotateFile(path string, now time.Time) {
+	rotated := rotatedName(path, now)
+	if err := os.Rename(path, rotated); err != nil {
+		go LogErrorf("Failed to rotate %v: %v", path, err)
+		return
+	}
+	if CompressLogs {
+		go func() {
+			compressLock.Lock()
+			err := compressFile(rotated)
+			compressLock.Unlock()
+			if err != nil {
+				LogErrorf("Failed to compress %v: %v", rotated, err)
+			}
+		}()
+	}
+}
+
+// compressFile gzips a file, replacing it with a .gz file. Files that no longer exist are skipped.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	gz.Name = filepath.Base(path)
+	gz.ModTime = info.ModTime()
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// Keep the modification time, which retention is based on.
+	os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+	in.Close()
+	return os.Remove(path)
+}
+
+// rotatedFiles returns the rotated versions of a log file, oldest first.
+func rotatedFiles(name string) []string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	matches, _ := filepath.Glob(filepath.Join(LogPath, stem+"-*"+ext+"*"))
+	var files []string
+	for _, m := range matches {
+		if rotatedLogRegex.MatchString(filepath.Base(m)) {
+			files = append(files, m)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// StartJanitor starts a goroutine that periodically compresses past area logs and deletes logs older than the retention period.
+func StartJanitor(interval time.Duration) {
+	go func() {
+		for {
+			cleanLogs(time.Now())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// cleanLogs applies the retention and compression policy to the log directory.
+// Only rotated server and audit logs and area logs from past days are affected.
+func cleanLogs(now time.Time) {
+	today := now.Format("2006-01-02")
+	// Area logs from past days are no longer written to.
+	closeWriters(func(path string) bool {
+		return areaLogRegex.MatchString(path) && !strings.HasSuffix(path, today+".txt")
+	})
+	filepath.Walk(LogPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		switch {
+		case filepath.Dir(path) == filepath.Clean(LogPath) && rotatedLogRegex.MatchString(name):
+		case filepath.Dir(path) != filepath.Clean(LogPath) && areaLogRegex.MatchString(name):
+			if strings.HasSuffix(name, today+".txt") {
+				return nil
+			}
+		default:
+			return nil
+		}
+
+		if LogRetention > 0 && now.Sub(info.ModTime()) > LogRetention {
+			compressLock.Lock()
+			err := os.Remove(path)
+			compressLock.Unlock()
+			if err != nil {
+				LogErrorf("Failed to remove old log %v: %v", path, err)
+			}
+			return nil
+		}
+		if CompressLogs && !strings.HasSuffix(name, ".gz") {
+			compressLock.Lock()
+			err := compressFile(path)
+			compressLock.Unlock()
+			if err != nil {
+				LogErrorf("Failed to compress %v: %v", path, err)
+			}
+		}
+		return nil
+	})
+}
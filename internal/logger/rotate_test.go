@@ -0,0 +1,149 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// resetRotation restores the rotation settings after a test.
+func resetRotation(t *testing.T) {
+	t.Cleanup(func() {
+		MaxLogSize, RotateInterval, CompressLogs, LogRetention = 0, 0, false, 0
+	})
+}
+
+func TestSizeRotation(t *testing.T) {
+	resetRotation(t)
+	LogPath = t.TempDir()
+	MaxLogSize = 100
+
+	WriteLog(string(make([]byte, 80)))
+	WriteLog(string(make([]byte, 80)))
+	Flush()
+	if files := rotatedFiles("server.log"); len(files) != 1 {
+		t.Fatalf("Expected 1 rotated log, got %v", files)
+	}
+	info, err := os.Stat(filepath.Join(LogPath, "server.log"))
+	if err != nil || info.Size() != 80 {
+		t.Errorf("Expected a new server.log with only the latest write")
+	}
+}
+
+func TestNeedsRotation(t *testing.T) {
+	resetRotation(t)
+	now := time.Date(2024, 1, 2, 0, 30, 0, 0, time.UTC)
+	modTime := now.Add(-time.Hour)
+
+	if needsRotation(5, modTime, 10, now) {
+		t.Errorf("Rotated with rotation disabled")
+	}
+	RotateInterval = 24 * time.Hour
+	if !needsRotation(5, modTime, 10, now) {
+		t.Errorf("Did not rotate a log last written on the previous day")
+	}
+	if needsRotation(5, modTime, 10, now.Add(-45*time.Minute)) {
+		t.Errorf("Rotated a log written earlier the same day")
+	}
+	if needsRotation(0, modTime, 10, now) {
+		t.Errorf("Rotated an empty log")
+	}
+}
+
+func TestCleanLogs(t *testing.T) {
+	resetRotation(t)
+	LogPath = t.TempDir()
+	CompressLogs = true
+	LogRetention = 7 * 24 * time.Hour
+	now := time.Now()
+
+	areaDir := filepath.Join(LogPath, "Lobby")
+	os.MkdirAll(areaDir, 0755)
+	write := func(path string, age time.Duration) {
+		os.WriteFile(path, []byte("entry\n"), 0644)
+		os.Chtimes(path, now.Add(-age), now.Add(-age))
+	}
+	old := filepath.Join(areaDir, "Lobby-2000-01-01.txt")
+	yesterday := filepath.Join(areaDir, "Lobby-"+now.Add(-24*time.Hour).Format("2006-01-02")+".txt")
+	today := filepath.Join(areaDir, "Lobby-"+now.Format("2006-01-02")+".txt")
+	oldAudit := filepath.Join(LogPath, "audit-2000-01-01T000000Z.jsonl.gz")
+	server := filepath.Join(LogPath, "server.log")
+	write(old, 30*24*time.Hour)
+	write(yesterday, 24*time.Hour)
+	write(today, 0)
+	write(oldAudit, 30*24*time.Hour)
+	write(server, 30*24*time.Hour)
+
+	cleanLogs(now)
+
+	if fileExists(old) || fileExists(oldAudit) {
+		t.Errorf("Logs past the retention period were not removed")
+	}
+	if fileExists(yesterday) || !fileExists(yesterday+".gz") {
+		t.Errorf("Yesterday's area log was not compressed")
+	}
+	if !fileExists(today) || !fileExists(server) {
+		t.Errorf("Logs in use were removed or compressed")
+	}
+}
+
+func TestQueryRotatedAudit(t *testing.T) {
+	resetRotation(t)
+	LogPath = t.TempDir()
+	WriteAudit(AuditRecord{Actor: "alice", Action: "ban"})
+	Shutdown()
+	rotated := rotatedName(filepath.Join(LogPath, AuditFile), time.Now())
+	if err := os.Rename(filepath.Join(LogPath, AuditFile), rotated); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressFile(rotated); err != nil {
+		t.Fatal(err)
+	}
+	WriteAudit(AuditRecord{Actor: "bob", Action: "ban"})
+
+	got, err := QueryAudit(AuditQuery{Action: "ban"})
+	if err != nil {
+		t.Fatalf("QueryAudit() error: %v", err)
+	}
+	if len(got) != 2 || got[0].Actor != "alice" || got[1].Actor != "bob" {
+		t.Errorf("Expected records from the rotated and current logs in order, got %+v", got)
+	}
+}
+
+func TestWriterRotation(t *testing.T) {
+	resetRotation(t)
+	LogPath = t.TempDir()
+	MaxLogSize = 50
+	CompressLogs = true
+
+	for i := 0; i < 5; i++ {
+		WriteAudit(AuditRecord{Actor: "alice", Action: "login"})
+	}
+	got, err := QueryAudit(AuditQuery{})
+	if err != nil {
+		t.Fatalf("QueryAudit() error: %v", err)
+	}
+	if len(got) != 5 {
+		t.Errorf("Expected all 5 records across rotated logs, got %d", len(got))
+	}
+	if files := rotatedFiles(AuditFile); len(files) != 4 {
+		t.Errorf("Expected 4 rotated logs, got %v", files)
+	}
+}
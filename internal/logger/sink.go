@@ -0,0 +1,221 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Entry is a single server log message.
+type Entry struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+}
+
+// MarshalJSON encodes the entry as {"time": ..., "level": ..., "msg": ...}.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Time    time.Time `json:"time"`
+		Level   string    `json:"level"`
+		Message string    `json:"msg"`
+	}{e.Time, levelToString[e.Level], e.Message})
+}
+
+// text returns the entry in the server's plain text log format.
+func (e Entry) text() string {
+	return fmt.Sprintf("%v: %v: %v\n", e.Time.Format(time.StampMilli), levelToString[e.Level], e.Message)
+}
+
+// Sink is a destination for server log messages.
+// Write is called for every entry at or above the sink's level, and must not block for long.
+type Sink interface {
+	Write(e Entry)
+	Close() error
+}
+
+// SinkConfig holds the settings used to create sinks.
+type SinkConfig struct {
+	HTTPURL       string        // Collector URL for the http sink.
+	HTTPBatchSize int           // Entries sent per request by the http sink.
+	HTTPInterval  time.Duration // Longest time the http sink holds entries before sending them.
+	SyslogNetwork string        // Network of the syslog server, e.g. "udp". Empty uses the local syslog socket.
+	SyslogAddress string        // Address of the syslog server.
+}
+
+// levelSink is a configured sink with its minimum level.
+type levelSink struct {
+	Sink
+	level    LogLevel
+	useLevel bool // If false, the sink follows CurrentLevel.
+}
+
+func (s levelSink) accepts(level LogLevel) bool {
+	if s.useLevel {
+		return level >= s.level
+	}
+	return level >= CurrentLevel
+}
+
+var (
+	sinksMu sync.RWMutex
+	// Until sinks are configured, messages are printed to stdout.
+	sinks = []levelSink{{Sink: stdoutSink{}}}
+)
+
+// ParseLevel parses a level name, as used in log_level.
+func ParseLevel(s string) (LogLevel, error) {
+	for level, name := range levelToString {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	if strings.EqualFold(s, "warning") {
+		return Warning, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// NewSink creates a sink by name.
+func NewSink(name string, conf SinkConfig) (Sink, error) {
+	switch name {
+	case "stdout":
+		return stdoutSink{}, nil
+	case "log_file":
+		return &fileSink{}, nil
+	case "json":
+		return jsonSink{}, nil
+	case "syslog":
+		return newSyslogSink(conf.SyslogNetwork, conf.SyslogAddress)
+	case "http":
+		if conf.HTTPURL == "" {
+			return nil, fmt.Errorf("the http log method requires log_http_url")
+		}
+		return newHTTPSink(conf.HTTPURL, conf.HTTPBatchSize, conf.HTTPInterval), nil
+	default:
+		return nil, fmt.Errorf("unknown log method %q", name)
+	}
+}
+
+// ConfigureSinks replaces the server's sinks with those named in methods.
+// Each method is a sink name, optionally followed by a minimum level, e.g. "syslog:warning".
+// Sinks without a level follow CurrentLevel.
+func ConfigureSinks(methods []string, conf SinkConfig) error {
+	var configured []levelSink
+	for _, m := range methods {
+		name, levelName, hasLevel := strings.Cut(m, ":")
+		ls := levelSink{useLevel: hasLevel}
+		if hasLevel {
+			level, err := ParseLevel(levelName)
+			if err != nil {
+				closeSinkList(configured)
+				return fmt.Errorf("log method %q: %w", m, err)
+			}
+			ls.level = level
+		}
+		s, err := NewSink(name, conf)
+		if err != nil {
+			closeSinkList(configured)
+			return err
+		}
+		ls.Sink = s
+		configured = append(configured, ls)
+	}
+	sinksMu.Lock()
+	old := sinks
+	sinks = configured
+	sinksMu.Unlock()
+	closeSinkList(old)
+	return nil
+}
+
+// AddSink adds a sink that receives entries at or above level.
+func AddSink(s Sink, level LogLevel) {
+	sinksMu.Lock()
+	sinks = append(sinks, levelSink{Sink: s, level: level, useLevel: true})
+	sinksMu.Unlock()
+}
+
+// closeSinks removes and closes every sink.
+func closeSinks() {
+	sinksMu.Lock()
+	old := sinks
+	sinks = nil
+	sinksMu.Unlock()
+	closeSinkList(old)
+}
+
+func closeSinkList(list []levelSink) {
+	for _, s := range list {
+		if err := s.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close log sink: %v\n", err)
+		}
+	}
+}
+
+// stdoutSink prints entries to standard output as plain text.
+type stdoutSink struct{}
+
+func (stdoutSink) Write(e Entry) {
+	outputLock.Lock()
+	fmt.Print(e.text())
+	outputLock.Unlock()
+}
+
+func (stdoutSink) Close() error { return nil }
+
+// jsonSink prints entries to standard output as one JSON object per line, for collection by journald, Docker and the like.
+type jsonSink struct{}
+
+func (jsonSink) Write(e Entry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	outputLock.Lock()
+	os.Stdout.Write(append(b, '\n'))
+	outputLock.Unlock()
+}
+
+func (jsonSink) Close() error { return nil }
+
+// fileSink writes entries to server.log in LogPath. It disables itself if the file cannot be written.
+type fileSink struct {
+	failed atomic.Bool
+}
+
+func (s *fileSink) Write(e Entry) {
+	if s.failed.Load() {
+		return
+	}
+	writeFile(filepath.Join(LogPath, "server.log"), 0755, true, s.fail, []byte(e.text()))
+}
+
+// fail reports an error writing server.log. It cannot be logged normally, as that would write to server.log again.
+func (s *fileSink) fail(err error) {
+	s.failed.Store(true)
+	stdoutError(err)
+}
+
+func (s *fileSink) Close() error { return nil }
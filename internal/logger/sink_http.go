@@ -0,0 +1,123 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const httpSinkQueueSize = 4096
+
+// httpSink sends entries to a collector as JSON arrays in batched POST requests.
+// Entries are dropped, rather than blocking the server, if the collector falls too far behind.
+type httpSink struct {
+	url       string
+	client    *http.Client
+	batchSize int
+	interval  time.Duration
+	queue     chan Entry
+	done      chan struct{}
+	dropped   atomic.Int64
+}
+
+func newHTTPSink(url string, batchSize int, interval time.Duration) *httpSink {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	s := &httpSink{
+		url:       url,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		batchSize: batchSize,
+		interval:  interval,
+		queue:     make(chan Entry, httpSinkQueueSize),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *httpSink) Write(e Entry) {
+	select {
+	case s.queue <- e:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Close sends any queued entries and stops the sink.
+func (s *httpSink) Close() error {
+	close(s.queue)
+	<-s.done
+	return nil
+}
+
+func (s *httpSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	batch := make([]Entry, 0, s.batchSize)
+	for {
+		select {
+		case e, ok := <-s.queue:
+			if !ok {
+				s.send(batch)
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= s.batchSize {
+				s.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			s.send(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// send posts a batch to the collector. Failed batches are dropped; errors go to stderr, as logging them could loop back here.
+func (s *httpSink) send(batch []Entry) {
+	if n := s.dropped.Swap(0); n > 0 {
+		batch = append(batch, Entry{Time: time.Now().UTC(), Level: Warning, Message: fmt.Sprintf("Dropped %v log entries: the collector is not keeping up.", n)})
+	}
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode log batch: %v\n", err)
+		return
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to send %v log entries to %v: %v\n", len(batch), s.url, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		fmt.Fprintf(os.Stderr, "failed to send %v log entries to %v: %v\n", len(batch), s.url, resp.Status)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build !windows && !plan9
+
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package logger
+
+import (
+	"log/syslog"
+)
+
+// syslogSink sends entries to syslog, mapping log levels to syslog severities.
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// newSyslogSink connects to syslog. An empty network connects to the local syslog socket.
+func newSyslogSink(network string, address string) (Sink, error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, "athena")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Write(e Entry) {
+	switch e.Level {
+	case Debug:
+		s.w.Debug(e.Message)
+	case Info:
+		s.w.Info(e.Message)
+	case Warning:
+		s.w.Warning(e.Message)
+	case Error:
+		s.w.Err(e.Message)
+	case Fatal:
+		s.w.Crit(e.Message)
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
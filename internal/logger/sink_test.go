@@ -0,0 +1,119 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSink keeps the messages written to it.
+type recordSink struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (s *recordSink) Write(e Entry) {
+	s.mu.Lock()
+	s.messages = append(s.messages, e.Message)
+	s.mu.Unlock()
+}
+
+func (s *recordSink) Close() error { return nil }
+
+// useSinks replaces the configured sinks for the duration of a test.
+func useSinks(t *testing.T, list ...levelSink) {
+	sinksMu.Lock()
+	old, oldLevel := sinks, CurrentLevel
+	sinks = list
+	sinksMu.Unlock()
+	t.Cleanup(func() {
+		sinksMu.Lock()
+		sinks, CurrentLevel = old, oldLevel
+		sinksMu.Unlock()
+	})
+}
+
+func TestSinkLevels(t *testing.T) {
+	all, warn, current := &recordSink{}, &recordSink{}, &recordSink{}
+	useSinks(t, levelSink{Sink: all, level: Debug, useLevel: true}, levelSink{Sink: warn, level: Warning, useLevel: true}, levelSink{Sink: current})
+	CurrentLevel = Info
+
+	LogDebug("debug")
+	LogInfo("info")
+	LogWarning("warning")
+
+	if len(all.messages) != 3 {
+		t.Errorf("Debug sink got %v, want all 3 messages", all.messages)
+	}
+	if len(warn.messages) != 1 || warn.messages[0] != "warning" {
+		t.Errorf("Warning sink got %v, want only the warning", warn.messages)
+	}
+	if len(current.messages) != 2 {
+		t.Errorf("Sink following CurrentLevel got %v, want info and warning", current.messages)
+	}
+}
+
+func TestConfigureSinks(t *testing.T) {
+	useSinks(t)
+	if err := ConfigureSinks([]string{"stdout", "json:warning", "log_file:error"}, SinkConfig{}); err != nil {
+		t.Fatalf("ConfigureSinks() error: %v", err)
+	}
+	if len(sinks) != 3 || sinks[0].useLevel || sinks[1].level != Warning || sinks[2].level != Error {
+		t.Errorf("Unexpected sinks: %+v", sinks)
+	}
+
+	for _, methods := range [][]string{{"carrier_pigeon"}, {"stdout:loud"}, {"http"}} {
+		if err := ConfigureSinks(methods, SinkConfig{}); err == nil {
+			t.Errorf("ConfigureSinks(%v) should fail", methods)
+		}
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("Failed to decode batch: %v", err)
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	s := newHTTPSink(srv.URL, 2, time.Hour)
+	for _, msg := range []string{"one", "two", "three"} {
+		s.Write(Entry{Time: time.Now(), Level: Info, Message: msg})
+	}
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("Expected batches of 2 and 1 entries, got %v", batches)
+	}
+	if e := batches[0][0]; e["msg"] != "one" || e["level"] != "INFO" || e["time"] == "" {
+		t.Errorf("Unexpected entry encoding: %v", e)
+	}
+}
@@ -0,0 +1,241 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	writerQueueSize     = 1024        // Lines that can be queued for a file before writers block.
+	writerFlushInterval = time.Second // Longest time a line is buffered while a file is busy.
+)
+
+// logMsg is a request to a fileWriter. Data is appended to the file; if done is set,
+// the writer flushes (and, if close is set, closes the file and stops) and then closes done.
+type logMsg struct {
+	data  []byte
+	done  chan struct{}
+	close bool
+}
+
+// fileWriter owns a single log file, writing lines it receives on its queue from its own goroutine.
+// Lines are buffered, and flushed whenever the queue empties or writerFlushInterval passes.
+type fileWriter struct {
+	path    string
+	perm    os.FileMode
+	rotate  bool // Whether the file is subject to size and time-based rotation.
+	onError func(error)
+	queue   chan logMsg
+
+	file    *os.File
+	buf     *bufio.Writer
+	size    int64
+	modTime time.Time
+}
+
+var (
+	// writersMu is held for reading while queueing lines, and for writing while adding or removing writers,
+	// so a writer is never removed while a line is being queued for it.
+	writersMu sync.RWMutex
+	writers   = make(map[string]*fileWriter)
+)
+
+// writeFile queues data to be appended to the file at path, starting a writer for the file if needed.
+// It blocks only if the file's queue is full.
+func writeFile(path string, perm os.FileMode, rotate bool, onError func(error), data []byte) {
+	for {
+		writersMu.RLock()
+		if w, ok := writers[path]; ok {
+			w.queue <- logMsg{data: data}
+			writersMu.RUnlock()
+			return
+		}
+		writersMu.RUnlock()
+
+		writersMu.Lock()
+		if _, ok := writers[path]; !ok {
+			w := &fileWriter{path: path, perm: perm, rotate: rotate, onError: onError, queue: make(chan logMsg, writerQueueSize)}
+			writers[path] = w
+			go w.run()
+		}
+		writersMu.Unlock()
+	}
+}
+
+// run processes the writer's queue until it is asked to close.
+func (w *fileWriter) run() {
+	ticker := time.NewTicker(writerFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-w.queue:
+			if msg.data != nil {
+				w.write(msg.data)
+			}
+			if msg.done != nil {
+				w.flush()
+				if msg.close {
+					w.closeFile()
+					close(msg.done)
+					return
+				}
+				close(msg.done)
+			} else if len(w.queue) == 0 {
+				w.flush()
+			}
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+// write appends data to the file, rotating it first if needed.
+func (w *fileWriter) write(data []byte) {
+	now := time.Now()
+	if w.file == nil {
+		if !w.open() {
+			return
+		}
+	}
+	if w.rotate && needsRotation(w.size, w.modTime, len(data), now) {
+		w.closeFile()
+		rotateFile(w.path, now)
+		if !w.open() {
+			return
+		}
+	}
+	if _, err := w.buf.Write(data); err != nil {
+		w.onError(err)
+		return
+	}
+	w.size += int64(len(data))
+	w.modTime = now
+}
+
+// open opens the writer's file for appending.
+func (w *fileWriter) open() bool {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.perm)
+	if err != nil {
+		w.onError(err)
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		w.onError(err)
+		return false
+	}
+	w.file, w.buf = f, bufio.NewWriter(f)
+	w.size, w.modTime = info.Size(), info.ModTime()
+	return true
+}
+
+// flush writes any buffered data to the file.
+func (w *fileWriter) flush() {
+	if w.buf == nil || w.buf.Buffered() == 0 {
+		return
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.onError(err)
+	}
+}
+
+// closeFile flushes and closes the writer's file. It is reopened on the next write.
+func (w *fileWriter) closeFile() {
+	if w.file == nil {
+		return
+	}
+	w.flush()
+	if err := w.file.Close(); err != nil {
+		w.onError(err)
+	}
+	w.file, w.buf = nil, nil
+}
+
+// request sends a flush or close request to a writer and waits for it to be handled.
+func (w *fileWriter) request(close bool) {
+	done := make(chan struct{})
+	w.queue <- logMsg{done: done, close: close}
+	<-done
+}
+
+// flushFile waits for every line queued for the file at path to be written.
+func flushFile(path string) {
+	writersMu.RLock()
+	w, ok := writers[path]
+	if ok {
+		done := make(chan struct{})
+		w.queue <- logMsg{done: done}
+		writersMu.RUnlock()
+		<-done
+		return
+	}
+	writersMu.RUnlock()
+}
+
+// closeWriters stops the writers for which remove returns true, after writing everything queued for them.
+func closeWriters(remove func(path string) bool) {
+	writersMu.Lock()
+	var closing []*fileWriter
+	for path, w := range writers {
+		if remove(path) {
+			closing = append(closing, w)
+			delete(writers, path)
+		}
+	}
+	writersMu.Unlock()
+	for _, w := range closing {
+		w.request(true)
+	}
+}
+
+// Flush waits for every queued log line to be written to disk.
+func Flush() {
+	writersMu.RLock()
+	paths := make([]string, 0, len(writers))
+	for path := range writers {
+		paths = append(paths, path)
+	}
+	writersMu.RUnlock()
+	for _, path := range paths {
+		flushFile(path)
+	}
+}
+
+// Shutdown closes every sink, then writes every queued log line and closes all log files.
+// It should be called before the server exits.
+func Shutdown() {
+	closeSinks()
+	closeWriters(func(string) bool { return true })
+}
+
+// asyncLogError reports an error from a writer without blocking it.
+func asyncLogError(err error) {
+	go LogError(err.Error())
+}
+
+// stdoutError reports an error from the server log's own writer, which cannot log to itself.
+func stdoutError(err error) {
+	outputLock.Lock()
+	fmt.Printf("%v: %v: failed to write server log: %v\n", time.Now().UTC().Format(time.StampMilli), levelToString[Error], err)
+	outputLock.Unlock()
+}
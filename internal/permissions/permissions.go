@@ -39,6 +39,12 @@ var PermissionField = map[string]uint64{
 	"MOD_CHAT":    1 << 9,
 	"MUTE":        1 << 10,
 	"LOG":         1 << 11,
+	"BAN_PERMA":   1 << 12,
+	"PUNISH":      1 << 13,
+	"POSSESS":     1 << 14,
+	"EVENTS":      1 << 15,
+	"ANNOUNCE":    1 << 16,
+	"FORCE_PAIR":  1 << 17,
 	"ADMIN":       math.MaxUint64,
 }
 
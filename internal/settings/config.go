@@ -19,13 +19,16 @@ package settings
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/MangosArentLiterature/Athena/internal/area"
 	"github.com/MangosArentLiterature/Athena/internal/permissions"
+	"github.com/MangosArentLiterature/Athena/internal/wordfilter"
 )
 
 // Stores the path to the config directory
@@ -36,6 +39,8 @@ type Config struct {
 	LogConfig     `toml:"Logging"`
 	MSConfig      `toml:"MasterServer"`
 	DiscordConfig `toml:"Discord"`
+	AntiSpamConfig `toml:"AntiSpam"`
+	WebhooksConfig `toml:"Webhooks"`
 }
 
 type ServerConfig struct {
@@ -67,6 +72,13 @@ type ServerConfig struct {
 	RateLimit             int    `toml:"message_rate_limit"`
 	RateLimitWindow       int    `toml:"message_rate_limit_window"`
 	ModcallCooldown       int    `toml:"modcall_cooldown"`
+	FilterMuteDuration    int    `toml:"filter_mute_duration"`
+	ConnRateLimit         int    `toml:"conn_rate_limit"`
+	ConnRateLimitWindow   int    `toml:"conn_rate_limit_window"`
+	MaxPendingConns       int    `toml:"max_pending_connections"`
+	HandshakeTimeout      int    `toml:"handshake_timeout"`
+	MaxPacketSize         int    `toml:"max_packet_size"`
+	PendingExpiry         string `toml:"pending_action_expiry"`
 }
 
 type LogConfig struct {
@@ -75,6 +87,18 @@ type LogConfig struct {
 	LogDir           string   `toml:"log_directory"`
 	LogMethods       []string `toml:"log_methods"`
 	EnableAreaLogging bool    `toml:"enable_area_logging"`
+	LogMaxSize        int      `toml:"log_max_size"`
+	LogRotateInterval string   `toml:"log_rotate_interval"`
+	LogCompress       bool     `toml:"log_compress"`
+	LogRetentionDays  int      `toml:"log_retention_days"`
+	LogHTTPURL        string   `toml:"log_http_url"`
+	LogHTTPBatchSize  int      `toml:"log_http_batch_size"`
+	LogHTTPInterval   int      `toml:"log_http_interval"`
+	LogSyslogNetwork  string   `toml:"log_syslog_network"`
+	LogSyslogAddress  string   `toml:"log_syslog_address"`
+	EnableChatArchive bool     `toml:"enable_chat_archive"`
+	ChatArchiveDays   int      `toml:"chat_archive_retention_days"`
+	TranscriptLength  int      `toml:"transcript_length"`
 }
 
 type MSConfig struct {
@@ -83,9 +107,46 @@ type MSConfig struct {
 }
 
 type DiscordConfig struct {
-	BotToken  string `toml:"bot_token"`
-	GuildID   string `toml:"guild_id"`
-	ModRoleID string `toml:"mod_role_id"`
+	BotToken       string            `toml:"bot_token"`
+	GuildID        string            `toml:"guild_id"`
+	ModRoleID      string            `toml:"mod_role_id"`
+	ModcallChannel string            `toml:"modcall_channel_id"`
+	StatusChannel  string            `toml:"status_channel_id"`
+	EventChannel   string            `toml:"event_channel_id"`
+	EventFeed      []string          `toml:"event_feed"`
+	BridgeCooldown int               `toml:"bridge_cooldown"`
+	Bridge         map[string]string `toml:"bridge"`
+	DiscordRoles   map[string]string `toml:"roles"`
+}
+
+type AntiSpamConfig struct {
+	SpamEnabled            bool    `toml:"enabled"`
+	SpamFloodLimit         int     `toml:"ipid_flood_limit"`
+	SpamFloodWindow        int     `toml:"ipid_flood_window"`
+	SpamDuplicateLimit     int     `toml:"duplicate_limit"`
+	SpamDuplicateWindow    int     `toml:"duplicate_window"`
+	SpamDuplicateMinLength int     `toml:"duplicate_min_length"`
+	SpamCapsRatio          float64 `toml:"caps_ratio"`
+	SpamCapsMinLength      int     `toml:"caps_min_length"`
+	SpamMaxCombining       int     `toml:"max_combining_marks"`
+	SpamMaxNewlines        int     `toml:"max_newlines"`
+	SpamMuteStrikes        int     `toml:"mute_strikes"`
+	SpamKickStrikes        int     `toml:"kick_strikes"`
+	SpamMuteDuration       int     `toml:"mute_duration"`
+	SpamStrikeDecay        int     `toml:"strike_decay"`
+}
+
+type WebhooksConfig struct {
+	WebhookAttempts int             `toml:"max_attempts"`
+	WebhookBackoff  int             `toml:"retry_backoff"`
+	WebhookTargets  []WebhookTarget `toml:"target"`
+}
+
+// WebhookTarget is a URL that server events are posted to.
+type WebhookTarget struct {
+	URL    string   `toml:"url"`
+	Secret string   `toml:"secret"`
+	Events []string `toml:"events"`
 }
 
 // Returns a default configuration.
@@ -116,6 +177,13 @@ func defaultConfig() *Config {
 			RateLimit:             20,
 			RateLimitWindow:       10,
 			ModcallCooldown:       0,
+			FilterMuteDuration:    300,
+			ConnRateLimit:         5,
+			ConnRateLimitWindow:   10,
+			MaxPendingConns:       50,
+			HandshakeTimeout:      15,
+			MaxPacketSize:         16384,
+			PendingExpiry:         "1d",
 		},
 		LogConfig{
 			BufSize:           150,
@@ -123,15 +191,48 @@ func defaultConfig() *Config {
 			LogDir:            "logs",
 			LogMethods:        []string{"stdout"},
 			EnableAreaLogging: false,
+			LogMaxSize:        10,
+			LogRotateInterval: "1d",
+			LogCompress:       true,
+			LogRetentionDays:  0,
+			LogHTTPURL:        "",
+			LogHTTPBatchSize:  100,
+			LogHTTPInterval:   5,
+			LogSyslogNetwork:  "",
+			LogSyslogAddress:  "",
+			EnableChatArchive: false,
+			ChatArchiveDays:   0,
+			TranscriptLength:  500,
 		},
 		MSConfig{
 			Advertise: false,
 			MSAddr:    "https://servers.aceattorneyonline.com/servers",
 		},
 		DiscordConfig{
-			BotToken:  "",
-			GuildID:   "",
-			ModRoleID: "",
+			BotToken:       "",
+			GuildID:        "",
+			ModRoleID:      "",
+			BridgeCooldown: 3,
+		},
+		AntiSpamConfig{
+			SpamEnabled:            true,
+			SpamFloodLimit:         10,
+			SpamFloodWindow:        5,
+			SpamDuplicateLimit:     3,
+			SpamDuplicateWindow:    30,
+			SpamDuplicateMinLength: 8,
+			SpamCapsRatio:          0.8,
+			SpamCapsMinLength:      12,
+			SpamMaxCombining:       10,
+			SpamMaxNewlines:        5,
+			SpamMuteStrikes:        2,
+			SpamKickStrikes:        4,
+			SpamMuteDuration:       120,
+			SpamStrikeDecay:        600,
+		},
+		WebhooksConfig{
+			WebhookAttempts: 5,
+			WebhookBackoff:  2,
 		},
 	}
 }
@@ -222,3 +323,73 @@ func LoadRoles() ([]permissions.Role, error) {
 	}
 	return conf.Role, nil
 }
+
+// LoadCommandPerms reads the server's command permission file, returning a map of command names to the permissions they require.
+// The file is optional; if it does not exist, no overrides are returned.
+func LoadCommandPerms() (map[string][]string, error) {
+	var conf struct {
+		Permissions map[string][]string `toml:"permissions"`
+	}
+	_, err := toml.DecodeFile(ConfigPath+"/commands.toml", &conf)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return conf.Permissions, nil
+}
+
+// LoadFilter reads the server's chat filter file, returning it's contents.
+// The filter file is optional; if it does not exist, no rules are returned.
+func LoadFilter() ([]wordfilter.Rule, error) {
+	var conf struct {
+		Rule []wordfilter.Rule
+	}
+	_, err := toml.DecodeFile(ConfigPath+"/filter.toml", &conf)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return conf.Rule, err
+}
+
+// SaveFilter writes the given rules to the server's filter file.
+func SaveFilter(rules []wordfilter.Rule) error {
+	conf := struct {
+		Rule []wordfilter.Rule
+	}{rules}
+	return writeTOML(ConfigPath+"/filter.toml", "# This file defines the server's chat filter rules. It is rewritten by /filter.\n", conf)
+}
+
+// SaveRoles writes the given roles to the server's roles file.
+func SaveRoles(roles []permissions.Role) error {
+	conf := struct {
+		Role []permissions.Role
+	}{roles}
+	return writeTOML(ConfigPath+"/roles.toml", "# This file defines the server moderator roles. It is rewritten by /editrole.\n", conf)
+}
+
+// writeTOML encodes v to the file at path.
+// The comment block at the top of the existing file is kept, so documentation survives a rewrite; if there is none, header is written instead.
+func writeTOML(path string, header string, v any) error {
+	if old, err := os.ReadFile(path); err == nil {
+		var b strings.Builder
+		for _, line := range strings.SplitAfter(string(old), "\n") {
+			if t := strings.TrimSpace(line); t != "" && !strings.HasPrefix(t, "#") {
+				break
+			}
+			b.WriteString(line)
+		}
+		if strings.TrimSpace(b.String()) != "" {
+			header = strings.TrimRight(b.String(), "\n") + "\n"
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(header + "\n"); err != nil {
+		return err
+	}
+	return toml.NewEncoder(f).Encode(v)
+}
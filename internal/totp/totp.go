@@ -0,0 +1,107 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+// Package totp implements time-based one-time passwords (RFC 6238), compatible with common authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	digits = 6
+	period = 30 // Seconds each code is valid for.
+	skew   = 1  // Number of periods before and after the current one that are also accepted.
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random base32-encoded secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Code returns the code for a base32-encoded secret at the given time.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.ReplaceAll(secret, " ", "")))
+	if err != nil {
+		return "", fmt.Errorf("invalid secret: %w", err)
+	}
+	return hotp(key, uint64(t.Unix()/period)), nil
+}
+
+// Validate reports whether code is valid for the secret at the given time, allowing for small clock differences.
+func Validate(secret string, code string, t time.Time) bool {
+	_, ok := Match(secret, code, t)
+	return ok
+}
+
+// Match is like Validate, but also returns the time step the code belongs to,
+// so that callers can reject a code that has already been used.
+func Match(secret string, code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != digits {
+		return 0, false
+	}
+	key, err := encoding.DecodeString(strings.ToUpper(strings.ReplaceAll(secret, " ", "")))
+	if err != nil {
+		return 0, false
+	}
+	counter := t.Unix() / period
+	for i := int64(-skew); i <= skew; i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(counter+i))), []byte(code)) == 1 {
+			return counter + i, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns an otpauth:// URI for the secret, which authenticator apps can import.
+func URI(issuer string, account string, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	return fmt.Sprintf("otpauth://totp/%v:%v?%v", url.PathEscape(issuer), url.PathEscape(account), v.Encode())
+}
+
+// hotp computes an HOTP value (RFC 4226) for the given key and counter.
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
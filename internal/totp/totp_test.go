@@ -0,0 +1,84 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The RFC 6238 SHA-1 test secret, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestRFCVectors(t *testing.T) {
+	// RFC 6238 appendix B lists 8-digit codes; these are their last 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code() error: %v", err)
+		}
+		if got != tt.code {
+			t.Errorf("Code(%v) = %v, want %v", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := Code(rfcSecret, now)
+	if !Validate(rfcSecret, code, now.Add(29*time.Second)) {
+		t.Error("code from the previous period should be accepted")
+	}
+	if Validate(rfcSecret, code, now.Add(2*time.Minute)) {
+		t.Error("code from two periods ago should be rejected")
+	}
+	if Validate(rfcSecret, "12345", now) {
+		t.Error("short code should be rejected")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	s, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error: %v", err)
+	}
+	if _, err := Code(s, time.Now()); err != nil {
+		t.Errorf("generated secret is not usable: %v", err)
+	}
+	if uri := URI("My Server", "mod", s); !strings.HasPrefix(uri, "otpauth://totp/My%20Server:mod?") {
+		t.Errorf("unexpected URI %v", uri)
+	}
+}
+
+func TestMatchStep(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := Code(rfcSecret, now)
+	if step, ok := Match(rfcSecret, code, now.Add(29*time.Second)); !ok || step != now.Unix()/period {
+		t.Errorf("Match() = %v, %v; want step %v", step, ok, now.Unix()/period)
+	}
+}
@@ -0,0 +1,241 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+// Package transcript records an area's IC messages and renders them as readable HTML or Markdown documents.
+package transcript
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a single IC message.
+type Entry struct {
+	Time      time.Time
+	Character string
+	Showname  string
+	Message   string
+	Color     int    // AO2 text colour index.
+	Objection string // Shout that preceded the message, if any.
+	Evidence  string // Name of the evidence presented with the message, if any.
+}
+
+// Name returns the name the message was sent under, with the character in brackets if a different showname was used.
+func (e Entry) Name() string {
+	if e.Showname == "" || e.Showname == e.Character {
+		return e.Character
+	}
+	return fmt.Sprintf("%v (%v)", e.Showname, e.Character)
+}
+
+// colors are the default AO2 text colours, by index.
+var colors = []string{"#ffffff", "#00ff00", "#ff0000", "#ffa500", "#2d96ff", "#ffff00", "#ffc0cb", "#00ffff", "#bbbbbb"}
+
+// ColorHex returns the hex code of an AO2 text colour, defaulting to white.
+func ColorHex(c int) string {
+	if c < 0 || c >= len(colors) {
+		return colors[0]
+	}
+	return colors[c]
+}
+
+// Objection returns the shout named by an MS packet's objection modifier, such as "2" or "4&custom".
+func Objection(mod string) string {
+	parts := strings.SplitN(mod, "&", 2)
+	n, _ := strconv.Atoi(parts[0])
+	switch n {
+	case 1:
+		return "Hold it!"
+	case 2:
+		return "Objection!"
+	case 3:
+		return "Take that!"
+	case 4:
+		if len(parts) == 2 && parts[1] != "" {
+			return parts[1] + "!"
+		}
+		return "Custom shout!"
+	default:
+		return ""
+	}
+}
+
+// Log is a fixed-size history of IC messages. It is safe for concurrent use.
+type Log struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewLog returns a log that keeps the last size messages. A log with size 0 keeps nothing.
+func NewLog(size int) *Log {
+	return &Log{entries: make([]Entry, size)}
+}
+
+// Add adds a message to the log, replacing the oldest one if the log is full.
+func (l *Log) Add(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) == 0 {
+		return
+	}
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Entries returns the logged messages sent between from and to, oldest first. A zero time leaves that end unbounded.
+func (l *Log) Entries(from time.Time, to time.Time) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ordered := l.entries[:l.next]
+	if l.full {
+		ordered = append(append([]Entry{}, l.entries[l.next:]...), l.entries[:l.next]...)
+	}
+	var result []Entry
+	for _, e := range ordered {
+		if (!from.IsZero() && e.Time.Before(from)) || (!to.IsZero() && e.Time.After(to)) {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// Format is a transcript document format.
+type Format int
+
+const (
+	HTML Format = iota
+	Markdown
+)
+
+// ParseFormat returns the format with the given name or file extension.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "html", "htm":
+		return HTML, nil
+	case "md", "markdown":
+		return Markdown, nil
+	default:
+		return HTML, fmt.Errorf("unknown format %v", s)
+	}
+}
+
+// Extension returns the file extension for the format.
+func (f Format) Extension() string {
+	if f == Markdown {
+		return "md"
+	}
+	return "html"
+}
+
+// Render writes a transcript of entries in the given format.
+func Render(w io.Writer, f Format, title string, entries []Entry) error {
+	if f == Markdown {
+		return renderMarkdown(w, title, entries)
+	}
+	return htmlTemplate.Execute(w, struct {
+		Title   string
+		Entries []Entry
+	}{title, entries})
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"color": func(c int) template.CSS { return template.CSS(ColorHex(c)) },
+	"clock": func(t time.Time) string { return t.UTC().Format("15:04:05") },
+	"date":  func(t time.Time) string { return t.UTC().Format("02 Jan 2006 15:04 MST") },
+	"first": func(e []Entry) Entry { return e[0] },
+	"last":  func(e []Entry) Entry { return e[len(e)-1] },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { background: #1e1f22; color: #ffffff; font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+.meta, .time, .char { color: #949ba4; }
+.msg { margin: 0.75em 0; }
+.name { font-weight: bold; }
+.shout { color: #ff5555; font-weight: bold; text-transform: uppercase; }
+.text { white-space: pre-wrap; margin-left: 1em; }
+.evidence { color: #f0b232; margin-left: 1em; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- with .Entries}}
+<p class="meta">{{len .}} messages, {{date (first .).Time}} to {{date (last .).Time}}</p>
+{{- end}}
+{{- range .Entries}}
+<div class="msg">
+<span class="time">[{{clock .Time}}]</span> <span class="name">{{or .Showname .Character}}</span>
+{{- if and .Showname (ne .Showname .Character)}} <span class="char">({{.Character}})</span>{{end}}
+{{- with .Objection}} <span class="shout">{{.}}</span>{{end}}
+<div class="text" style="color: {{color .Color}}">{{.Message}}</div>
+{{- with .Evidence}}
+<div class="evidence">Presented evidence: {{.}}</div>
+{{- end}}
+</div>
+{{- end}}
+</body>
+</html>
+`))
+
+// markdownEscaper escapes characters with special meaning in Markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", "&lt;", ">", "&gt;", "#", `\#`, "|", `\|`, "~", `\~`,
+)
+
+// renderMarkdown writes a Markdown transcript. Coloured text uses inline HTML, which most Markdown viewers support.
+func renderMarkdown(w io.Writer, title string, entries []Entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %v\n\n", markdownEscaper.Replace(title))
+	if len(entries) > 0 {
+		fmt.Fprintf(&b, "*%v messages, %v to %v*\n\n", len(entries),
+			entries[0].Time.UTC().Format("02 Jan 2006 15:04 MST"), entries[len(entries)-1].Time.UTC().Format("02 Jan 2006 15:04 MST"))
+	}
+	for _, e := range entries {
+		fmt.Fprintf(&b, "**[%v] %v**", e.Time.UTC().Format("15:04:05"), markdownEscaper.Replace(e.Name()))
+		if e.Objection != "" {
+			fmt.Fprintf(&b, " — ***%v***", markdownEscaper.Replace(strings.ToUpper(e.Objection)))
+		}
+		b.WriteString("\n\n")
+		var lines []string
+		for _, line := range strings.Split(e.Message, "\n") {
+			line = markdownEscaper.Replace(line)
+			if hex := ColorHex(e.Color); hex != colors[0] && line != "" {
+				line = fmt.Sprintf(`<span style="color: %v">%v</span>`, hex, line)
+			}
+			lines = append(lines, "> "+line)
+		}
+		fmt.Fprintf(&b, "%v\n\n", strings.Join(lines, "  \n"))
+		if e.Evidence != "" {
+			fmt.Fprintf(&b, "*Presented evidence: %v*\n\n", markdownEscaper.Replace(e.Evidence))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,89 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package transcript
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLog(3)
+	for i := 0; i < 5; i++ {
+		l.Add(Entry{Time: base.Add(time.Duration(i) * time.Minute), Message: string(rune('a' + i))})
+	}
+	var got []string
+	for _, e := range l.Entries(time.Time{}, time.Time{}) {
+		got = append(got, e.Message)
+	}
+	if strings.Join(got, "") != "cde" {
+		t.Errorf("Entries() = %v, want the last 3 messages in order", got)
+	}
+	if e := l.Entries(base.Add(3*time.Minute), base.Add(3*time.Minute)); len(e) != 1 || e[0].Message != "d" {
+		t.Errorf("Entries() with bounds = %v, want only d", e)
+	}
+	empty := NewLog(0)
+	empty.Add(Entry{Message: "a"})
+	if len(empty.Entries(time.Time{}, time.Time{})) != 0 {
+		t.Error("a log of size 0 should keep nothing")
+	}
+}
+
+func TestObjection(t *testing.T) {
+	tests := map[string]string{
+		"0":         "",
+		"":          "",
+		"1":         "Hold it!",
+		"2":         "Objection!",
+		"3":         "Take that!",
+		"4&Gotcha":  "Gotcha!",
+		"4":         "Custom shout!",
+		"2&ignored": "Objection!",
+	}
+	for mod, want := range tests {
+		if got := Objection(mod); got != want {
+			t.Errorf("Objection(%q) = %q, want %q", mod, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	entries := []Entry{
+		{Time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), Character: "Phoenix", Showname: "Nick", Message: "<b>Take</b> *that*", Color: 2, Objection: "Take that!", Evidence: "Knife"},
+		{Time: time.Date(2024, 1, 1, 12, 1, 0, 0, time.UTC), Character: "Edgeworth", Message: "Hmph."},
+	}
+	var html strings.Builder
+	if err := Render(&html, HTML, "Court", entries); err != nil {
+		t.Fatalf("Render(HTML) error: %v", err)
+	}
+	for _, want := range []string{"&lt;b&gt;Take&lt;/b&gt;", "color: #ff0000", "Nick</span> <span class=\"char\">(Phoenix)", "Presented evidence: Knife", "2 messages"} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("HTML transcript is missing %q:\n%v", want, html.String())
+		}
+	}
+	var md strings.Builder
+	if err := Render(&md, Markdown, "Court", entries); err != nil {
+		t.Fatalf("Render(Markdown) error: %v", err)
+	}
+	for _, want := range []string{"# Court", "**[12:00:00] Nick (Phoenix)** — ***TAKE THAT!***", "&lt;b&gt;Take&lt;/b&gt; \\*that\\*", "#ff0000", "*Presented evidence: Knife*", "> Hmph."} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Markdown transcript is missing %q:\n%v", want, md.String())
+		}
+	}
+}
@@ -0,0 +1,290 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Headers sent with each outgoing webhook request.
+const (
+	EventHeader     = "X-Athena-Event"
+	DeliveryHeader  = "X-Athena-Delivery"
+	SignatureHeader = "X-Athena-Signature" // "sha256=" followed by the hex HMAC-SHA256 of the body, keyed with the target's secret.
+)
+
+const targetQueueSize = 256 // Events waiting for delivery to a target before new ones are dropped.
+
+// Event is a server event sent to outgoing webhooks as JSON.
+type Event struct {
+	ID   string                 `json:"id"`
+	Type string                 `json:"type"`
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// Target is a URL that events are posted to.
+type Target struct {
+	URL    string
+	Secret string   // Used to sign requests. If empty, requests are not signed.
+	Events []string // Event types sent to the URL, matched exactly. If empty, every event is sent.
+}
+
+// DeadLetter is an event that could not be delivered, as written to the dead-letter file.
+type DeadLetter struct {
+	URL      string    `json:"url"`
+	Event    Event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Time     time.Time `json:"time"`
+}
+
+// Dispatcher posts events to outgoing webhooks, retrying failed deliveries with exponential backoff.
+// Events that still can't be delivered are appended to a dead-letter file, one JSON object per line.
+type Dispatcher struct {
+	mu         sync.RWMutex // Held for reading while queueing events, so the queues aren't closed during a send.
+	closed     bool
+	workers    []*targetWorker
+	client     *http.Client
+	attempts   int
+	backoff    time.Duration
+	deadLetter string
+	dead       chan DeadLetter // Dead letters waiting to be written, so the file is never written by a caller of Send.
+	deadDone   chan struct{}
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// targetWorker delivers events to one target in order, so a slow target doesn't hold up the others.
+type targetWorker struct {
+	target Target
+	events map[string]bool
+	queue  chan Event
+}
+
+// NewDispatcher starts delivering events to the targets. Each event is tried up to attempts times,
+// waiting backoff after the first failure and twice as long after each one after that.
+func NewDispatcher(targets []Target, attempts int, backoff time.Duration, deadLetter string) *Dispatcher {
+	if attempts <= 0 {
+		attempts = 1
+	}
+	d := &Dispatcher{
+		client:     &http.Client{Timeout: 10 * time.Second},
+		attempts:   attempts,
+		backoff:    backoff,
+		deadLetter: deadLetter,
+		dead:       make(chan DeadLetter, targetQueueSize),
+		deadDone:   make(chan struct{}),
+		stop:       make(chan struct{}),
+	}
+	go d.writeDeadLetters()
+	for _, t := range targets {
+		w := &targetWorker{target: t, queue: make(chan Event, targetQueueSize)}
+		if len(t.Events) > 0 {
+			w.events = make(map[string]bool, len(t.Events))
+			for _, e := range t.Events {
+				w.events[e] = true
+			}
+		}
+		d.workers = append(d.workers, w)
+		d.wg.Add(1)
+		go d.run(w)
+	}
+	return d
+}
+
+// Send queues an event for every target that accepts its type. It never blocks;
+// if a target has fallen too far behind, the event is queued for the dead-letter file instead.
+func (d *Dispatcher) Send(eventType string, data map[string]interface{}) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+	e := Event{ID: newEventID(), Type: eventType, Time: time.Now().UTC(), Data: data}
+	for _, w := range d.workers {
+		if w.events != nil && !w.events[eventType] {
+			continue
+		}
+		select {
+		case w.queue <- e:
+		default:
+			select {
+			case d.dead <- newDeadLetter(w.target.URL, e, 0, fmt.Errorf("delivery queue is full")):
+			default:
+				fmt.Fprintf(os.Stderr, "dropped undelivered webhook event %v for %v: dead-letter queue is full\n", e.ID, w.target.URL)
+			}
+		}
+	}
+}
+
+// Close stops retrying, writes queued events to the dead-letter file without posting them,
+// and waits for deliveries in progress to finish.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.stop)
+	for _, w := range d.workers {
+		close(w.queue)
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+	close(d.dead)
+	<-d.deadDone
+}
+
+func (d *Dispatcher) run(w *targetWorker) {
+	defer d.wg.Done()
+	for e := range w.queue {
+		select {
+		case <-d.stop:
+			d.addDeadLetter(w.target.URL, e, 0, fmt.Errorf("dispatcher closed"))
+			continue
+		default:
+		}
+		d.deliver(w.target, e)
+	}
+}
+
+// deliver posts an event to a target until it succeeds, fails permanently, or runs out of attempts.
+func (d *Dispatcher) deliver(t Target, e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		d.addDeadLetter(t.URL, e, 0, err)
+		return
+	}
+	wait := d.backoff
+	var attempt int
+	for attempt = 1; ; attempt++ {
+		var retry bool
+		retry, err = d.post(t, e, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= d.attempts {
+			break
+		}
+		select {
+		case <-d.stop:
+			d.addDeadLetter(t.URL, e, attempt, err)
+			return
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+	d.addDeadLetter(t.URL, e, attempt, err)
+}
+
+// post sends an event once, reporting whether a failure is worth retrying.
+// Network errors, rate limits and server errors are retried; other error responses are not.
+func (d *Dispatcher) post(t Target, e Event, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, e.Type)
+	req.Header.Set(DeliveryHeader, e.ID)
+	if t.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(t.Secret, body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false, nil
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, fmt.Errorf("%v", resp.Status)
+}
+
+// addDeadLetter queues an event that could not be delivered to be written to the dead-letter file.
+// Only workers call it, and they wait for room in the queue rather than lose the event.
+func (d *Dispatcher) addDeadLetter(url string, e Event, attempts int, cause error) {
+	d.dead <- newDeadLetter(url, e, attempts, cause)
+}
+
+func newDeadLetter(url string, e Event, attempts int, cause error) DeadLetter {
+	return DeadLetter{URL: url, Event: e, Attempts: attempts, Error: cause.Error(), Time: time.Now().UTC()}
+}
+
+// writeDeadLetters writes queued dead letters to the dead-letter file until the queue is closed.
+func (d *Dispatcher) writeDeadLetters() {
+	defer close(d.deadDone)
+	for l := range d.dead {
+		if d.deadLetter != "" {
+			writeDeadLetter(d.deadLetter, l)
+		}
+	}
+}
+
+// writeDeadLetter appends a dead letter to the file at path.
+// Errors go to stderr, as the logger may be what is being notified.
+func writeDeadLetter(path string, l DeadLetter) {
+	line, err := json.Marshal(l)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode undelivered webhook event: %v\n", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open webhook dead-letter file: %v\n", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write webhook dead-letter file: %v\n", err)
+	}
+}
+
+// Sign returns the signature header value for a request body, so receivers can check requests came from the server.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether a signature header value is valid for a request body.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// newEventID returns a random ID, which receivers can use to ignore an event delivered twice.
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprint(time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
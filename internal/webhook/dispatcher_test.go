@@ -0,0 +1,256 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// readDeadLetters returns the entries in a dead-letter file.
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+	t.Helper()
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var l DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			t.Fatalf("invalid dead letter %q: %v", scanner.Text(), err)
+		}
+		letters = append(letters, l)
+	}
+	return letters
+}
+
+// waitFor fails the test if cond doesn't become true within a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for delivery")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestDispatcherSignsEvents tests that events are posted as signed JSON, and only to targets that accept them.
+func TestDispatcherSignsEvents(t *testing.T) {
+	var mu sync.Mutex
+	var got []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify("secret", body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("invalid signature %q", r.Header.Get(SignatureHeader))
+		}
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("invalid body %q: %v", body, err)
+		}
+		if r.Header.Get(EventHeader) != e.Type || r.Header.Get(DeliveryHeader) != e.ID {
+			t.Errorf("headers %v don't match event %+v", r.Header, e)
+		}
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	var unsigned int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) != "" {
+			t.Errorf("request without a secret was signed")
+		}
+		atomic.AddInt32(&unsigned, 1)
+	}))
+	defer other.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	d := NewDispatcher([]Target{
+		{URL: srv.URL, Secret: "secret"},
+		{URL: other.URL, Events: []string{"ban"}},
+	}, 1, time.Millisecond, deadLetter)
+	d.Send("modcall", map[string]interface{}{"reason": "help"})
+	d.Send("ban", map[string]interface{}{"ipid": "abc"})
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 2 && atomic.LoadInt32(&unsigned) == 1
+	})
+	d.Close()
+
+	if len(got) != 2 || got[0].Type != "modcall" || got[0].Data["reason"] != "help" || got[1].Type != "ban" {
+		t.Errorf("got %+v, want modcall and ban events", got)
+	}
+	if got[0].ID == got[1].ID {
+		t.Errorf("events share ID %v", got[0].ID)
+	}
+	if atomic.LoadInt32(&unsigned) != 1 {
+		t.Errorf("filtered target got %v events, want 1", unsigned)
+	}
+	if l := readDeadLetters(t, deadLetter); len(l) != 0 {
+		t.Errorf("got dead letters %+v", l)
+	}
+}
+
+// TestDispatcherRetries tests that server errors are retried and client errors are not.
+func TestDispatcherRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // Responses to each attempt; later attempts get the last one.
+		attempts int32
+		dead     bool
+	}{
+		{"recovers", []int{500, 429, 200}, 3, false},
+		{"exhausted", []int{503}, 4, true},
+		{"rejected", []int{400}, 1, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&calls, 1))
+				if n > len(tc.statuses) {
+					n = len(tc.statuses)
+				}
+				w.WriteHeader(tc.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+			d := NewDispatcher([]Target{{URL: srv.URL}}, 4, time.Millisecond, deadLetter)
+			d.Send("report", map[string]interface{}{"area": "Basement"})
+			// Close stops retrying, so wait until the event has been delivered or given up on.
+			waitFor(t, func() bool {
+				if tc.dead {
+					return len(readDeadLetters(t, deadLetter)) > 0
+				}
+				return atomic.LoadInt32(&calls) >= tc.attempts
+			})
+			d.Close()
+
+			if calls := atomic.LoadInt32(&calls); calls != tc.attempts {
+				t.Errorf("got %v attempts, want %v", calls, tc.attempts)
+			}
+			letters := readDeadLetters(t, deadLetter)
+			if !tc.dead {
+				if len(letters) != 0 {
+					t.Errorf("got dead letters %+v", letters)
+				}
+				return
+			}
+			if len(letters) != 1 {
+				t.Fatalf("got %v dead letters, want 1", len(letters))
+			}
+			l := letters[0]
+			if l.URL != srv.URL || l.Event.Type != "report" || l.Event.Data["area"] != "Basement" || l.Attempts != int(tc.attempts) || l.Error == "" {
+				t.Errorf("got dead letter %+v", l)
+			}
+		})
+	}
+}
+
+// TestDispatcherUnreachable tests that events for a target that can't be reached end up in the dead-letter file.
+func TestDispatcherUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	d := NewDispatcher([]Target{{URL: url}}, 2, time.Millisecond, deadLetter)
+	d.Send("join", map[string]interface{}{"uid": 1})
+	waitFor(t, func() bool { return len(readDeadLetters(t, deadLetter)) > 0 })
+	d.Close()
+
+	if l := readDeadLetters(t, deadLetter); len(l) != 1 || l[0].Attempts != 2 {
+		t.Errorf("got dead letters %+v, want one after 2 attempts", l)
+	}
+}
+
+// TestDispatcherCloseSkipsQueued tests that events still queued when the dispatcher closes go to the dead-letter file without being posted.
+func TestDispatcherCloseSkipsQueued(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+	}))
+	defer srv.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	d := NewDispatcher([]Target{{URL: srv.URL}}, 1, time.Millisecond, deadLetter)
+	d.Send("join", map[string]interface{}{"uid": 1})
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 1 })
+	d.Send("join", map[string]interface{}{"uid": 2})
+	d.Send("join", map[string]interface{}{"uid": 3})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	d.Close()
+
+	if calls := atomic.LoadInt32(&calls); calls != 1 {
+		t.Errorf("got %v requests, want only the one in progress", calls)
+	}
+	letters := readDeadLetters(t, deadLetter)
+	if len(letters) != 2 || letters[0].Attempts != 0 || letters[0].Event.Data["uid"] != float64(2) {
+		t.Errorf("got dead letters %+v, want the 2 queued events", letters)
+	}
+}
+
+// TestDispatcherQueueFull tests that an event for a target whose queue is full goes to the dead-letter file.
+func TestDispatcherQueueFull(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+	}))
+	defer srv.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	d := NewDispatcher([]Target{{URL: srv.URL}}, 1, time.Millisecond, deadLetter)
+	d.Send("join", map[string]interface{}{"uid": 0})
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 1 })
+	for i := 1; i <= targetQueueSize; i++ {
+		d.Send("join", map[string]interface{}{"uid": i})
+	}
+	d.Send("join", map[string]interface{}{"uid": -1})
+	waitFor(t, func() bool { return len(readDeadLetters(t, deadLetter)) == 1 })
+	close(release)
+	d.Close()
+
+	letters := readDeadLetters(t, deadLetter)
+	if len(letters) == 0 || letters[0].Event.Data["uid"] != float64(-1) || letters[0].Error != "delivery queue is full" {
+		t.Errorf("got dead letters %+v, want the event that didn't fit first", letters)
+	}
+}
@@ -62,3 +62,18 @@ func PostReport(name string, contents string) error {
 	err := discord.UploadFile(p, f)
 	return err
 }
+
+// PostEvents sends a batch of server events to the discord webhook.
+func PostEvents(events []string) error {
+	e := discord.Embed{
+		Title:       "Server Events",
+		Description: strings.Join(events, "\n"),
+		Color:       ServerColor,
+	}
+	p := discord.PostOptions{
+		Username: ServerName,
+		Embeds:   []discord.Embed{e},
+	}
+	err := discord.Post(p)
+	return err
+}
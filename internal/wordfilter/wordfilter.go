@@ -0,0 +1,218 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+// Package wordfilter implements the server's configurable chat filter.
+package wordfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Action is the response taken when a filter rule matches.
+// Actions are ordered by severity, so the most severe hit can be found with a simple comparison.
+type Action int
+
+const (
+	Notify Action = iota
+	Censor
+	Block
+	Warn
+	Mute
+	Kick
+)
+
+// Rule is a single filter rule as stored in the filter file.
+type Rule struct {
+	Pattern string `toml:"pattern"`
+	Regex   bool   `toml:"regex"`
+	Action  string `toml:"action"`
+}
+
+// Hit describes a rule that matched a piece of text.
+type Hit struct {
+	ID     int // 1-based index of the rule, as shown by Rules.
+	Rule   Rule
+	Action Action
+	Match  string
+}
+
+// Result is the outcome of running text through the filter.
+type Result struct {
+	Text   string // The text with all censor matches replaced.
+	Hits   []Hit
+	Action Action // The most severe action among all hits. Only meaningful if Hits is non-empty.
+}
+
+type compiledRule struct {
+	rule   Rule
+	action Action
+	re     *regexp.Regexp
+}
+
+// Filter is a thread-safe set of compiled filter rules.
+type Filter struct {
+	mu    sync.RWMutex
+	rules []compiledRule
+}
+
+// ParseAction returns the action with the given name.
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "notify":
+		return Notify, nil
+	case "censor":
+		return Censor, nil
+	case "block":
+		return Block, nil
+	case "warn":
+		return Warn, nil
+	case "mute":
+		return Mute, nil
+	case "kick":
+		return Kick, nil
+	}
+	return Notify, fmt.Errorf("unknown filter action %q", s)
+}
+
+// String returns the string representation of an action.
+func (a Action) String() string {
+	switch a {
+	case Notify:
+		return "notify"
+	case Censor:
+		return "censor"
+	case Block:
+		return "block"
+	case Warn:
+		return "warn"
+	case Mute:
+		return "mute"
+	case Kick:
+		return "kick"
+	}
+	return ""
+}
+
+// compile validates a rule and builds its matcher.
+func compile(r Rule) (compiledRule, error) {
+	if strings.TrimSpace(r.Pattern) == "" {
+		return compiledRule{}, fmt.Errorf("empty filter pattern")
+	}
+	action, err := ParseAction(r.Action)
+	if err != nil {
+		return compiledRule{}, err
+	}
+	var expr string
+	if r.Regex {
+		expr = "(?i)" + r.Pattern
+	} else {
+		// Plain words only match on word boundaries, so "ass" does not hit "class".
+		expr = regexp.QuoteMeta(r.Pattern)
+		if first, _ := utf8.DecodeRuneInString(r.Pattern); isWordRune(first) {
+			expr = `\b` + expr
+		}
+		if last, _ := utf8.DecodeLastRuneInString(r.Pattern); isWordRune(last) {
+			expr = expr + `\b`
+		}
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return compiledRule{}, fmt.Errorf("invalid filter pattern: %w", err)
+	}
+	r.Action = action.String()
+	return compiledRule{rule: r, action: action, re: re}, nil
+}
+
+// isWordRune reports whether r is matched by the regexp \w class.
+func isWordRune(r rune) bool {
+	return r == '_' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
+
+// New returns a filter containing the given rules.
+func New(rules []Rule) (*Filter, error) {
+	f := &Filter{}
+	for i, r := range rules {
+		c, err := compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("filter rule %v: %w", i+1, err)
+		}
+		f.rules = append(f.rules, c)
+	}
+	return f, nil
+}
+
+// Rules returns a copy of the filter's rules.
+func (f *Filter) Rules() []Rule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	rules := make([]Rule, len(f.rules))
+	for i, c := range f.rules {
+		rules[i] = c.rule
+	}
+	return rules
+}
+
+// Add appends a rule to the filter.
+func (f *Filter) Add(r Rule) error {
+	c, err := compile(r)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.rules = append(f.rules, c)
+	f.mu.Unlock()
+	return nil
+}
+
+// Remove deletes the rule with the given 1-based ID.
+func (f *Filter) Remove(id int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id < 1 || id > len(f.rules) {
+		return fmt.Errorf("no filter rule with ID %v", id)
+	}
+	f.rules = append(f.rules[:id-1], f.rules[id:]...)
+	return nil
+}
+
+// Check runs text through every rule, returning the censored text and all hits.
+func (f *Filter) Check(text string) Result {
+	res := Result{Text: text}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for i, c := range f.rules {
+		match := c.re.FindString(text)
+		if match == "" {
+			continue
+		}
+		res.Hits = append(res.Hits, Hit{ID: i + 1, Rule: c.rule, Action: c.action, Match: match})
+		if len(res.Hits) == 1 || c.action > res.Action {
+			res.Action = c.action
+		}
+		if c.action == Censor {
+			res.Text = c.re.ReplaceAllStringFunc(res.Text, func(s string) string {
+				return strings.Repeat("*", utf8.RuneCountInString(s))
+			})
+		}
+	}
+	return res
+}
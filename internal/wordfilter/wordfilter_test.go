@@ -0,0 +1,118 @@
+/* Athena - A server for Attorney Online 2 written in Go
+Copyright (C) 2022 MangosArentLiterature <mango@transmenace.dev>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>. */
+
+package wordfilter
+
+import "testing"
+
+func TestPlainWordBoundaries(t *testing.T) {
+	f, err := New([]Rule{{Pattern: "heck", Action: "censor"}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	tests := []struct {
+		in   string
+		out  string
+		hits int
+	}{
+		{"what the heck", "what the ****", 1},
+		{"What The HECK!", "What The ****!", 1},
+		{"checkmate", "checkmate", 0},
+		{"heck heck", "**** ****", 1},
+	}
+	for _, tt := range tests {
+		res := f.Check(tt.in)
+		if res.Text != tt.out {
+			t.Errorf("Check(%q).Text = %q, want %q", tt.in, res.Text, tt.out)
+		}
+		if len(res.Hits) != tt.hits {
+			t.Errorf("Check(%q) hits = %d, want %d", tt.in, len(res.Hits), tt.hits)
+		}
+	}
+}
+
+func TestRegexRule(t *testing.T) {
+	f, err := New([]Rule{{Pattern: `discord\.gg/\w+`, Regex: true, Action: "block"}})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	res := f.Check("join DISCORD.GG/abc now")
+	if len(res.Hits) != 1 || res.Action != Block {
+		t.Errorf("expected a single block hit, got %+v", res)
+	}
+	if res.Text != "join DISCORD.GG/abc now" {
+		t.Errorf("block rules must not alter text, got %q", res.Text)
+	}
+}
+
+func TestMostSevereAction(t *testing.T) {
+	f, err := New([]Rule{
+		{Pattern: "foo", Action: "notify"},
+		{Pattern: "bar", Action: "kick"},
+		{Pattern: "baz", Action: "censor"},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	res := f.Check("foo baz bar")
+	if res.Action != Kick {
+		t.Errorf("Action = %v, want kick", res.Action)
+	}
+	if len(res.Hits) != 3 {
+		t.Errorf("hits = %d, want 3", len(res.Hits))
+	}
+	if res.Text != "foo *** bar" {
+		t.Errorf("Text = %q, want %q", res.Text, "foo *** bar")
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	bad := []Rule{
+		{Pattern: "", Action: "censor"},
+		{Pattern: "word", Action: "explode"},
+		{Pattern: "([", Regex: true, Action: "block"},
+	}
+	for _, r := range bad {
+		if _, err := New([]Rule{r}); err == nil {
+			t.Errorf("New(%+v) expected error", r)
+		}
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+	f, _ := New(nil)
+	if err := f.Add(Rule{Pattern: "one", Action: "WARN"}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	if err := f.Add(Rule{Pattern: "two", Action: "mute"}); err != nil {
+		t.Fatalf("Add() error: %v", err)
+	}
+	rules := f.Rules()
+	if len(rules) != 2 || rules[0].Action != "warn" {
+		t.Fatalf("unexpected rules after add: %+v", rules)
+	}
+	if err := f.Remove(3); err == nil {
+		t.Error("Remove(3) expected error")
+	}
+	if err := f.Remove(1); err != nil {
+		t.Fatalf("Remove(1) error: %v", err)
+	}
+	res := f.Check("one two")
+	if len(res.Hits) != 1 || res.Hits[0].ID != 1 || res.Action != Mute {
+		t.Errorf("unexpected result after remove: %+v", res)
+	}
+}